@@ -261,7 +261,11 @@ func generateWorkspaceClaude(st *state.State, id string) string {
 		b.WriteString("|------|-----|--------|\n")
 		for _, r := range st.Spec.Repos {
 			p := state.RepoPath(r)
-			b.WriteString(fmt.Sprintf("| %s | %s | %s |\n", p, r.URL, r.Branch))
+			branch := r.Branch
+			if r.Pinned() {
+				branch = r.Ref + " (pinned)"
+			}
+			b.WriteString(fmt.Sprintf("| %s | %s | %s |\n", p, r.URL, branch))
 		}
 	}
 
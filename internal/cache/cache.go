@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"os"
 	"time"
+
+	"github.com/milldr/flow/internal/fsutil"
 )
 
 // StatusEntry holds a cached status for a single workspace.
@@ -36,5 +38,5 @@ func SaveStatus(path string, c StatusCache) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	return fsutil.WriteFileAtomic(path, data, 0o644)
 }
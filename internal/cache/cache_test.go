@@ -82,3 +82,33 @@ func TestSaveBadPath(t *testing.T) {
 func writeFile(path, content string) error {
 	return os.WriteFile(path, []byte(content), 0o644)
 }
+
+func TestFetchCacheFresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fetch.json")
+	now := time.Now().Truncate(time.Second)
+
+	if err := SaveFetch(path, FetchCache{"/repos/a.git": {FetchedAt: now.Add(-30 * time.Second)}}); err != nil {
+		t.Fatalf("SaveFetch: %v", err)
+	}
+	c := LoadFetch(path)
+
+	if !c.Fresh("/repos/a.git", time.Minute, now) {
+		t.Error("fetch 30s ago should be fresh with a 1m TTL")
+	}
+	if c.Fresh("/repos/a.git", 10*time.Second, now) {
+		t.Error("fetch 30s ago should be stale with a 10s TTL")
+	}
+	if c.Fresh("/repos/a.git", 0, now) {
+		t.Error("a zero TTL should never be fresh")
+	}
+	if c.Fresh("/repos/b.git", time.Minute, now) {
+		t.Error("unknown repo should not be fresh")
+	}
+}
+
+func TestLoadFetchMissingFile(t *testing.T) {
+	c := LoadFetch("/nonexistent/path/fetch.json")
+	if c == nil || len(c) != 0 {
+		t.Errorf("expected empty cache, got %v", c)
+	}
+}
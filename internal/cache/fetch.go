@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/milldr/flow/internal/fsutil"
+)
+
+// FetchEntry records the last successful fetch of a bare repo.
+type FetchEntry struct {
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// FetchCache maps bare repo paths to their last fetch.
+type FetchCache map[string]FetchEntry
+
+// Fresh reports whether repo was fetched less than ttl ago.
+func (c FetchCache) Fresh(repo string, ttl time.Duration, now time.Time) bool {
+	e, ok := c[repo]
+	return ok && ttl > 0 && now.Sub(e.FetchedAt) < ttl
+}
+
+// LoadFetch reads the fetch cache from disk. Returns an empty cache if the
+// file doesn't exist or can't be parsed.
+func LoadFetch(path string) FetchCache {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return make(FetchCache)
+	}
+	var c FetchCache
+	if err := json.Unmarshal(data, &c); err != nil || c == nil {
+		return make(FetchCache)
+	}
+	return c
+}
+
+// SaveFetch writes the fetch cache to disk as JSON.
+func SaveFetch(path string, c FetchCache) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0o644)
+}
@@ -27,6 +27,9 @@ func newArchiveCmd(svc *workspace.Service, cfg *config.Config) *cobra.Command {
 while preserving the state file. Archived workspaces are hidden from
 flow status by default (use --all to see them).
 
+Uncommitted changes and unpushed commits are saved under the workspace's
+archive/ directory first and reapplied by flow unarchive.
+
 Use --closed to archive all workspaces with "closed" status at once.`,
 		Args:    cobra.MaximumNArgs(1),
 		Example: "  flow archive my-workspace    # Archive a single workspace\n  flow archive --closed        # Archive all closed workspaces",
@@ -73,6 +76,7 @@ func runArchiveOne(ctx context.Context, svc *workspace.Service, idOrName string,
 	err = ui.RunWithSpinner("Archiving workspace: "+name, func(_ func(string)) error {
 		return svc.Archive(ctx, id)
 	})
+	printRescued(svc, id)
 	if err != nil {
 		return err
 	}
@@ -81,6 +85,22 @@ func runArchiveOne(ctx context.Context, svc *workspace.Service, idOrName string,
 	return nil
 }
 
+// printRescued lists work saved from removed worktrees, if any.
+func printRescued(svc *workspace.Service, id string) {
+	st, err := svc.Find(id)
+	if err != nil {
+		return
+	}
+	for _, r := range st.Metadata.Rescued {
+		if r.Patch != "" {
+			ui.Info(fmt.Sprintf("%s: saved uncommitted changes to %s", r.Path, r.Patch))
+		}
+		if r.Bundle != "" {
+			ui.Info(fmt.Sprintf("%s: saved %d unpushed commit(s) to %s", r.Path, r.Commits, r.Bundle))
+		}
+	}
+}
+
 func runArchiveClosed(ctx context.Context, svc *workspace.Service, cfg *config.Config, force bool) error {
 	infos, err := svc.List()
 	if err != nil {
@@ -90,7 +110,7 @@ func runArchiveClosed(ctx context.Context, svc *workspace.Service, cfg *config.C
 	// Filter to non-archived workspaces only.
 	var candidates []workspace.Info
 	for _, info := range infos {
-		if !info.Archived {
+		if info.Err == nil && !info.Archived {
 			candidates = append(candidates, info)
 		}
 	}
@@ -172,7 +192,9 @@ func runArchiveClosed(ctx context.Context, svc *workspace.Service, cfg *config.C
 
 	var archiveErrors []error
 	for _, ws := range closedList {
-		if err := svc.Archive(ctx, ws.id); err != nil {
+		err := svc.Archive(ctx, ws.id)
+		printRescued(svc, ws.id)
+		if err != nil {
 			archiveErrors = append(archiveErrors, fmt.Errorf("archiving %s: %w", ws.name, err))
 			continue
 		}
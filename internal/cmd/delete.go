@@ -2,7 +2,9 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/milldr/flow/internal/state"
 	"github.com/milldr/flow/internal/ui"
@@ -11,14 +13,29 @@ import (
 )
 
 func newDeleteCmd(svc *workspace.Service) *cobra.Command {
-	var force bool
+	var (
+		force   bool
+		discard bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "delete <workspace> [workspace...]",
 		Short: "Delete one or more workspaces and their worktrees",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Delete one or more workspaces and their worktrees.
+
+The worktrees are removed and the rest of the workspace — state, generated
+files, and any uncommitted changes or unpushed commits saved from the
+worktrees — is moved to ~/.flow/trash/. Use flow trash restore to bring it
+back.
+
+Before deleting, each worktree is checked for uncommitted changes, commits
+not on any remote, and stashes, and the results are shown in the prompt.
+With --force, workspaces holding such work are refused unless
+--discard-unpushed is also passed.`,
+		Args: cobra.MinimumNArgs(1),
 		Example: `  flow delete calm-delta
   flow delete calm-delta warm-brook --force
+  flow delete calm-delta --force --discard-unpushed
   flow delete ws1 ws2 ws3`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			for _, arg := range args {
@@ -29,16 +46,17 @@ func newDeleteCmd(svc *workspace.Service) *cobra.Command {
 
 				name := workspaceDisplayName(id, st)
 
-				if !force {
-					repos := make([]ui.DeleteRepo, len(st.Spec.Repos))
-					for i, r := range st.Spec.Repos {
-						repos[i] = ui.DeleteRepo{
-							Path:   state.RepoPath(r),
-							Branch: r.Branch,
-						}
-					}
+				risks, err := deleteRisks(cmd.Context(), svc, id, name)
+				if err != nil {
+					return err
+				}
 
-					confirmed, err := ui.ConfirmDelete(name, id, repos)
+				if force {
+					if err := checkDeleteRisks(name, risks, discard); err != nil {
+						return err
+					}
+				} else {
+					confirmed, err := ui.ConfirmDelete(name, id, deleteRepos(st, risks))
 					if err != nil {
 						return err
 					}
@@ -49,18 +67,82 @@ func newDeleteCmd(svc *workspace.Service) *cobra.Command {
 				}
 
 				err = ui.RunWithSpinner("Deleting workspace: "+name, func(_ func(string)) error {
-					return svc.Delete(cmd.Context(), id)
+					_, err := svc.Delete(cmd.Context(), id)
+					return err
 				})
 				if err != nil {
 					return fmt.Errorf("deleting %s: %w", name, err)
 				}
 
 				ui.Success("Deleted workspace: " + name)
+				ui.Printf("  Restore with %s\n", ui.Code("flow trash restore "+id))
 			}
 			return nil
 		},
 	}
 
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	cmd.Flags().BoolVar(&discard, "discard-unpushed", false, "With --force, delete even if worktrees have uncommitted changes, unpushed commits, or stashes")
 	return cmd
 }
+
+// deleteRisks inspects a workspace's worktrees for local work.
+func deleteRisks(ctx context.Context, svc *workspace.Service, id, name string) ([]workspace.RepoRisk, error) {
+	var risks []workspace.RepoRisk
+	err := ui.RunWithSpinner("Checking for local work: "+name, func(_ func(string)) error {
+		var err error
+		risks, err = svc.DeleteRisks(ctx, id)
+		return err
+	})
+	return risks, err
+}
+
+// checkDeleteRisks refuses a forced delete that would lose local work,
+// unless discard is set.
+func checkDeleteRisks(name string, risks []workspace.RepoRisk, discard bool) error {
+	var risky []string
+	for _, r := range risks {
+		if r.Risky() {
+			risky = append(risky, fmt.Sprintf("%s (%s)", r.Path, r.Reason()))
+		}
+	}
+	if len(risky) == 0 {
+		return nil
+	}
+	if discard {
+		ui.Warning(fmt.Sprintf("Discarding local work in %s: %s", name, strings.Join(risky, "; ")))
+		return nil
+	}
+	return fmt.Errorf("%w: %s\n  %s\n  Hint: push or discard the changes, or re-run with --discard-unpushed",
+		workspace.ErrDeleteRefused, name, strings.Join(risky, "\n  "))
+}
+
+// deleteRepos builds the confirmation list: declared repos in state order,
+// followed by undeclared worktrees that would also be removed.
+func deleteRepos(st *state.State, risks []workspace.RepoRisk) []ui.DeleteRepo {
+	byPath := make(map[string]workspace.RepoRisk, len(risks))
+	for _, r := range risks {
+		byPath[r.Path] = r
+	}
+
+	repos := make([]ui.DeleteRepo, 0, len(st.Spec.Repos))
+	for _, r := range st.Spec.Repos {
+		path := state.RepoPath(r)
+		repos = append(repos, ui.DeleteRepo{
+			Path:   path,
+			Branch: r.Target(),
+			Risk:   byPath[path].Reason(),
+		})
+	}
+	for _, r := range risks {
+		if !r.Stray {
+			continue
+		}
+		branch := r.Branch
+		if branch == "HEAD" {
+			branch = "(detached)"
+		}
+		repos = append(repos, ui.DeleteRepo{Path: r.Path, Branch: branch, Risk: r.Reason()})
+	}
+	return repos
+}
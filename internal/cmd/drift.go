@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+func newDriftCmd(svc *workspace.Service) *cobra.Command {
+	var fix string
+
+	cmd := &cobra.Command{
+		Use:   "drift [workspace]",
+		Short: "Show worktrees that no longer match the state file",
+		Long: `Compare each worktree's checked-out branch, HEAD, and base with the state
+file, and list missing worktrees and undeclared worktree directories.
+
+Without arguments, checks all active workspaces.
+
+Use --fix=state to rewrite state.yaml to match the worktrees, or
+--fix=checkout to switch clean worktrees back to their declared branches
+(and create missing ones from their existing branches, which are never reset).`,
+		Args:    cobra.MaximumNArgs(1),
+		Example: "  flow drift                        # Check all active workspaces\n  flow drift vpc-ipv6               # Check one workspace\n  flow drift vpc-ipv6 --fix=state   # Record checked-out branches in state.yaml\n  flow drift vpc-ipv6 --fix=checkout",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode := workspace.DriftFix(fix)
+			if mode != "" && mode != workspace.DriftFixState && mode != workspace.DriftFixCheckout {
+				return fmt.Errorf("%w: %q (use state or checkout)", workspace.ErrInvalidDriftFix, fix)
+			}
+			if len(args) == 1 {
+				id, st, err := resolveWorkspace(svc, args[0])
+				if err != nil {
+					return err
+				}
+				_, err = runDrift(cmd.Context(), svc, id, workspaceDisplayName(id, st), mode)
+				return err
+			}
+
+			infos, err := svc.List()
+			if err != nil {
+				return err
+			}
+			drifted := 0
+			for _, info := range infos {
+				if info.Err != nil || info.Archived {
+					continue
+				}
+				name := info.Name
+				if name == "" {
+					name = info.ID
+				}
+				d, err := runDrift(cmd.Context(), svc, info.ID, name, mode)
+				if err != nil {
+					return err
+				}
+				if d {
+					drifted++
+				}
+			}
+			if drifted == 0 {
+				ui.Success("No drift")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&fix, "fix", "", "Resolve drift: state (update state.yaml) or checkout (switch worktrees)")
+	return cmd
+}
+
+// runDrift reports (and with a fix mode, resolves) drift for one workspace.
+// It returns whether drift remains.
+func runDrift(ctx context.Context, svc *workspace.Service, id, name string, mode workspace.DriftFix) (bool, error) {
+	var report *workspace.DriftReport
+	var err error
+	if mode == "" {
+		report, err = svc.Drift(ctx, id)
+	} else {
+		err = ui.RunWithSpinner("Fixing drift: "+name, func(progress func(string)) error {
+			report, err = svc.FixDrift(ctx, id, mode, progress)
+			return err
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !report.Drifted() {
+		if mode != "" {
+			ui.Success("No drift in " + name)
+		}
+		return false, nil
+	}
+
+	ui.Print(name + ":")
+	headers := []string{"REPO", "STATE", "WORKTREE", "DRIFT"}
+	var rows [][]string
+	for _, d := range report.Repos {
+		if !d.Drifted() {
+			continue
+		}
+		worktree := d.Current
+		if d.Kind == workspace.DriftMissing {
+			worktree = "-"
+		}
+		declared := d.Branch
+		if d.Ref != "" {
+			declared = d.Ref
+		}
+		rows = append(rows, []string{d.Path, declared, worktree, d.Summary()})
+	}
+	for _, p := range report.Extra {
+		rows = append(rows, []string{p, "-", "-", "not in state"})
+	}
+	fmt.Println(ui.Table(headers, rows))
+	if mode == "" {
+		ui.Printf("  Fix with %s or %s\n\n", ui.Code("flow drift "+name+" --fix=state"), ui.Code("flow drift "+name+" --fix=checkout"))
+	}
+	return true, nil
+}
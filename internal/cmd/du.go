@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var errInvalidSort = errors.New("invalid sort key")
+
+// duJSON is the --json output of flow du.
+type duJSON struct {
+	Workspaces []duWorkspaceJSON `json:"workspaces"`
+	Repos      []duRepoJSON      `json:"repos"`
+	TotalBytes int64             `json:"totalBytes"`
+}
+
+type duWorkspaceJSON struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Archived bool   `json:"archived"`
+	Repos    int    `json:"repos"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type duRepoJSON struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Bytes      int64  `json:"bytes"`
+	Workspaces int    `json:"workspaces"`
+}
+
+func newDuCmd(svc *workspace.Service) *cobra.Command {
+	var (
+		sortBy string
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "du",
+		Short: "Show disk usage of workspaces and cached repos",
+		Long: `Show the disk space used by each workspace and each bare repo in
+~/.flow/repos/.
+
+Workspace sizes cover the worktree files only; git objects are shared in
+the bare repos and counted there. WORKSPACES is the number of workspaces
+(including deleted ones in the trash) that declare each repo — repos with
+none can be removed with flow gc.`,
+		Args: cobra.NoArgs,
+		Example: `  flow du
+  flow du --sort name
+  flow du --json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if sortBy != "size" && sortBy != "name" {
+				return fmt.Errorf("%w: %q (use size or name)", errInvalidSort, sortBy)
+			}
+
+			usage, err := svc.DiskUsage(cmd.Context())
+			if err != nil {
+				return err
+			}
+			sortUsage(usage, sortBy)
+
+			if asJSON {
+				return printUsageJSON(usage)
+			}
+			printUsage(usage)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&sortBy, "sort", "size", "Sort by size (largest first) or name")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print machine-readable JSON")
+	return cmd
+}
+
+// sortUsage orders workspaces and repos by size (largest first) or name.
+func sortUsage(usage *workspace.DiskUsage, by string) {
+	ws, repos := usage.Workspaces, usage.Repos
+	if by == "name" {
+		sort.SliceStable(ws, func(i, j int) bool { return ws[i].ID < ws[j].ID })
+		sort.SliceStable(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+		return
+	}
+	sort.SliceStable(ws, func(i, j int) bool { return ws[i].Size > ws[j].Size })
+	sort.SliceStable(repos, func(i, j int) bool { return repos[i].Size > repos[j].Size })
+}
+
+func printUsage(usage *workspace.DiskUsage) {
+	if len(usage.Workspaces) > 0 {
+		headers := []string{"WORKSPACE", "NAME", "REPOS", "SIZE"}
+		var rows [][]string
+		for _, w := range usage.Workspaces {
+			name := "-"
+			if w.Name != "" {
+				name = w.Name
+			}
+			if w.Archived {
+				name += " (archived)"
+			}
+			rows = append(rows, []string{w.ID, name, fmt.Sprintf("%d", w.Repos), ui.FormatBytes(w.Size)})
+		}
+		fmt.Println(ui.Table(headers, rows))
+	}
+
+	if len(usage.Repos) > 0 {
+		headers := []string{"REPO", "WORKSPACES", "SIZE"}
+		var rows [][]string
+		for _, r := range usage.Repos {
+			rows = append(rows, []string{r.Name, fmt.Sprintf("%d", r.Workspaces), ui.FormatBytes(r.Size)})
+		}
+		fmt.Println(ui.Table(headers, rows))
+	}
+
+	ui.Printf("  Total: %s\n", ui.FormatBytes(usage.Total()))
+}
+
+func printUsageJSON(usage *workspace.DiskUsage) error {
+	out := duJSON{
+		Workspaces: []duWorkspaceJSON{},
+		Repos:      []duRepoJSON{},
+		TotalBytes: usage.Total(),
+	}
+	for _, w := range usage.Workspaces {
+		out.Workspaces = append(out.Workspaces, duWorkspaceJSON{
+			ID:       w.ID,
+			Name:     w.Name,
+			Archived: w.Archived,
+			Repos:    w.Repos,
+			Bytes:    w.Size,
+		})
+	}
+	for _, r := range usage.Repos {
+		out.Repos = append(out.Repos, duRepoJSON{
+			Name:       r.Name,
+			Path:       r.Path,
+			Bytes:      r.Size,
+			Workspaces: r.Workspaces,
+		})
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
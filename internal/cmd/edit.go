@@ -101,7 +101,7 @@ func editGlobalStatus(cfg *config.Config) error {
 	path := cfg.StatusSpecFile
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		if err := status.Save(path, status.DefaultSpec()); err != nil {
+		if err := status.Create(path, status.DefaultSpec(), cfg.SchemaRef(path, "status")); err != nil {
 			return fmt.Errorf("creating status spec: %w", err)
 		}
 		ui.Success("Created status spec at " + path)
@@ -127,7 +127,7 @@ func editWorkspaceStatus(svc *workspace.Service, cfg *config.Config, idOrName st
 			spec = status.DefaultSpec()
 		}
 
-		if err := status.Save(path, spec); err != nil {
+		if err := status.Create(path, spec, cfg.SchemaRef(path, "status")); err != nil {
 			return fmt.Errorf("creating workspace status spec: %w", err)
 		}
 		ui.Success("Created workspace status spec at " + path)
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+func newGCCmd(svc *workspace.Service) *cobra.Command {
+	var opts workspace.GCOptions
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove unused bare repos from the repo cache",
+		Long: `Remove bare clones in ~/.flow/repos/ that no workspace references, and
+clear stale worktree entries from the rest.
+
+A repo is kept while any workspace — active, archived, or in the trash —
+declares it, or while a worktree checked out from it still exists. An
+unused repo that holds branches backed up by render --force-reset is kept
+too, unless --force is given. Use --git-gc to also run git gc in the kept
+repos.`,
+		Args: cobra.NoArgs,
+		Example: `  flow gc --dry-run   # Show what would be removed
+  flow gc
+  flow gc --git-gc    # Also repack the repos that are kept
+  flow gc --force     # Also remove unused repos holding backup refs`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var report *workspace.GCReport
+			msg := "Collecting repo cache"
+			if opts.DryRun {
+				msg = "Scanning repo cache"
+			}
+			err := ui.RunWithSpinner(msg, func(progress func(string)) error {
+				var err error
+				report, err = svc.GC(cmd.Context(), opts, progress)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			printGCReport(report, opts.DryRun)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show what would be removed without changing anything")
+	cmd.Flags().BoolVar(&opts.GitGC, "git-gc", false, "Run git gc in every repo that is kept")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Remove unused repos even if they hold backup refs")
+	return cmd
+}
+
+// printGCReport lists every cached repo with its size and what gc did.
+func printGCReport(report *workspace.GCReport, dryRun bool) {
+	if len(report.Removed)+len(report.Kept) == 0 {
+		ui.Print("Repo cache is empty.")
+		return
+	}
+
+	removed := "removed"
+	if dryRun {
+		removed = "would remove"
+	}
+
+	headers := []string{"REPO", "SIZE", "ACTION"}
+	var rows [][]string
+	var total int64
+	for _, r := range report.Removed {
+		rows = append(rows, []string{r.Name, ui.FormatBytes(r.Size), removed})
+	}
+	var backups []workspace.CacheRepo
+	for _, r := range report.Kept {
+		action := "kept"
+		switch {
+		case r.Unused():
+			action = fmt.Sprintf("kept: %d backup ref(s)", len(r.Backups))
+		case !r.Referenced:
+			action = fmt.Sprintf("kept: %d worktree(s) not in any state file", len(r.Worktrees))
+		}
+		if len(r.Backups) > 0 {
+			backups = append(backups, r)
+		}
+		rows = append(rows, []string{r.Name, ui.FormatBytes(r.Size), action})
+		total += r.Size
+	}
+	fmt.Println(ui.Table(headers, rows))
+
+	if len(backups) > 0 {
+		ui.Print("Backup refs saved by render --force-reset:")
+		for _, r := range backups {
+			for _, ref := range r.Backups {
+				ui.Printf("  %s  %s\n", r.Name, ref)
+			}
+		}
+		ui.Print("")
+	}
+
+	switch {
+	case dryRun && len(report.Removed) > 0:
+		ui.Info(fmt.Sprintf("Would remove %d unused repo(s), freeing %s", len(report.Removed), ui.FormatBytes(report.Freed)))
+	case len(report.Removed) > 0 || report.Freed > 0:
+		ui.Success(fmt.Sprintf("Removed %d unused repo(s), freed %s", len(report.Removed), ui.FormatBytes(report.Freed)))
+	default:
+		ui.Success("No unused repos")
+	}
+	ui.Printf("  Cache in use: %s across %d repo(s)\n", ui.FormatBytes(total), len(report.Kept))
+}
@@ -5,7 +5,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/milldr/flow/internal/state"
 	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
 )
 
 func TestTruncate(t *testing.T) {
@@ -57,3 +59,48 @@ func TestRelativeTime(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseAge(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAge(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAge(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteRepos(t *testing.T) {
+	st := state.NewState("ws", "", []state.Repo{
+		{URL: "github.com/org/app", Branch: "feat/x"},
+		{URL: "github.com/org/sdk", Ref: "v1.2.0"},
+	})
+	risks := []workspace.RepoRisk{{Path: "old", Branch: "HEAD", Dirty: true, Stray: true}}
+
+	var got []string
+	for _, r := range deleteRepos(st, risks) {
+		got = append(got, r.Path+"@"+r.Branch)
+	}
+	want := "app@feat/x sdk@v1.2.0 old@(detached)"
+	if strings.Join(got, " ") != want {
+		t.Errorf("deleteRepos = %v, want %s", got, want)
+	}
+}
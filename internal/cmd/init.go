@@ -2,33 +2,52 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 
 	"github.com/milldr/flow/internal/iterm"
 	"github.com/milldr/flow/internal/state"
+	"github.com/milldr/flow/internal/template"
 	"github.com/milldr/flow/internal/ui"
 	"github.com/milldr/flow/internal/workspace"
 	"github.com/spf13/cobra"
 )
 
+// errBranchWithoutTemplate is returned when --branch is passed without --template.
+var errBranchWithoutTemplate = errors.New("--branch requires --template")
+
 func newInitCmd(svc *workspace.Service) *cobra.Command {
 	var noExec bool
+	var tmplName string
+	var branch string
+	var render bool
 
 	cmd := &cobra.Command{
 		Use:   "init [name]",
 		Short: "Create a new empty workspace",
-		Long:  "Create a new empty workspace with a generated ID.\nOptionally provide a human-friendly name as the first argument.\nBy default, launches the configured agent in the new workspace.",
-		Args:  cobra.MaximumNArgs(1),
+		Long: `Create a new empty workspace with a generated ID.
+Optionally provide a human-friendly name as the first argument.
+By default, launches the configured agent in the new workspace.
+
+With --template, the workspace's repos are populated from a saved template
+(see flow template). ${name} and ${branch} placeholders are replaced with the
+workspace name and the --branch value.`,
+		Args: cobra.MaximumNArgs(1),
 		Example: `  flow init
   flow init my-project
-  flow init my-project --no-exec`,
-		RunE: func(_ *cobra.Command, args []string) error {
+  flow init my-project --no-exec
+  flow init my-feature --template platform --branch feat/x --render`,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			var name string
 			if len(args) > 0 {
 				name = args[0]
 			}
 
+			if branch != "" && tmplName == "" {
+				return errBranchWithoutTemplate
+			}
+
 			// Collect existing IDs to avoid collisions
 			infos, err := svc.List()
 			if err != nil {
@@ -42,6 +61,13 @@ func newInitCmd(svc *workspace.Service) *cobra.Command {
 			id := workspace.GenerateUniqueID(existingIDs)
 			st := state.NewState(name, "", nil)
 
+			if tmplName != "" {
+				st, err = stateFromTemplate(svc, tmplName, id, name, branch)
+				if err != nil {
+					return err
+				}
+			}
+
 			err = ui.RunWithSpinner("Creating workspace", func(_ func(string)) error {
 				return svc.Create(id, st)
 			})
@@ -56,6 +82,16 @@ func newInitCmd(svc *workspace.Service) *cobra.Command {
 			ui.Print("")
 			ui.Success("Created workspace " + label)
 
+			if render {
+				err = ui.RunWithSpinner("Rendering workspace: "+workspaceDisplayName(id, st), func(report func(string)) error {
+					return svc.Render(cmd.Context(), id, report, nil)
+				})
+				if err != nil {
+					return err
+				}
+				ui.Success("Workspace ready")
+			}
+
 			// Try to launch the configured agent unless --no-exec was passed.
 			if !noExec {
 				cmdArgs, agentErr := resolveAgentCmd(svc)
@@ -86,13 +122,53 @@ func newInitCmd(svc *workspace.Service) *cobra.Command {
 			if name != "" {
 				ref = name
 			}
-			ui.Printf("\n  Next: %s\n", ui.Code("flow edit state "+ref))
+			next := "flow edit state " + ref
+			if render {
+				next = fmt.Sprintf("flow exec %s -- <command>", ref)
+			}
+			ui.Printf("\n  Next: %s\n", ui.Code(next))
 
 			return nil
 		},
 	}
 
 	cmd.Flags().BoolVar(&noExec, "no-exec", false, "skip launching the agent after creation")
+	cmd.Flags().StringVarP(&tmplName, "template", "t", "", "create the workspace from a saved template")
+	cmd.Flags().StringVarP(&branch, "branch", "b", "", "branch substituted for ${branch} in the template")
+	cmd.Flags().BoolVar(&render, "render", false, "render the workspace immediately after creation")
 
 	return cmd
 }
+
+// stateFromTemplate loads a named template and instantiates it for a new workspace.
+// ${name} resolves to the workspace name, falling back to the generated ID.
+func stateFromTemplate(svc *workspace.Service, tmplName, id, name, branch string) (*state.State, error) {
+	if err := template.ValidateName(tmplName); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.Load(svc.Config.TemplatePath(tmplName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s\n  Hint: run `flow template list` to see available templates", ErrTemplateNotFound, tmplName)
+		}
+		return nil, err
+	}
+	if err := template.Validate(tmpl); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", tmplName, err)
+	}
+
+	vars := template.Vars{Name: name, Branch: branch}
+	if vars.Name == "" {
+		vars.Name = id
+	}
+
+	st, err := tmpl.NewState(name, vars)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", tmplName, err)
+	}
+	if err := state.Validate(st); err != nil {
+		return nil, fmt.Errorf("template %s produced an invalid state: %w", tmplName, err)
+	}
+	return st, nil
+}
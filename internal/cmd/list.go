@@ -37,7 +37,14 @@ func newListCmd(svc *workspace.Service) *cobra.Command {
 
 			headers := []string{"ID", "NAME", "DESCRIPTION", "REPOS", "CREATED"}
 			var rows [][]string
+			broken := 0
 			for _, info := range infos {
+				if info.Err != nil {
+					broken++
+					rows = append(rows, []string{info.ID, "-", "invalid state.yaml", "-", "-"})
+					continue
+				}
+
 				displayName := "-"
 				if info.Name != "" {
 					displayName = info.Name
@@ -62,6 +69,9 @@ func newListCmd(svc *workspace.Service) *cobra.Command {
 			}
 
 			fmt.Println(ui.Table(headers, rows))
+			if broken > 0 {
+				ui.Warning(fmt.Sprintf("%d workspace(s) have an invalid state file. Run %s for details.", broken, ui.Code("flow validate --all")))
+			}
 			return nil
 		},
 	}
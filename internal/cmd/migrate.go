@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/milldr/flow/internal/config"
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var errMigrateFailed = errors.New("migration failed")
+
+func newMigrateCmd(svc *workspace.Service, cfg *config.Config) *cobra.Command {
+	var all, dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate [workspace]",
+		Short: "Upgrade flow's files to the current format",
+		Long: `Upgrade files written by older versions of flow to the current format
+(apiVersion ` + migrate.CurrentVersion + `) and rewrite them on disk.
+
+Flow already reads older files, upgrading them in memory, and moves
+` + "`~/.flow`" + ` to its current directory layout on startup; this command
+makes the upgrades permanent. It always migrates the directory layout, the
+global config and the global status spec. Pass a workspace to also migrate
+its state.yaml and status.yaml, or --all for every workspace.
+
+Use --dry-run to list what would change without writing anything.`,
+		Args:    cobra.MaximumNArgs(1),
+		Example: "  flow migrate --all --dry-run   # Show what would change\n  flow migrate --all             # Migrate everything\n  flow migrate vpc-ipv6          # Migrate one workspace and the global files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var ids []string
+			switch {
+			case all:
+				infos, err := svc.List()
+				if err != nil {
+					return err
+				}
+				for _, info := range infos {
+					ids = append(ids, info.ID)
+				}
+			case len(args) == 1:
+				id, err := validateTarget(svc, cfg, args[0])
+				if err != nil {
+					return err
+				}
+				ids = []string{id}
+			}
+
+			changed := 0
+			if dryRun {
+				pending, err := svc.PendingLayoutMigrations()
+				if err != nil {
+					return err
+				}
+				for _, step := range pending {
+					ui.Info("Would migrate flow home: " + step)
+				}
+				changed += len(pending)
+			} else {
+				done, err := svc.MigrateHome(cmd.Context(), ui.Warning)
+				if err != nil {
+					return err
+				}
+				for _, step := range done {
+					ui.Success("Migrated flow home: " + step)
+				}
+				changed += len(done)
+			}
+
+			report := func(migrated []workspace.FileMigration) {
+				for _, m := range migrated {
+					rel, err := filepath.Rel(cfg.Home, m.Path)
+					if err != nil {
+						rel = m.Path
+					}
+					if dryRun {
+						ui.Info(fmt.Sprintf("Would migrate %s from %s to %s", rel, m.From, migrate.CurrentVersion))
+					} else {
+						ui.Success(fmt.Sprintf("Migrated %s from %s to %s", rel, m.From, migrate.CurrentVersion))
+					}
+				}
+				changed += len(migrated)
+			}
+
+			failed := 0
+			migrated, err := svc.MigrateConfig(dryRun)
+			report(migrated)
+			if err != nil {
+				ui.Error(fmt.Sprintf("global files: %v", err))
+				failed++
+			}
+			for _, id := range ids {
+				migrated, err := svc.MigrateWorkspace(cmd.Context(), id, dryRun)
+				report(migrated)
+				if err != nil {
+					ui.Error(fmt.Sprintf("%s: %v", id, err))
+					failed++
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%w: %d error(s)", errMigrateFailed, failed)
+			}
+			if changed == 0 {
+				ui.Success("Everything is up to date")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Also migrate every workspace")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would change without writing anything")
+	return cmd
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/milldr/flow/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// offlineEnv turns on --offline for render, sync and status when set to a
+// true value (1, true, ...).
+const offlineEnv = "FLOW_OFFLINE"
+
+// addOfflineFlag registers --offline, defaulting to $FLOW_OFFLINE.
+func addOfflineFlag(cmd *cobra.Command, offline *bool) {
+	def, _ := strconv.ParseBool(os.Getenv(offlineEnv))
+	cmd.Flags().BoolVar(offline, "offline", def, "Skip network access and use the local repo cache (default $"+offlineEnv+")")
+}
+
+// printOfflineNote tells the user which network steps are skipped.
+func printOfflineNote(skipped string) {
+	ui.Info("Offline: " + skipped)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/milldr/flow/internal/ui"
 	"github.com/milldr/flow/internal/workspace"
@@ -10,12 +11,18 @@ import (
 
 func newRenderCmd(svc *workspace.Service) *cobra.Command {
 	var reset bool
+	var prune bool
+	var plan bool
+	var forceReset bool
+	var rebase bool
+	var offline bool
+	var updatePins bool
 
 	cmd := &cobra.Command{
 		Use:     "render <workspace>",
 		Short:   "Create worktrees from workspace state file",
 		Args:    cobra.ExactArgs(1),
-		Example: "  flow render calm-delta\n  flow render calm-delta --reset=false   # Use existing remote branches instead of creating fresh",
+		Example: "  flow render calm-delta\n  flow render calm-delta --reset=false   # Use existing remote branches instead of creating fresh\n  flow render calm-delta --prune         # Remove worktrees for repos dropped from state\n  flow render calm-delta --plan          # Show what render would do without changing anything\n  flow render calm-delta --force-reset   # Reset branches even if they have unpushed commits (backed up first)\n  flow render calm-delta --rebase        # Rebase rendered repos whose base changed in state\n  flow render calm-delta --update-pins   # Move pinned repos to where their tags point now\n  flow render calm-delta --offline       # Render from the local repo cache without fetching",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id, st, err := resolveWorkspace(svc, args[0])
 			if err != nil {
@@ -24,16 +31,40 @@ func newRenderCmd(svc *workspace.Service) *cobra.Command {
 
 			name := workspaceDisplayName(id, st)
 
-			opts := &workspace.RenderOptions{}
+			svc.Offline = offline
+			if offline {
+				printOfflineNote("cloning and fetching are skipped; using the local repo cache")
+			}
+
+			var warnings []string
+			opts := &workspace.RenderOptions{
+				Prune:      prune,
+				ForceReset: forceReset,
+				Rebase:     rebase,
+				UpdatePins: updatePins,
+				Warn:       func(msg string) { warnings = append(warnings, msg) },
+			}
 			if reset {
 				opts.OnBranchConflict = workspace.BranchConflictReset
 			} else {
 				opts.OnBranchConflict = workspace.BranchConflictUseExisting
 			}
 
+			if plan {
+				p, err := svc.Plan(cmd.Context(), id, opts)
+				if err != nil {
+					return err
+				}
+				printRenderPlan(name, p)
+				return nil
+			}
+
 			err = ui.RunWithSpinner("Rendering workspace: "+name, func(report func(string)) error {
 				return svc.Render(cmd.Context(), id, report, opts)
 			})
+			for _, w := range warnings {
+				ui.Warning(w)
+			}
 			if err != nil {
 				return err
 			}
@@ -52,5 +83,99 @@ func newRenderCmd(svc *workspace.Service) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&reset, "reset", true, "Reset existing branches to fresh state from default branch")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Remove worktrees no longer in the state file (keeps dirty or unpushed ones)")
+	cmd.Flags().BoolVar(&forceReset, "force-reset", false, "Reset branches with unpushed commits, saving the old tip under refs/flow/backup/")
+	cmd.Flags().BoolVar(&rebase, "rebase", false, "Rebase rendered worktrees whose base changed in the state file")
+	cmd.Flags().BoolVar(&updatePins, "update-pins", false, "Move clean pinned worktrees to where their refs resolve now and record the new commits")
+	cmd.Flags().BoolVar(&plan, "plan", false, "Show what render would do for each repo without changing anything")
+	addOfflineFlag(cmd, &offline)
 	return cmd
 }
+
+// printRenderPlan prints one row per repo followed by warnings and errors.
+func printRenderPlan(name string, p *workspace.RenderPlan) {
+	ui.Print("Render plan for " + name + ":")
+	ui.Print("")
+
+	headers := []string{"REPO", "BRANCH", "ACTION", "DETAILS"}
+	var rows [][]string
+	for _, rp := range p.Repos {
+		branch := rp.Branch
+		if rp.Ref != "" {
+			branch = rp.Ref
+		}
+		rows = append(rows, []string{rp.Path, branch, string(rp.Action), planDetails(rp)})
+	}
+	for _, wt := range p.Prune {
+		action, details := "prune", "remove worktree"
+		if !wt.Safe() {
+			action, details = "keep", wt.Reason()
+		}
+		rows = append(rows, []string{wt.Path, wt.Branch, action, details})
+	}
+	fmt.Println(ui.Table(headers, rows))
+
+	var printed bool
+	for _, rp := range p.Repos {
+		for _, w := range rp.Warnings {
+			if !printed {
+				ui.Print("")
+				printed = true
+			}
+			ui.Warning(rp.Path + ": " + w)
+		}
+		if rp.Err != nil {
+			if !printed {
+				ui.Print("")
+				printed = true
+			}
+			ui.Error(rp.Path + ": " + rp.Err.Error())
+		}
+	}
+}
+
+// planDetails describes the fetch and branch steps of a repo plan.
+func planDetails(rp workspace.RepoPlan) string {
+	var steps []string
+	switch {
+	case rp.Clone:
+		steps = append(steps, "clone", "fetch")
+	case rp.Fetch:
+		steps = append(steps, "fetch")
+	}
+
+	base := "default branch"
+	if rp.Base != "" {
+		base = "origin/" + rp.Base
+	}
+
+	switch rp.Action {
+	case workspace.ActionSkip:
+		steps = append(steps, "worktree exists")
+	case workspace.ActionCreateBranch:
+		steps = append(steps, "new branch from "+base)
+	case workspace.ActionResetBranch:
+		step := "hard reset to " + base
+		if rp.Ahead > 0 {
+			step += fmt.Sprintf(" (discards %d commit(s))", rp.Ahead)
+		}
+		steps = append(steps, step)
+	case workspace.ActionUseExisting:
+		steps = append(steps, "check out existing branch")
+	case workspace.ActionSwitchBranch:
+		step := "switch from " + rp.Current
+		if rp.NewBranch {
+			step += ", new branch from " + base
+		}
+		steps = append(steps, step)
+	case workspace.ActionRebase:
+		steps = append(steps, "rebase onto "+base+" (was "+rp.PrevBase+")")
+	case workspace.ActionPin:
+		step := "check out detached"
+		if rp.Commit != "" {
+			step += " at " + workspace.ShortSHA(rp.Commit)
+		}
+		steps = append(steps, step)
+	}
+	return strings.Join(steps, ", ")
+}
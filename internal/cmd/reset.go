@@ -48,7 +48,7 @@ func newResetStatusCmd(cfg *config.Config) *cobra.Command {
 				}
 			}
 
-			if err := status.Save(path, status.DefaultSpec()); err != nil {
+			if err := status.Create(path, status.DefaultSpec(), cfg.SchemaRef(path, "status")); err != nil {
 				return fmt.Errorf("resetting status spec: %w", err)
 			}
 
@@ -82,7 +82,7 @@ func newResetConfigCmd(cfg *config.Config) *cobra.Command {
 				}
 			}
 
-			if err := config.SaveFlowConfig(path, config.DefaultFlowConfig()); err != nil {
+			if err := config.CreateFlowConfig(path, config.DefaultFlowConfig(), cfg.SchemaRef(path, "config")); err != nil {
 				return fmt.Errorf("resetting config: %w", err)
 			}
 
@@ -123,7 +123,7 @@ func newResetStateCmd(svc *workspace.Service, cfg *config.Config) *cobra.Command
 			}
 
 			newSt := state.NewState(st.Metadata.Name, st.Metadata.Description, nil)
-			if err := state.Save(path, newSt); err != nil {
+			if err := state.Create(path, newSt, cfg.SchemaRef(path, "state")); err != nil {
 				return fmt.Errorf("resetting state: %w", err)
 			}
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -67,14 +68,14 @@ func newRootCmd() *cobra.Command {
 		os.Exit(1)
 	}
 
-	gitRunner := &git.RealRunner{}
+	gitRunner := &git.RealRunner{URLs: cfg.FlowConfig.Spec.Git.Rules()}
 	svc := &workspace.Service{
 		Config: cfg,
 		Git:    gitRunner,
 	}
 
 	// Wire the logger after flags are parsed.
-	root.PersistentPreRun = func(_ *cobra.Command, _ []string) {
+	root.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
 		if verbose {
 			ui.SetPlain(true)
 		}
@@ -82,6 +83,23 @@ func newRootCmd() *cobra.Command {
 		gitRunner.Log = log
 		svc.Log = log
 		log.Debug("flow starting", "version", version, "flow_home", cfg.Home)
+
+		// Bring ~/.flow up to the current layout. Failures are retried on
+		// the next run and don't block the command, but a layout from a
+		// newer flow does: this one could damage it. flow migrate does this
+		// itself, so --dry-run can leave it alone, and flow version runs
+		// anyway to show which flow is installed.
+		switch cmd.Name() {
+		case "migrate", "version":
+			return nil
+		}
+		if _, err := svc.MigrateHome(cmd.Context(), ui.Warning); err != nil {
+			if errors.Is(err, workspace.ErrHomeTooNew) {
+				return err
+			}
+			ui.Warning(fmt.Sprintf("migrating flow home: %v", err))
+		}
+		return nil
 	}
 
 	// Register commands
@@ -94,9 +112,18 @@ func newRootCmd() *cobra.Command {
 	root.AddCommand(newExecCmd(svc))
 	root.AddCommand(newOpenCmd(svc))
 	root.AddCommand(newDeleteCmd(svc))
+	root.AddCommand(newTrashCmd(svc))
+	root.AddCommand(newGCCmd(svc))
+	root.AddCommand(newDuCmd(svc))
 	root.AddCommand(newArchiveCmd(svc, cfg))
+	root.AddCommand(newUnarchiveCmd(svc))
 	root.AddCommand(newResetCmd(svc, cfg))
 	root.AddCommand(newSyncCmd(svc))
+	root.AddCommand(newDriftCmd(svc))
+	root.AddCommand(newValidateCmd(svc, cfg))
+	root.AddCommand(newSchemaCmd())
+	root.AddCommand(newMigrateCmd(svc, cfg))
+	root.AddCommand(newTemplateCmd(svc, cfg))
 
 	return root
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/milldr/flow/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var errUnknownSchemaKind = errors.New("unknown schema kind")
+
+func newSchemaCmd() *cobra.Command {
+	schemas := config.Schemas()
+	kinds := make([]string, 0, len(schemas))
+	for kind := range schemas {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+
+	return &cobra.Command{
+		Use:   "schema <kind>",
+		Short: "Print the JSON Schema for a state, status or config file",
+		Long: `Print the JSON Schema for one of flow's files: state (a workspace's
+state.yaml), status (status.yaml) or config (config.yaml).
+
+The schemas are generated from the same types flow reads the files into,
+and flow validate checks state files against the state schema. Flow also
+keeps a copy of each in ` + "`~/.flow/schemas/`" + `, and files it creates start
+with a yaml-language-server comment pointing at them, so editors with YAML
+language support complete and check fields as you type.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: kinds,
+		Example:   "  flow schema state\n  flow schema config > config.schema.json",
+		RunE: func(_ *cobra.Command, args []string) error {
+			s, ok := schemas[args[0]]
+			if !ok {
+				return fmt.Errorf("%w %q (use %s)", errUnknownSchemaKind, args[0], strings.Join(kinds, ", "))
+			}
+			data, err := config.MarshalSchema(s)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(data))
+			return nil
+		},
+	}
+}
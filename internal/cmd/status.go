@@ -20,6 +20,7 @@ import (
 
 func newStatusCmd(svc *workspace.Service, cfg *config.Config) *cobra.Command {
 	var showAll bool
+	var offline bool
 
 	cmd := &cobra.Command{
 		Use:   "status [workspace]",
@@ -30,20 +31,25 @@ Without arguments, shows all workspaces with their statuses.
 Archived workspaces are hidden by default; use --all to include them.
 With a workspace argument, shows a detailed per-repo status breakdown.`,
 		Args:    cobra.MaximumNArgs(1),
-		Example: "  flow status                  # Show all workspace statuses\n  flow status --all             # Include archived workspaces\n  flow status vpc-ipv6          # Show per-repo breakdown",
+		Example: "  flow status                  # Show all workspace statuses\n  flow status --all             # Include archived workspaces\n  flow status vpc-ipv6          # Show per-repo breakdown\n  flow status --offline         # Skip network checks (gh, ls-remote)",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver := &status.Resolver{Runner: &status.ShellRunner{}, Offline: offline}
+			if offline {
+				printOfflineNote("checks run with " + offlineEnv + "=1; network checks in the default spec are skipped")
+			}
 			if len(args) == 0 {
-				return runStatusAll(cmd.Context(), svc, cfg, showAll)
+				return runStatusAll(cmd.Context(), svc, cfg, resolver, showAll)
 			}
-			return runStatusWorkspace(cmd.Context(), svc, cfg, args[0])
+			return runStatusWorkspace(cmd.Context(), svc, cfg, resolver, args[0])
 		},
 	}
 
 	cmd.Flags().BoolVarP(&showAll, "all", "a", false, "Include archived workspaces")
+	addOfflineFlag(cmd, &offline)
 	return cmd
 }
 
-func runStatusAll(ctx context.Context, svc *workspace.Service, cfg *config.Config, showAll bool) error {
+func runStatusAll(ctx context.Context, svc *workspace.Service, cfg *config.Config, resolver *status.Resolver, showAll bool) error {
 	allInfos, err := svc.List()
 	if err != nil {
 		return err
@@ -52,7 +58,7 @@ func runStatusAll(ctx context.Context, svc *workspace.Service, cfg *config.Confi
 	// Filter out archived workspaces unless --all is set.
 	var infos []workspace.Info
 	for _, info := range allInfos {
-		if !showAll && info.Archived {
+		if info.Err != nil || (!showAll && info.Archived) {
 			continue
 		}
 		infos = append(infos, info)
@@ -98,8 +104,6 @@ func runStatusAll(ctx context.Context, svc *workspace.Service, cfg *config.Confi
 		Colors: globalSpec.ColorMap(),
 	}
 
-	resolver := &status.Resolver{Runner: &status.ShellRunner{}}
-
 	// Track errors from resolution goroutines.
 	var resolveErr error
 	var errOnce sync.Once
@@ -166,7 +170,7 @@ func runStatusAll(ctx context.Context, svc *workspace.Service, cfg *config.Confi
 	return err
 }
 
-func runStatusWorkspace(ctx context.Context, svc *workspace.Service, cfg *config.Config, idOrName string) error {
+func runStatusWorkspace(ctx context.Context, svc *workspace.Service, cfg *config.Config, resolver *status.Resolver, idOrName string) error {
 	id, st, err := resolveWorkspace(svc, idOrName)
 	if err != nil {
 		return err
@@ -189,7 +193,6 @@ func runStatusWorkspace(ctx context.Context, svc *workspace.Service, cfg *config
 
 	var result *status.WorkspaceResult
 	err = ui.RunWithSpinner("Resolving status for "+wsName+"...", func(_ func(string)) error {
-		resolver := &status.Resolver{Runner: &status.ShellRunner{}}
 		result = resolver.ResolveWorkspace(ctx, spec, repos, id, wsName)
 		return nil
 	})
@@ -204,17 +207,29 @@ func runStatusWorkspace(ctx context.Context, svc *workspace.Service, cfg *config
 	colorMap := spec.ColorMap()
 	ui.Printf("Status: %s  (%s)\n", ui.StatusStyle(result.Status, colorMap), ui.FormatDuration(result.Duration.Milliseconds()))
 
+	// Drift only reads local git state; a failure just leaves the column blank.
+	drift, _ := svc.Drift(ctx, id)
+
 	if len(result.Repos) > 0 {
-		headers := []string{"REPO", "BRANCH", "STATUS", "UPDATED", "TIME"}
+		headers := []string{"REPO", "BRANCH", "DRIFT", "STATUS", "UPDATED", "TIME"}
 		var rows [][]string
-		for _, r := range result.Repos {
+		for i, r := range result.Repos {
 			updated := "-"
 			if !r.LastCommit.IsZero() {
 				updated = ui.RelativeTime(r.LastCommit)
 			}
+			driftCol := "-"
+			if drift != nil && i < len(drift.Repos) {
+				driftCol = drift.Repos[i].Summary()
+			}
+			branch := r.Branch
+			if r.Ref != "" {
+				branch = r.Ref
+			}
 			rows = append(rows, []string{
 				status.RepoSlug(r.URL),
-				r.Branch,
+				branch,
+				driftCol,
 				ui.StatusStyle(r.Status, colorMap),
 				updated,
 				ui.FormatDuration(r.Duration.Milliseconds()),
@@ -223,6 +238,10 @@ func runStatusWorkspace(ctx context.Context, svc *workspace.Service, cfg *config
 		fmt.Println(ui.Table(headers, rows))
 	}
 
+	if drift != nil && len(drift.Extra) > 0 {
+		ui.Warning(fmt.Sprintf("%d worktree(s) not in state; see %s", len(drift.Extra), ui.Code("flow drift "+wsName)))
+	}
+
 	return nil
 }
 
@@ -234,6 +253,7 @@ func stateReposToInfo(st *state.State, wsDir string) []status.RepoInfo {
 		repos[i] = status.RepoInfo{
 			URL:    r.URL,
 			Branch: r.Branch,
+			Ref:    r.Ref,
 			Path:   filepath.Join(wsDir, state.RepoPath(r)),
 		}
 	}
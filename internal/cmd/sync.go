@@ -7,11 +7,14 @@ import (
 )
 
 func newSyncCmd(svc *workspace.Service) *cobra.Command {
-	return &cobra.Command{
-		Use:     "sync <workspace>",
-		Short:   "Fetch and rebase worktrees onto their base branches",
-		Args:    cobra.ExactArgs(1),
-		Example: `  flow sync calm-delta`,
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "sync <workspace>",
+		Short: "Fetch and rebase worktrees onto their base branches",
+		Args:  cobra.ExactArgs(1),
+		Example: `  flow sync calm-delta
+  flow sync calm-delta --offline   # Rebase onto the cached base without fetching`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id, st, err := resolveWorkspace(svc, args[0])
 			if err != nil {
@@ -20,6 +23,11 @@ func newSyncCmd(svc *workspace.Service) *cobra.Command {
 
 			name := workspaceDisplayName(id, st)
 
+			svc.Offline = offline
+			if offline {
+				printOfflineNote("fetching is skipped; rebasing onto the cached base branches")
+			}
+
 			err = ui.RunWithSpinner("Syncing workspace: "+name, func(report func(string)) error {
 				return svc.Sync(cmd.Context(), id, report)
 			})
@@ -32,4 +40,7 @@ func newSyncCmd(svc *workspace.Service) *cobra.Command {
 			return nil
 		},
 	}
+
+	addOfflineFlag(cmd, &offline)
+	return cmd
 }
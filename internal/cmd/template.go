@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/milldr/flow/internal/config"
+	"github.com/milldr/flow/internal/template"
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+// Template command errors.
+var (
+	ErrTemplateExists   = errors.New("template already exists")
+	ErrTemplateNotFound = errors.New("template not found")
+)
+
+func newTemplateCmd(svc *workspace.Service, cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "template",
+		Short: "Manage workspace templates",
+		Long: `Manage reusable workspace templates stored in ~/.flow/templates/.
+
+A template is a state skeleton whose fields may contain ${name} and ${branch}
+placeholders. Create a workspace from one with flow init --template.`,
+	}
+
+	cmd.AddCommand(newTemplateSaveCmd(svc, cfg))
+	cmd.AddCommand(newTemplateListCmd(cfg))
+
+	return cmd
+}
+
+func newTemplateSaveCmd(svc *workspace.Service, cfg *config.Config) *cobra.Command {
+	var name string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "save <workspace>",
+		Short: "Save a workspace's repos as a template",
+		Long: `Save a workspace's repos as a template.
+
+Each repo's branch is replaced with the ${branch} placeholder; URLs, bases,
+and paths are kept. The template name defaults to the workspace name.`,
+		Args:    cobra.ExactArgs(1),
+		Example: "  flow template save vpc-ipv6\n  flow template save vpc-ipv6 --name platform --force",
+		RunE: func(_ *cobra.Command, args []string) error {
+			id, st, err := resolveWorkspace(svc, args[0])
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				name = workspaceDisplayName(id, st)
+			}
+			if err := template.ValidateName(name); err != nil {
+				return err
+			}
+
+			tmpl := template.FromState(name, st)
+			if err := template.Validate(tmpl); err != nil {
+				return fmt.Errorf("invalid template: %w", err)
+			}
+
+			path := cfg.TemplatePath(name)
+			if _, err := os.Stat(path); err == nil && !force {
+				return fmt.Errorf("%w: %s (use --force to overwrite)", ErrTemplateExists, name)
+			}
+
+			if err := template.Save(path, tmpl); err != nil {
+				return fmt.Errorf("saving template: %w", err)
+			}
+
+			ui.Success(fmt.Sprintf("Saved template %s (%d repos)", name, len(tmpl.Spec.Repos)))
+			ui.Printf("\n  Next: %s\n", ui.Code("flow init <name> --template "+name+" --branch <branch>"))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Template name (defaults to the workspace name)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite an existing template")
+	return cmd
+}
+
+func newTemplateListCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List workspace templates",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			names, err := template.List(cfg.TemplatesDir)
+			if err != nil {
+				return err
+			}
+
+			if len(names) == 0 {
+				ui.Print("No templates found. Run " + ui.Code("flow template save <workspace>") + " to create one.")
+				return nil
+			}
+
+			headers := []string{"NAME", "DESCRIPTION", "REPOS"}
+			var rows [][]string
+			for _, n := range names {
+				desc, repos := "-", "-"
+				if tmpl, err := template.Load(cfg.TemplatePath(n)); err == nil {
+					if tmpl.Metadata.Description != "" {
+						desc = ui.Truncate(tmpl.Metadata.Description, 40)
+					}
+					repos = fmt.Sprintf("%d", len(tmpl.Spec.Repos))
+				}
+				rows = append(rows, []string{n, desc, repos})
+			}
+
+			fmt.Println(ui.Table(headers, rows))
+			return nil
+		},
+	}
+}
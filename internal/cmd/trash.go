@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var errInvalidAge = errors.New("invalid age")
+
+func newTrashCmd(svc *workspace.Service) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "List, restore, or empty deleted workspaces",
+		Long: `Manage deleted workspaces held in ~/.flow/trash/.
+
+flow delete moves a workspace's state, generated files, and any saved
+uncommitted changes or unpushed commits here. Restore brings the workspace
+back and re-renders its worktrees from their existing branches.`,
+	}
+
+	cmd.AddCommand(newTrashListCmd(svc))
+	cmd.AddCommand(newTrashRestoreCmd(svc))
+	cmd.AddCommand(newTrashEmptyCmd(svc))
+
+	return cmd
+}
+
+func newTrashListCmd(svc *workspace.Service) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List deleted workspaces",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			trash, err := svc.ListTrash()
+			if err != nil {
+				return err
+			}
+
+			if len(trash) == 0 {
+				ui.Print("Trash is empty.")
+				return nil
+			}
+
+			headers := []string{"ID", "NAME", "REPOS", "DELETED"}
+			var rows [][]string
+			for _, e := range trash {
+				name, repos := "-", "-"
+				if e.State != nil {
+					if e.State.Metadata.Name != "" {
+						name = e.State.Metadata.Name
+					}
+					repos = fmt.Sprintf("%d", len(e.State.Spec.Repos))
+				}
+				rows = append(rows, []string{e.Name, name, repos, ui.RelativeTime(e.Deleted)})
+			}
+
+			fmt.Println(ui.Table(headers, rows))
+			return nil
+		},
+	}
+}
+
+func newTrashRestoreCmd(svc *workspace.Service) *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore <id>",
+		Short: "Restore a deleted workspace",
+		Long: `Restore a deleted workspace and re-render its worktrees from their
+existing branches. Uncommitted changes and unpushed commits saved when it
+was deleted are reapplied.
+
+<id> is a workspace ID (the most recent deletion is restored) or a trash
+entry from flow trash list.`,
+		Args:    cobra.ExactArgs(1),
+		Example: "  flow trash restore vpc-ipv6\n  flow trash restore vpc-ipv6-20260101-120000",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entry, err := svc.FindTrash(args[0])
+			if err != nil {
+				return err
+			}
+
+			name := entry.ID
+			if entry.State != nil {
+				name = workspaceDisplayName(entry.ID, entry.State)
+			}
+
+			var warnings []string
+			err = ui.RunWithSpinner("Restoring workspace: "+name, func(report func(string)) error {
+				return svc.RestoreTrash(cmd.Context(), entry, report, func(msg string) {
+					warnings = append(warnings, msg)
+				})
+			})
+
+			failed, err := splitRepoErrors(err)
+			if err != nil {
+				return err
+			}
+
+			st, err := svc.Find(entry.ID)
+			if err != nil {
+				return err
+			}
+			if !st.Metadata.Archived {
+				printRestoreReport(st, failed)
+			}
+			for _, w := range warnings {
+				ui.Warning(w)
+			}
+
+			ui.Print("")
+			if len(failed) > 0 {
+				ui.Warning(fmt.Sprintf("Restored workspace %s with %d of %d repos missing", name, len(failed), len(st.Spec.Repos)))
+				return nil
+			}
+			ui.Success("Restored workspace: " + name)
+			return nil
+		},
+	}
+}
+
+func newTrashEmptyCmd(svc *workspace.Service) *cobra.Command {
+	var olderThan string
+
+	cmd := &cobra.Command{
+		Use:   "empty",
+		Short: "Permanently remove deleted workspaces",
+		Args:  cobra.NoArgs,
+		Example: `  flow trash empty                   # Remove everything in the trash
+  flow trash empty --older-than 30d  # Remove workspaces deleted over 30 days ago`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			var age time.Duration
+			if olderThan != "" {
+				var err error
+				if age, err = parseAge(olderThan); err != nil {
+					return err
+				}
+			}
+
+			removed, err := svc.EmptyTrash(age)
+			if err != nil {
+				return err
+			}
+
+			if len(removed) == 0 {
+				ui.Print("Nothing to remove.")
+				return nil
+			}
+			ui.Success(fmt.Sprintf("Removed %d deleted workspace(s)", len(removed)))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Only remove workspaces deleted longer ago than this (e.g. 30d, 12h)")
+	return cmd
+}
+
+// parseAge parses a duration that may use a "d" (days) suffix in addition
+// to the units time.ParseDuration accepts.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%w: %q (use e.g. 30d or 12h)", errInvalidAge, s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %q (use e.g. 30d or 12h)", errInvalidAge, s)
+	}
+	return d, nil
+}
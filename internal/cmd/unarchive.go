@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/milldr/flow/internal/state"
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+func newUnarchiveCmd(svc *workspace.Service) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unarchive <workspace>",
+		Short: "Restore an archived workspace",
+		Long: `Restore an archived workspace by clearing the archived flag and
+re-creating its worktrees from their existing branches.
+
+Branches are restored from the local bare cache or the remote. Repos whose
+branch no longer exists in either are reported and skipped.`,
+		Args:    cobra.ExactArgs(1),
+		Example: "  flow unarchive vpc-ipv6",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, st, err := resolveWorkspace(svc, args[0])
+			if err != nil {
+				return err
+			}
+
+			if !st.Metadata.Archived {
+				ui.Print("Workspace is not archived.")
+				return nil
+			}
+
+			name := workspaceDisplayName(id, st)
+
+			var warnings []string
+			err = ui.RunWithSpinner("Restoring workspace: "+name, func(report func(string)) error {
+				return svc.Unarchive(cmd.Context(), id, report, func(msg string) {
+					warnings = append(warnings, msg)
+				})
+			})
+
+			failed, err := splitRepoErrors(err)
+			if err != nil {
+				return err
+			}
+
+			printRestoreReport(st, failed)
+			for _, w := range warnings {
+				ui.Warning(w)
+			}
+
+			ui.Print("")
+			if len(failed) > 0 {
+				ui.Warning(fmt.Sprintf("Restored workspace %s with %d of %d repos missing", name, len(failed), len(st.Spec.Repos)))
+				return nil
+			}
+			ui.Success("Restored workspace: " + name)
+			return nil
+		},
+	}
+}
+
+// splitRepoErrors separates per-repo render failures, keyed by repo path,
+// from any other error.
+func splitRepoErrors(err error) (map[string]error, error) {
+	failed := make(map[string]error)
+	if err == nil {
+		return failed, nil
+	}
+
+	errs := []error{err}
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		errs = joined.Unwrap()
+	}
+
+	var other []error
+	for _, e := range errs {
+		var repoErr *workspace.RepoError
+		if errors.As(e, &repoErr) {
+			failed[repoErr.Path] = repoErr.Err
+			continue
+		}
+		other = append(other, e)
+	}
+	return failed, errors.Join(other...)
+}
+
+// printRestoreReport prints one row per repo with its restore result.
+func printRestoreReport(st *state.State, failed map[string]error) {
+	headers := []string{"REPO", "BRANCH", "RESULT"}
+	var rows [][]string
+	for _, r := range st.Spec.Repos {
+		path := state.RepoPath(r)
+		result := "restored"
+		if err, ok := failed[path]; ok {
+			if errors.Is(err, workspace.ErrBranchNotFound) {
+				result = "not restored: branch deleted upstream and not in local cache"
+			} else {
+				result = "not restored: " + strings.SplitN(err.Error(), "\n", 2)[0]
+			}
+		}
+		rows = append(rows, []string{path, r.Branch, result})
+	}
+	fmt.Println(ui.Table(headers, rows))
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/milldr/flow/internal/config"
+	"github.com/milldr/flow/internal/state"
+	"github.com/milldr/flow/internal/ui"
+	"github.com/milldr/flow/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	errValidateArgRequired = errors.New("workspace argument required (or use --all)")
+	errInvalidState        = errors.New("invalid state")
+)
+
+func newValidateCmd(svc *workspace.Service, cfg *config.Config) *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "validate [workspace]",
+		Short: "Check state files for errors",
+		Long: `Check a workspace's state.yaml and report every problem with its line and
+column: invalid YAML, unknown fields, values of the wrong type, missing
+fields, invalid branch names, repos sharing a path or a branch, paths
+outside the workspace or on flow's own files, and branches that are their
+own base or (for cloned repos) the repo's default branch.
+
+Use --all to check every workspace, including ones whose state file no
+longer parses. Exits non-zero if any problem is found.`,
+		Args:    cobra.MaximumNArgs(1),
+		Example: "  flow validate vpc-ipv6   # Check one workspace\n  flow validate --all      # Check every workspace",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var ids []string
+			switch {
+			case all:
+				infos, err := svc.List()
+				if err != nil {
+					return err
+				}
+				for _, info := range infos {
+					ids = append(ids, info.ID)
+				}
+			case len(args) == 1:
+				id, err := validateTarget(svc, cfg, args[0])
+				if err != nil {
+					return err
+				}
+				ids = []string{id}
+			default:
+				return errValidateArgRequired
+			}
+
+			if len(ids) == 0 {
+				ui.Print("No workspaces found. Run `flow init` to create one.")
+				return nil
+			}
+
+			problems, broken := 0, 0
+			for _, id := range ids {
+				found, err := svc.Validate(cmd.Context(), id)
+				if err != nil {
+					return err
+				}
+				if len(found) == 0 {
+					ui.Success(id + ": valid")
+					continue
+				}
+				broken++
+				problems += len(found)
+				ui.Error(fmt.Sprintf("%s: %d problem(s)", id, len(found)))
+				for _, p := range found {
+					ui.Print("  " + formatProblem(cfg.StatePath(id), p))
+				}
+			}
+
+			if broken > 0 {
+				return fmt.Errorf("%w: %d problem(s) in %d workspace(s)", errInvalidState, problems, broken)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Check every workspace")
+	return cmd
+}
+
+// validateTarget resolves the workspace to validate. A workspace ID is used
+// directly, even if its state file doesn't parse; names are resolved as
+// usual.
+func validateTarget(svc *workspace.Service, cfg *config.Config, idOrName string) (string, error) {
+	if _, err := os.Stat(cfg.StatePath(idOrName)); err == nil {
+		return idOrName, nil
+	}
+	id, _, err := resolveWorkspace(svc, idOrName)
+	return id, err
+}
+
+// formatProblem renders a problem as path:line:col: message, the form
+// editors and terminals link to the position.
+func formatProblem(path string, p *state.Problem) string {
+	if p.Line == 0 {
+		return path + ": " + p.Error()
+	}
+	return path + ":" + p.Error()
+}
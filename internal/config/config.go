@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/milldr/flow/internal/giturl"
 	"github.com/milldr/flow/internal/status"
 )
 
@@ -16,6 +17,11 @@ type Config struct {
 	ReposDir       string      // ~/.flow/repos/
 	AgentsDir      string      // ~/.flow/agents/
 	CacheDir       string      // ~/.flow/cache/
+	TemplatesDir   string      // ~/.flow/templates/
+	TrashDir       string      // ~/.flow/trash/
+	LocksDir       string      // ~/.flow/locks/
+	SchemasDir     string      // ~/.flow/schemas/
+	LayoutFile     string      // ~/.flow/layout
 	ConfigFile     string      // ~/.flow/config.yaml
 	StatusSpecFile string      // ~/.flow/status.yaml
 	FlowConfig     *FlowConfig // loaded global config
@@ -39,6 +45,11 @@ func New() (*Config, error) {
 		ReposDir:       filepath.Join(home, "repos"),
 		AgentsDir:      filepath.Join(home, "agents"),
 		CacheDir:       filepath.Join(home, "cache"),
+		TemplatesDir:   filepath.Join(home, "templates"),
+		TrashDir:       filepath.Join(home, "trash"),
+		LocksDir:       filepath.Join(home, "locks"),
+		SchemasDir:     filepath.Join(home, "schemas"),
+		LayoutFile:     filepath.Join(home, "layout"),
 		ConfigFile:     filepath.Join(home, "config.yaml"),
 		StatusSpecFile: filepath.Join(home, "status.yaml"),
 	}, nil
@@ -54,11 +65,41 @@ func (c *Config) StatePath(name string) string {
 	return filepath.Join(c.WorkspacesDir, name, "state.yaml")
 }
 
-// BareRepoPath returns the bare clone path for a repo URL.
-// e.g., github.com/org/repo → ~/.flow/repos/github.com/org/repo.git
-// Handles URLs that already end in .git (e.g., git@github.com:org/repo.git).
+// TrashPath returns the path for a deleted workspace's trash entry.
+func (c *Config) TrashPath(entry string) string {
+	return filepath.Join(c.TrashDir, entry)
+}
+
+// WorkspaceLockPath returns the lock file guarding a workspace.
+func (c *Config) WorkspaceLockPath(id string) string {
+	return filepath.Join(c.LocksDir, "workspaces", id+".lock")
+}
+
+// RepoLockPath returns the lock file guarding a bare repo, mirroring its
+// path in the repo cache.
+func (c *Config) RepoLockPath(barePath string) string {
+	rel, err := filepath.Rel(c.ReposDir, barePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(barePath)
+	}
+	return filepath.Join(c.LocksDir, "repos", rel+".lock")
+}
+
+// FetchCacheLockPath returns the lock file guarding the fetch cache file.
+func (c *Config) FetchCacheLockPath() string {
+	return filepath.Join(c.LocksDir, "fetch.lock")
+}
+
+// BareRepoPath returns the bare clone path for a repo URL, keyed by its
+// normalized form so SSH, HTTPS and scheme-less spellings share one clone.
+// e.g., git@github.com:org/repo.git → ~/.flow/repos/github.com/org/repo.git
 func (c *Config) BareRepoPath(repoURL string) string {
-	return filepath.Join(c.ReposDir, strings.TrimSuffix(repoURL, ".git")+".git")
+	return filepath.Join(c.ReposDir, filepath.FromSlash(giturl.Normalize(repoURL))+".git")
+}
+
+// TemplatePath returns the file path for a named workspace template.
+func (c *Config) TemplatePath(name string) string {
+	return filepath.Join(c.TemplatesDir, name+".yaml")
 }
 
 // ClaudeAgentDir returns the path for the shared Claude agent directory.
@@ -71,13 +112,20 @@ func (c *Config) StatusCacheFile() string {
 	return filepath.Join(c.CacheDir, "status.json")
 }
 
+// FetchCacheFile returns the path to the JSON file recording when each bare
+// repo was last fetched.
+func (c *Config) FetchCacheFile() string {
+	return filepath.Join(c.CacheDir, "fetch.json")
+}
+
 // WorkspaceStatusSpecPath returns the status.yaml path for a workspace.
 func (c *Config) WorkspaceStatusSpecPath(id string) string {
 	return filepath.Join(c.WorkspacesDir, id, "status.yaml")
 }
 
 // EnsureDirs creates the top-level directories if they don't exist.
-// It also creates the default config file if missing, and loads the config.
+// It also writes the JSON Schemas for flow's files, creates the default
+// config file if missing, and loads the config.
 func (c *Config) EnsureDirs() error {
 	for _, dir := range []string{c.WorkspacesDir, c.ReposDir, c.AgentsDir, c.CacheDir} {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -85,16 +133,20 @@ func (c *Config) EnsureDirs() error {
 		}
 	}
 
+	if err := c.writeSchemas(); err != nil {
+		return err
+	}
+
 	// Create default config if missing, then load it
 	if _, err := os.Stat(c.ConfigFile); os.IsNotExist(err) {
-		if err := SaveFlowConfig(c.ConfigFile, DefaultFlowConfig()); err != nil {
+		if err := CreateFlowConfig(c.ConfigFile, DefaultFlowConfig(), c.SchemaRef(c.ConfigFile, "config")); err != nil {
 			return err
 		}
 	}
 
 	// Create default status spec if missing
 	if _, err := os.Stat(c.StatusSpecFile); os.IsNotExist(err) {
-		if err := status.Save(c.StatusSpecFile, status.DefaultSpec()); err != nil {
+		if err := status.Create(c.StatusSpecFile, status.DefaultSpec(), c.SchemaRef(c.StatusSpecFile, "status")); err != nil {
 			return err
 		}
 	}
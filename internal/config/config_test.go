@@ -34,6 +34,12 @@ func TestNewDefaultPath(t *testing.T) {
 	if cfg.ConfigFile != filepath.Join(expected, "config.yaml") {
 		t.Errorf("ConfigFile = %q", cfg.ConfigFile)
 	}
+	if cfg.TemplatesDir != filepath.Join(expected, "templates") {
+		t.Errorf("TemplatesDir = %q", cfg.TemplatesDir)
+	}
+	if cfg.TrashDir != filepath.Join(expected, "trash") {
+		t.Errorf("TrashDir = %q", cfg.TrashDir)
+	}
 }
 
 func TestNewFlowHomeOverride(t *testing.T) {
@@ -59,6 +65,20 @@ func TestWorkspacePath(t *testing.T) {
 	}
 }
 
+func TestLockPaths(t *testing.T) {
+	cfg := &Config{ReposDir: "/test/repos", LocksDir: "/test/locks"}
+
+	if got := cfg.WorkspaceLockPath("my-ws"); got != "/test/locks/workspaces/my-ws.lock" {
+		t.Errorf("WorkspaceLockPath = %q", got)
+	}
+	if got := cfg.RepoLockPath("/test/repos/github.com/org/repo.git"); got != "/test/locks/repos/github.com/org/repo.git.lock" {
+		t.Errorf("RepoLockPath = %q", got)
+	}
+	if got := cfg.FetchCacheLockPath(); got != "/test/locks/fetch.lock" {
+		t.Errorf("FetchCacheLockPath = %q", got)
+	}
+}
+
 func TestStatePath(t *testing.T) {
 	cfg := &Config{Home: "/test", WorkspacesDir: "/test/workspaces", ReposDir: "/test/repos"}
 
@@ -77,7 +97,9 @@ func TestBareRepoPath(t *testing.T) {
 	}{
 		{"github.com/org/repo", "/test/repos/github.com/org/repo.git"},
 		{"gitlab.com/deep/nested/repo", "/test/repos/gitlab.com/deep/nested/repo.git"},
-		{"git@github.com:org/repo.git", "/test/repos/git@github.com:org/repo.git"},
+		{"git@github.com:org/repo.git", "/test/repos/github.com/org/repo.git"},
+		{"https://github.com/org/repo", "/test/repos/github.com/org/repo.git"},
+		{"ssh://git@host:2222/x", "/test/repos/host:2222/x.git"},
 	}
 
 	for _, tt := range tests {
@@ -88,6 +110,15 @@ func TestBareRepoPath(t *testing.T) {
 	}
 }
 
+func TestTemplatePath(t *testing.T) {
+	cfg := &Config{Home: "/test", TemplatesDir: "/test/templates"}
+
+	got := cfg.TemplatePath("platform")
+	if got != "/test/templates/platform.yaml" {
+		t.Errorf("TemplatePath = %q", got)
+	}
+}
+
 func TestClaudeAgentDir(t *testing.T) {
 	cfg := &Config{Home: "/test", AgentsDir: "/test/agents"}
 	got := cfg.ClaudeAgentDir()
@@ -1,12 +1,32 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"time"
 
+	"github.com/milldr/flow/internal/fsutil"
+	"github.com/milldr/flow/internal/giturl"
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/schema"
+	"github.com/milldr/flow/internal/state"
 	"gopkg.in/yaml.v3"
 )
 
+// Config file validation errors.
+var (
+	// ErrInvalidTransport is returned when spec.git.transports names an
+	// unsupported protocol.
+	ErrInvalidTransport = errors.New("invalid transport")
+	// ErrInvalidFetchTTL is returned when spec.git.fetchTTL is not a
+	// non-negative duration.
+	ErrInvalidFetchTTL = errors.New("invalid fetchTTL")
+)
+
+// DefaultFetchTTL is how long a fetch of a bare repo stays fresh when
+// spec.git.fetchTTL is not set.
+const DefaultFetchTTL = time.Minute
+
 // Agent represents a configured agent tool (editor, AI assistant, etc.).
 type Agent struct {
 	Name    string `yaml:"name"`
@@ -14,9 +34,43 @@ type Agent struct {
 	Default bool   `yaml:"default,omitempty"`
 }
 
+// GitConfig controls how repo URLs from state files are turned into the
+// URLs git clones and fetches, so one state file works on every machine.
+type GitConfig struct {
+	// URLRewrites replace URL prefixes, like git's url.<url>.insteadOf.
+	URLRewrites []giturl.Rewrite `yaml:"urlRewrites,omitempty"`
+	// Transports maps a host (e.g. github.com) to ssh or https.
+	Transports map[string]giturl.Transport `yaml:"transports,omitempty"`
+	// Clone sets the default clone options for new bare clones. Repos can
+	// override them in their state file.
+	Clone state.CloneOptions `yaml:"clone,omitempty"`
+	// FetchTTL is a duration (e.g. 30s, 5m) during which a bare repo that
+	// was fetched is not fetched again. "0" fetches every time.
+	FetchTTL string `yaml:"fetchTTL,omitempty"`
+}
+
+// FetchFreshness returns how long a fetch stays fresh: spec.git.fetchTTL,
+// or DefaultFetchTTL when unset. LoadFlowConfig rejects invalid values.
+func (g GitConfig) FetchFreshness() time.Duration {
+	if g.FetchTTL == "" {
+		return DefaultFetchTTL
+	}
+	ttl, err := time.ParseDuration(g.FetchTTL)
+	if err != nil {
+		return DefaultFetchTTL
+	}
+	return ttl
+}
+
+// Rules returns the URL rules to hand to the git runner.
+func (g GitConfig) Rules() *giturl.Rules {
+	return &giturl.Rules{Transports: g.Transports, Rewrites: g.URLRewrites}
+}
+
 // FlowConfigSpec holds optional configuration nested under spec.
 type FlowConfigSpec struct {
-	Agents []Agent `yaml:"agents,omitempty"`
+	Agents []Agent   `yaml:"agents,omitempty"`
+	Git    GitConfig `yaml:"git,omitempty"`
 }
 
 // FlowConfig represents the global flow configuration file.
@@ -24,6 +78,9 @@ type FlowConfig struct {
 	APIVersion string         `yaml:"apiVersion"`
 	Kind       string         `yaml:"kind"`
 	Spec       FlowConfigSpec `yaml:"spec,omitempty"`
+
+	version      fsutil.Version // file content at Load, for SaveFlowConfig
+	migratedFrom string         // apiVersion before LoadFlowConfig upgraded it
 }
 
 // DefaultAgent returns the agent marked as default, or nil if none is configured.
@@ -39,7 +96,7 @@ func (fc *FlowConfig) DefaultAgent() *Agent {
 // DefaultFlowConfig returns a FlowConfig with default values.
 func DefaultFlowConfig() *FlowConfig {
 	return &FlowConfig{
-		APIVersion: "flow/v1",
+		APIVersion: migrate.CurrentVersion,
 		Kind:       "Config",
 		Spec: FlowConfigSpec{
 			Agents: []Agent{
@@ -51,25 +108,77 @@ func DefaultFlowConfig() *FlowConfig {
 
 // LoadFlowConfig reads and parses a flow config file from disk.
 func LoadFlowConfig(path string) (*FlowConfig, error) {
-	data, err := os.ReadFile(path)
+	data, version, err := fsutil.ReadVersioned(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var fc FlowConfig
-	if err := yaml.Unmarshal(data, &fc); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("parsing config file: %w", err)
 	}
+	from, err := migrate.Documents.Upgrade("Config", &root)
+	if err != nil && !errors.Is(err, migrate.ErrNoMigration) {
+		return nil, err
+	}
+
+	var fc FlowConfig
+	if root.Kind != 0 {
+		if err := root.Decode(&fc); err != nil {
+			return nil, fmt.Errorf("parsing config file: %w", err)
+		}
+	}
+	fc.version = version
+	fc.migratedFrom = from
+
+	for host, t := range fc.Spec.Git.Transports {
+		if t != giturl.TransportSSH && t != giturl.TransportHTTPS {
+			return nil, fmt.Errorf("%w %q for %s in config file (use ssh or https)", ErrInvalidTransport, t, host)
+		}
+	}
+	if ttl := fc.Spec.Git.FetchTTL; ttl != "" {
+		if d, err := time.ParseDuration(ttl); err != nil || d < 0 {
+			return nil, fmt.Errorf("%w %q in config file (use a duration such as 30s or 5m)", ErrInvalidFetchTTL, ttl)
+		}
+	}
+	if fc.Spec.Git.Clone.Depth < 0 {
+		return nil, fmt.Errorf("spec.git: %w", state.ErrInvalidDepth)
+	}
 
 	return &fc, nil
 }
 
-// SaveFlowConfig writes a FlowConfig to disk as YAML.
+// MigratedFrom returns the apiVersion the file had when LoadFlowConfig
+// upgraded it to the current one, or "" if it was already current.
+func (fc *FlowConfig) MigratedFrom() string {
+	return fc.migratedFrom
+}
+
+// SaveFlowConfig atomically writes a FlowConfig to disk as YAML. If fc was
+// loaded from path and the file has changed since, it is left alone and
+// fsutil.ErrConcurrentModification is returned.
 func SaveFlowConfig(path string, fc *FlowConfig) error {
 	data, err := yaml.Marshal(fc)
 	if err != nil {
 		return fmt.Errorf("marshaling config: %w", err)
 	}
 
-	return os.WriteFile(path, data, 0o644)
+	fc.version, err = fsutil.WriteVersioned(path, data, 0o644, fc.version)
+	return err
+}
+
+// CreateFlowConfig writes fc as a new config file at path, replacing any
+// file there. A non-empty schemaRef adds a comment pointing YAML editors at
+// the config schema, at schemaRef relative to the file.
+func CreateFlowConfig(path string, fc *FlowConfig, schemaRef string) error {
+	data, err := yaml.Marshal(fc)
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+	if schemaRef != "" {
+		data = append([]byte(schema.Header(schemaRef)), data...)
+	}
+
+	fc.version, err = fsutil.WriteVersioned(path, data, 0o644, fsutil.Version{})
+	return err
 }
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDefaultFlowConfig(t *testing.T) {
@@ -116,3 +118,72 @@ func TestLoadFlowConfigMalformed(t *testing.T) {
 		t.Fatal("expected error for malformed YAML")
 	}
 }
+
+func TestLoadFlowConfigGit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := `apiVersion: flow/v1
+kind: Config
+spec:
+  git:
+    urlRewrites:
+      - insteadOf: "git@github.com:"
+        url: https://github.com/
+    transports:
+      gitlab.com: ssh
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err := LoadFlowConfig(path)
+	if err != nil {
+		t.Fatalf("LoadFlowConfig: %v", err)
+	}
+	rules := fc.Spec.Git.Rules()
+	if got := rules.Apply("git@github.com:org/repo.git"); got != "https://github.com/org/repo.git" {
+		t.Errorf("Apply(github) = %q", got)
+	}
+	if got := rules.Apply("https://gitlab.com/org/repo"); got != "git@gitlab.com:org/repo.git" {
+		t.Errorf("Apply(gitlab) = %q", got)
+	}
+}
+
+func TestLoadFlowConfigInvalidTransport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "apiVersion: flow/v1\nkind: Config\nspec:\n  git:\n    transports:\n      github.com: ftp\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFlowConfig(path)
+	if !errors.Is(err, ErrInvalidTransport) {
+		t.Fatalf("err = %v, want ErrInvalidTransport", err)
+	}
+}
+
+func TestFetchFreshness(t *testing.T) {
+	tests := []struct {
+		ttl  string
+		want time.Duration
+	}{
+		{"", DefaultFetchTTL},
+		{"30s", 30 * time.Second},
+		{"0", 0},
+	}
+	for _, tt := range tests {
+		if got := (GitConfig{FetchTTL: tt.ttl}).FetchFreshness(); got != tt.want {
+			t.Errorf("FetchFreshness(%q) = %v, want %v", tt.ttl, got, tt.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "apiVersion: flow/v1\nkind: Config\nspec:\n  git:\n    fetchTTL: soon\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFlowConfig(path); !errors.Is(err, ErrInvalidFetchTTL) {
+		t.Errorf("err = %v, want ErrInvalidFetchTTL", err)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/milldr/flow/internal/fsutil"
+	"github.com/milldr/flow/internal/giturl"
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/schema"
+	"github.com/milldr/flow/internal/state"
+	"github.com/milldr/flow/internal/status"
+)
+
+// Schema returns the JSON Schema for the config file, generated from
+// FlowConfig.
+func Schema() *schema.Schema {
+	s := schema.Generate(FlowConfig{})
+	s.Schema = schema.Draft
+	s.Title = "flow config"
+	s.At("apiVersion").Const = migrate.CurrentVersion
+	s.At("kind").Const = "Config"
+	s.At("spec", "git", "transports").Values.Enum = []string{string(giturl.TransportSSH), string(giturl.TransportHTTPS)}
+	s.At("spec", "git", "clone", "depth").Minimum = new(int)
+	return s
+}
+
+// Schemas returns the schema for each kind of file flow reads, by the name
+// `flow schema` takes.
+func Schemas() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"state":  state.Schema(),
+		"status": status.Schema(),
+		"config": Schema(),
+	}
+}
+
+// SchemaPath returns where EnsureDirs writes the schema for a kind of file.
+func (c *Config) SchemaPath(kind string) string {
+	return filepath.Join(c.SchemasDir, kind+".json")
+}
+
+// SchemaRef returns the schema for a kind of file as a path relative to
+// file, for the yaml-language-server header of a file flow creates. It is
+// empty when there is no schema directory.
+func (c *Config) SchemaRef(file, kind string) string {
+	if c.SchemasDir == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(filepath.Dir(file), c.SchemaPath(kind))
+	if err != nil {
+		return c.SchemaPath(kind)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// MarshalSchema encodes a schema as indented JSON.
+func MarshalSchema(s *schema.Schema) ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// writeSchemas writes every schema to the schema directory, so they match
+// the running flow version. Files that are already current are left alone.
+func (c *Config) writeSchemas() error {
+	if c.SchemasDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.SchemasDir, 0o755); err != nil {
+		return err
+	}
+	for kind, s := range Schemas() {
+		data, err := MarshalSchema(s)
+		if err != nil {
+			return err
+		}
+		path := c.SchemaPath(kind)
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+			continue
+		}
+		if err := fsutil.WriteFileAtomic(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
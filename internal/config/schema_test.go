@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/milldr/flow/internal/schema"
+	"github.com/milldr/flow/internal/status"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnsureDirsWritesSchemas(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		Home:           dir,
+		WorkspacesDir:  filepath.Join(dir, "workspaces"),
+		ReposDir:       filepath.Join(dir, "repos"),
+		AgentsDir:      filepath.Join(dir, "agents"),
+		CacheDir:       filepath.Join(dir, "cache"),
+		SchemasDir:     filepath.Join(dir, "schemas"),
+		ConfigFile:     filepath.Join(dir, "config.yaml"),
+		StatusSpecFile: filepath.Join(dir, "status.yaml"),
+	}
+	if err := cfg.EnsureDirs(); err != nil {
+		t.Fatalf("EnsureDirs: %v", err)
+	}
+
+	for kind := range Schemas() {
+		data, err := os.ReadFile(cfg.SchemaPath(kind))
+		if err != nil {
+			t.Fatalf("schema %s not written: %v", kind, err)
+		}
+		var s map[string]any
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("schema %s is not JSON: %v", kind, err)
+		}
+		if s["$schema"] != schema.Draft {
+			t.Errorf("schema %s $schema = %v", kind, s["$schema"])
+		}
+	}
+
+	for file, want := range map[string]string{
+		cfg.ConfigFile:     "# yaml-language-server: $schema=schemas/config.json\n",
+		cfg.StatusSpecFile: "# yaml-language-server: $schema=schemas/status.json\n",
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), want) {
+			t.Errorf("%s starts with %q, want %q", filepath.Base(file), strings.SplitAfter(string(data), "\n")[0], want)
+		}
+	}
+}
+
+func TestSchemaRef(t *testing.T) {
+	cfg := &Config{SchemasDir: "/home/.flow/schemas"}
+	if got := cfg.SchemaRef("/home/.flow/workspaces/a/state.yaml", "state"); got != "../../schemas/state.json" {
+		t.Errorf("SchemaRef = %q, want ../../schemas/state.json", got)
+	}
+	if got := (&Config{}).SchemaRef("/x/state.yaml", "state"); got != "" {
+		t.Errorf("SchemaRef without a schema dir = %q, want empty", got)
+	}
+}
+
+// The files flow writes by default must pass their own schemas.
+func TestDefaultsMatchSchemas(t *testing.T) {
+	for kind, v := range map[string]any{
+		"config": DefaultFlowConfig(),
+		"status": status.DefaultSpec(),
+	} {
+		var n yaml.Node
+		if err := n.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range schema.Check(&n, Schemas()[kind]) {
+			t.Errorf("default %s: %v", kind, p)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package fsutil provides crash-safe file writes for flow's state, status,
+// config and cache files.
+package fsutil
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrConcurrentModification is returned when a file changed on disk between
+// being read and being written back.
+var ErrConcurrentModification = errors.New("file was changed by another process since it was read")
+
+// Version records a file's content as it was read, so a later write can tell
+// whether something else changed the file in between. The zero Version
+// matches nothing and makes writes unconditional.
+type Version struct {
+	path string
+	sum  [sha256.Size]byte
+}
+
+// ReadVersioned reads a file and returns its content with its Version.
+func ReadVersioned(path string) ([]byte, Version, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Version{}, err
+	}
+	return data, Version{path: path, sum: sha256.Sum256(data)}, nil
+}
+
+// WriteVersioned atomically replaces path with data. If v was read from the
+// same path and the file has since changed or been removed, nothing is
+// written and ErrConcurrentModification is returned. It returns the Version
+// of the written content for the next write.
+func WriteVersioned(path string, data []byte, perm os.FileMode, v Version) (Version, error) {
+	if v.path != "" && filepath.Clean(v.path) == filepath.Clean(path) {
+		current, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return v, err
+		}
+		if err != nil || sha256.Sum256(current) != v.sum {
+			return v, fmt.Errorf("%w: %s", ErrConcurrentModification, path)
+		}
+	}
+	if err := WriteFileAtomic(path, data, perm); err != nil {
+		return v, err
+	}
+	return Version{path: path, sum: sha256.Sum256(data)}, nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it over path, so readers see either the old or the new content and
+// a crash never leaves a truncated file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Removing the temp file fails harmlessly once it has been renamed.
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes a directory so a rename in it survives a crash. Not every
+// filesystem supports syncing directories; those errors are ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	_ = d.Sync()
+	return d.Close()
+}
@@ -0,0 +1,97 @@
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.yaml")
+
+	if err := WriteFileAtomic(path, []byte("one\n"), 0o600); err != nil {
+		t.Fatalf("WriteFileAtomic: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("two\n"), 0o600); err != nil {
+		t.Fatalf("WriteFileAtomic overwrite: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "two\n" {
+		t.Errorf("content = %q, want %q", data, "two\n")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("dir has %d entries, want only the file (no temp files left)", len(entries))
+	}
+}
+
+func TestWriteVersioned(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.yaml")
+	if err := os.WriteFile(path, []byte("original\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, v, err := ReadVersioned(path)
+	if err != nil {
+		t.Fatalf("ReadVersioned: %v", err)
+	}
+
+	// Unchanged since read: written, and the new version allows the next write.
+	v, err = WriteVersioned(path, []byte("ours\n"), 0o644, v)
+	if err != nil {
+		t.Fatalf("WriteVersioned: %v", err)
+	}
+	if _, err := WriteVersioned(path, []byte("ours again\n"), 0o644, v); err != nil {
+		t.Fatalf("WriteVersioned after own write: %v", err)
+	}
+
+	// Changed by someone else: refused, their content kept.
+	_, v, err = ReadVersioned(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("theirs\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteVersioned(path, []byte("ours\n"), 0o644, v); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("err = %v, want ErrConcurrentModification", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "theirs\n" {
+		t.Errorf("content = %q, want the concurrent edit kept", data)
+	}
+
+	// Removed since read: refused rather than recreated.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteVersioned(path, []byte("ours\n"), 0o644, v); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("err = %v, want ErrConcurrentModification for a removed file", err)
+	}
+
+	// A version read from another file, or the zero Version, doesn't apply.
+	other := filepath.Join(dir, "copy.yaml")
+	if _, err := WriteVersioned(other, []byte("copy\n"), 0o644, v); err != nil {
+		t.Errorf("WriteVersioned to another path: %v", err)
+	}
+	if _, err := WriteVersioned(path, []byte("new\n"), 0o644, Version{}); err != nil {
+		t.Errorf("WriteVersioned with zero Version: %v", err)
+	}
+}
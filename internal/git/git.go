@@ -4,19 +4,28 @@ package git
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/milldr/flow/internal/giturl"
 )
 
 // Runner abstracts git operations for testability.
 type Runner interface {
-	BareClone(ctx context.Context, url, dest string) error
+	BareClone(ctx context.Context, url, dest string, opts CloneOptions) error
 	Fetch(ctx context.Context, repoPath string) error
+	FetchBranch(ctx context.Context, bareRepo, branch string) (bool, error)
+	FetchRef(ctx context.Context, bareRepo, ref string) error
 	AddWorktree(ctx context.Context, bareRepo, worktreePath, branch string) error
 	AddWorktreeNewBranch(ctx context.Context, bareRepo, worktreePath, newBranch, startPoint string) error
+	AddWorktreeDetached(ctx context.Context, bareRepo, worktreePath, commit string) error
 	RemoveWorktree(ctx context.Context, bareRepo, worktreePath string) error
 	BranchExists(ctx context.Context, bareRepo, branch string) (bool, error)
 	DeleteBranch(ctx context.Context, bareRepo, branch string) error
@@ -27,13 +36,69 @@ type Runner interface {
 	CurrentBranch(ctx context.Context, worktreePath string) (string, error)
 	CheckoutBranch(ctx context.Context, worktreePath, branch string) error
 	CheckoutNewBranch(ctx context.Context, worktreePath, newBranch, startPoint string) error
+	CheckoutDetached(ctx context.Context, worktreePath, commit string) error
 	Rebase(ctx context.Context, worktreePath, onto string) error
 	RebaseAbort(ctx context.Context, worktreePath string) error
+	UnpushedCommits(ctx context.Context, repoPath, ref string) (int, error)
+	CommitsAhead(ctx context.Context, repoPath, base, ref string) (int, error)
+	RefExists(ctx context.Context, repoPath, ref string) (bool, error)
+	CommonDir(ctx context.Context, worktreePath string) (string, error)
+	UpdateRef(ctx context.Context, repoPath, ref, target string) error
+	RebaseOnto(ctx context.Context, worktreePath, onto, upstream string) error
+	GetConfig(ctx context.Context, repoPath, key string) (string, error)
+	SetConfig(ctx context.Context, repoPath, key, value string) error
+	GetWorktreeConfig(ctx context.Context, worktreePath, key string) (string, error)
+	SetWorktreeConfig(ctx context.Context, worktreePath, key, value string) error
+	ResolveRef(ctx context.Context, repoPath, ref string) (string, error)
+	DiffWorktree(ctx context.Context, worktreePath string) ([]byte, error)
+	ApplyPatch(ctx context.Context, worktreePath, patchFile string) error
+	CreateBundle(ctx context.Context, repoPath, bundleFile, ref string) error
+	FetchBundle(ctx context.Context, repoPath, bundleFile, refspec string) error
+	StashCount(ctx context.Context, worktreePath, branch string) (int, error)
+	PruneWorktrees(ctx context.Context, bareRepo string) error
+	ListWorktrees(ctx context.Context, bareRepo string) ([]string, error)
+	GarbageCollect(ctx context.Context, repoPath string) error
+	ListRefs(ctx context.Context, repoPath, prefix string) (map[string]string, error)
+}
+
+// CloneOptions limit what a bare clone downloads.
+type CloneOptions struct {
+	// Filter is a partial clone filter such as blob:none. Missing objects
+	// are fetched on demand.
+	Filter string
+	// Depth truncates history to this many commits per branch. Zero clones
+	// full history.
+	Depth int
+	// SingleBranch clones only the default branch. Other branches are
+	// fetched with FetchBranch as they are needed.
+	SingleBranch bool
+}
+
+// args returns the git clone flags for the options.
+func (o CloneOptions) args() []string {
+	var args []string
+	if o.Filter != "" {
+		args = append(args, "--filter="+o.Filter)
+	}
+	if o.Depth > 0 {
+		args = append(args, "--depth="+strconv.Itoa(o.Depth))
+	}
+	switch {
+	case o.SingleBranch:
+		args = append(args, "--single-branch")
+	case o.Depth > 0:
+		// --depth implies --single-branch.
+		args = append(args, "--no-single-branch")
+	}
+	return args
 }
 
 // RealRunner shells out to the git binary.
 type RealRunner struct {
 	Log *slog.Logger
+	// URLs rewrites the URLs repos are cloned and fetched from. Nil leaves
+	// them as given.
+	URLs *giturl.Rules
 }
 
 func (r *RealRunner) log() *slog.Logger {
@@ -44,9 +109,17 @@ func (r *RealRunner) log() *slog.Logger {
 }
 
 func (r *RealRunner) run(ctx context.Context, args ...string) error {
-	r.log().Debug("executing git command", "args", args)
+	return r.runEnv(ctx, nil, args...)
+}
+
+// runEnv runs git with extra environment variables.
+func (r *RealRunner) runEnv(ctx context.Context, env []string, args ...string) error {
+	r.log().Debug("executing git command", "args", redactArgs(args))
 
 	cmd := exec.CommandContext(ctx, "git", args...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
@@ -61,7 +134,7 @@ func (r *RealRunner) run(ctx context.Context, args ...string) error {
 }
 
 func (r *RealRunner) output(ctx context.Context, args ...string) (string, error) {
-	r.log().Debug("executing git command", "args", args)
+	r.log().Debug("executing git command", "args", redactArgs(args))
 
 	cmd := exec.CommandContext(ctx, "git", args...)
 	var stdout, stderr bytes.Buffer
@@ -78,14 +151,46 @@ func (r *RealRunner) output(ctx context.Context, args ...string) (string, error)
 	return strings.TrimSpace(stdout.String()), nil
 }
 
-// BareClone creates a bare clone of a repository.
-func (r *RealRunner) BareClone(ctx context.Context, url, dest string) error {
-	r.log().Debug("bare cloning repository", "url", url, "dest", dest)
+// rawOutput runs git with extra environment variables and returns stdout
+// untrimmed, for binary output such as patches.
+func (r *RealRunner) rawOutput(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	r.log().Debug("executing git command", "args", redactArgs(args))
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		errMsg := strings.TrimSpace(stderr.String())
+		if errMsg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, errMsg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// redactArgs hides credentials in URL arguments for logging.
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = giturl.Redact(a)
+	}
+	return out
+}
+
+// BareClone creates a bare clone of a repository. The URL rules are applied
+// to url for the clone, but origin is left pointing at url.
+func (r *RealRunner) BareClone(ctx context.Context, url, dest string, opts CloneOptions) error {
+	r.log().Debug("bare cloning repository", "url", url, "dest", dest, "opts", opts)
 	cloneURL := url
 	if !strings.Contains(url, "://") && !strings.HasPrefix(url, "git@") && !filepath.IsAbs(url) && !strings.HasPrefix(url, ".") {
 		cloneURL = "https://" + url
 	}
-	return r.run(ctx, "clone", "--bare", cloneURL, dest)
+	args := append([]string{"clone", "--bare"}, opts.args()...)
+	return r.runEnv(ctx, r.remoteEnv(cloneURL), append(args, cloneURL, dest)...)
 }
 
 // Fetch fetches all refs in a bare repository and ensures the default branch
@@ -93,7 +198,11 @@ func (r *RealRunner) BareClone(ctx context.Context, url, dest string) error {
 func (r *RealRunner) Fetch(ctx context.Context, repoPath string) error {
 	r.log().Debug("fetching repository", "path", repoPath)
 
-	if err := r.run(ctx, "-C", repoPath, "fetch", "--all", "--prune"); err != nil {
+	env, err := r.originEnv(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+	if err := r.runEnv(ctx, env, "-C", repoPath, "fetch", "--all", "--prune"); err != nil {
 		return err
 	}
 
@@ -104,7 +213,7 @@ func (r *RealRunner) Fetch(ctx context.Context, repoPath string) error {
 	// macOS for repos with branches that differ only by casing.
 	defaultBranch, err := r.output(ctx, "-C", repoPath, "symbolic-ref", "--short", "HEAD")
 	if err == nil && defaultBranch != "" {
-		_ = r.run(ctx, "-C", repoPath, "fetch", "origin",
+		_ = r.runEnv(ctx, env, "-C", repoPath, "fetch", "origin",
 			"+refs/heads/"+defaultBranch+":refs/remotes/origin/"+defaultBranch)
 		_ = r.run(ctx, "-C", repoPath, "symbolic-ref",
 			"refs/remotes/origin/HEAD", "refs/remotes/origin/"+defaultBranch)
@@ -113,6 +222,77 @@ func (r *RealRunner) Fetch(ctx context.Context, repoPath string) error {
 	return nil
 }
 
+// FetchBranch fetches a branch from origin into a bare repo that doesn't
+// have it, such as a single-branch clone. It reports false when origin has
+// no such branch.
+func (r *RealRunner) FetchBranch(ctx context.Context, bareRepo, branch string) (bool, error) {
+	r.log().Debug("fetching branch", "bare_repo", bareRepo, "branch", branch)
+	env, err := r.originEnv(ctx, bareRepo)
+	if err != nil {
+		return false, err
+	}
+	ref := "refs/heads/" + branch
+	err = r.runEnv(ctx, env, "-C", bareRepo, "ls-remote", "--exit-code", "--heads", "origin", ref)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := r.runEnv(ctx, env, "-C", bareRepo, "fetch", "origin", ref+":"+ref); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FetchRef fetches a tag or commit from origin into a bare repo, for
+// worktrees pinned to it. A tag is stored under refs/tags/, replacing a
+// local copy that origin has moved; anything else is fetched by commit
+// hash, which origin must allow.
+func (r *RealRunner) FetchRef(ctx context.Context, bareRepo, ref string) error {
+	r.log().Debug("fetching ref", "bare_repo", bareRepo, "ref", ref)
+	env, err := r.originEnv(ctx, bareRepo)
+	if err != nil {
+		return err
+	}
+	tag := "refs/tags/" + ref
+	if err := r.runEnv(ctx, env, "-C", bareRepo, "fetch", "origin", "+"+tag+":"+tag); err == nil {
+		return nil
+	}
+	return r.runEnv(ctx, env, "-C", bareRepo, "fetch", "origin", ref)
+}
+
+// originEnv returns the environment that applies the URL rules to origin's
+// URL for commands that reach the remote.
+func (r *RealRunner) originEnv(ctx context.Context, repoPath string) ([]string, error) {
+	if r.URLs == nil {
+		return nil, nil
+	}
+	url, err := r.GetConfig(ctx, repoPath, "remote.origin.url")
+	if err != nil || url == "" {
+		return nil, err
+	}
+	return r.remoteEnv(url), nil
+}
+
+// remoteEnv returns the environment that makes git reach url at its URL with
+// the rules applied, as a url.<rewritten>.insteadOf entry for one command.
+// The rewritten URL may hold a token, so it is never written to the repo
+// config or passed on the command line.
+func (r *RealRunner) remoteEnv(url string) []string {
+	want := r.URLs.Apply(url)
+	if want == url {
+		return nil
+	}
+	r.log().Debug("rewriting remote url", "from", giturl.Redact(url), "to", giturl.Redact(want))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=url." + want + ".insteadOf",
+		"GIT_CONFIG_VALUE_0=" + url,
+	}
+}
+
 // AddWorktree creates a worktree from a bare repo at the given path and branch.
 // Uses --force to allow the same branch to be checked out in multiple worktrees
 // across different workspaces.
@@ -127,6 +307,14 @@ func (r *RealRunner) AddWorktreeNewBranch(ctx context.Context, bareRepo, worktre
 	return r.run(ctx, "-C", bareRepo, "worktree", "add", "-b", newBranch, worktreePath, startPoint)
 }
 
+// AddWorktreeDetached creates a worktree with HEAD detached at commit.
+// Uses --force so a worktree that was deleted without git knowing can be
+// re-created.
+func (r *RealRunner) AddWorktreeDetached(ctx context.Context, bareRepo, worktreePath, commit string) error {
+	r.log().Debug("adding detached worktree", "bare_repo", bareRepo, "worktree", worktreePath, "commit", commit)
+	return r.run(ctx, "-C", bareRepo, "worktree", "add", "--force", "--detach", worktreePath, commit)
+}
+
 // RemoveWorktree removes a worktree from a bare repo.
 func (r *RealRunner) RemoveWorktree(ctx context.Context, bareRepo, worktreePath string) error {
 	r.log().Debug("removing worktree", "bare_repo", bareRepo, "worktree", worktreePath)
@@ -163,7 +351,11 @@ func (r *RealRunner) DefaultBranch(ctx context.Context, bareRepo string) (string
 // origin/{branch} resolves from worktrees.
 func (r *RealRunner) EnsureRemoteRef(ctx context.Context, bareRepo, branch string) error {
 	r.log().Debug("ensuring remote ref", "bare_repo", bareRepo, "branch", branch)
-	return r.run(ctx, "-C", bareRepo, "fetch", "origin",
+	env, err := r.originEnv(ctx, bareRepo)
+	if err != nil {
+		return err
+	}
+	return r.runEnv(ctx, env, "-C", bareRepo, "fetch", "origin",
 		"+refs/heads/"+branch+":refs/remotes/origin/"+branch)
 }
 
@@ -190,10 +382,12 @@ func (r *RealRunner) CurrentBranch(ctx context.Context, worktreePath string) (st
 	return r.output(ctx, "-C", worktreePath, "rev-parse", "--abbrev-ref", "HEAD")
 }
 
-// CheckoutBranch switches to an existing branch in a worktree.
+// CheckoutBranch switches to an existing branch in a worktree. Like
+// AddWorktree, it allows the branch to be checked out in other worktrees
+// across different workspaces.
 func (r *RealRunner) CheckoutBranch(ctx context.Context, worktreePath, branch string) error {
 	r.log().Debug("checking out branch", "path", worktreePath, "branch", branch)
-	return r.run(ctx, "-C", worktreePath, "checkout", branch)
+	return r.run(ctx, "-C", worktreePath, "checkout", "--ignore-other-worktrees", branch)
 }
 
 // CheckoutNewBranch creates and switches to a new branch from a start point.
@@ -202,14 +396,293 @@ func (r *RealRunner) CheckoutNewBranch(ctx context.Context, worktreePath, newBra
 	return r.run(ctx, "-C", worktreePath, "checkout", "-b", newBranch, startPoint)
 }
 
+// CheckoutDetached detaches HEAD in a worktree at commit.
+func (r *RealRunner) CheckoutDetached(ctx context.Context, worktreePath, commit string) error {
+	r.log().Debug("checking out detached", "path", worktreePath, "commit", commit)
+	return r.run(ctx, "-C", worktreePath, "checkout", "--detach", commit)
+}
+
 // Rebase rebases the current branch onto the given ref.
 func (r *RealRunner) Rebase(ctx context.Context, worktreePath, onto string) error {
 	r.log().Debug("rebasing", "path", worktreePath, "onto", onto)
 	return r.run(ctx, "-C", worktreePath, "rebase", onto)
 }
 
+// RebaseOnto replays the commits after upstream onto the given ref.
+func (r *RealRunner) RebaseOnto(ctx context.Context, worktreePath, onto, upstream string) error {
+	r.log().Debug("rebasing", "path", worktreePath, "onto", onto, "upstream", upstream)
+	return r.run(ctx, "-C", worktreePath, "rebase", "--onto", onto, upstream)
+}
+
 // RebaseAbort aborts a rebase in progress.
 func (r *RealRunner) RebaseAbort(ctx context.Context, worktreePath string) error {
 	r.log().Debug("aborting rebase", "path", worktreePath)
 	return r.run(ctx, "-C", worktreePath, "rebase", "--abort")
 }
+
+// UnpushedCommits counts commits reachable from ref that are not reachable
+// from any remote-tracking ref. Returns 0 if ref does not exist.
+func (r *RealRunner) UnpushedCommits(ctx context.Context, repoPath, ref string) (int, error) {
+	r.log().Debug("counting unpushed commits", "path", repoPath, "ref", ref)
+	exists, err := r.RefExists(ctx, repoPath, ref)
+	if err != nil || !exists {
+		return 0, err
+	}
+	return r.count(ctx, "-C", repoPath, "rev-list", "--count", ref, "--not", "--remotes")
+}
+
+// CommitsAhead counts commits reachable from ref that are not reachable from base.
+func (r *RealRunner) CommitsAhead(ctx context.Context, repoPath, base, ref string) (int, error) {
+	r.log().Debug("counting commits ahead", "path", repoPath, "base", base, "ref", ref)
+	return r.count(ctx, "-C", repoPath, "rev-list", "--count", base+".."+ref)
+}
+
+// RefExists reports whether ref resolves to a commit in the repository.
+func (r *RealRunner) RefExists(ctx context.Context, repoPath, ref string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("git rev-parse: %w", err)
+	}
+	return true, nil
+}
+
+// ResolveRef returns the full commit hash ref points at.
+func (r *RealRunner) ResolveRef(ctx context.Context, repoPath, ref string) (string, error) {
+	return r.output(ctx, "-C", repoPath, "rev-parse", "--verify", ref+"^{commit}")
+}
+
+// count runs a git command that prints a single integer.
+func (r *RealRunner) count(ctx context.Context, args ...string) (int, error) {
+	out, err := r.output(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(out)
+	if err != nil {
+		return 0, fmt.Errorf("parsing commit count %q: %w", out, err)
+	}
+	return n, nil
+}
+
+// CommonDir returns the absolute path of the repository that owns a worktree.
+// For worktrees created by flow this is the bare clone.
+func (r *RealRunner) CommonDir(ctx context.Context, worktreePath string) (string, error) {
+	out, err := r.output(ctx, "-C", worktreePath, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(worktreePath, out)
+	}
+	return filepath.Clean(out), nil
+}
+
+// UpdateRef points ref at the commit target resolves to, creating ref if needed.
+func (r *RealRunner) UpdateRef(ctx context.Context, repoPath, ref, target string) error {
+	r.log().Debug("updating ref", "path", repoPath, "ref", ref, "target", target)
+	return r.run(ctx, "-C", repoPath, "update-ref", ref, target)
+}
+
+// GetConfig returns the value of a git config key, or an empty string if unset.
+func (r *RealRunner) GetConfig(ctx context.Context, repoPath, key string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "config", "--get", key)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("git config --get %s: %w", key, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SetConfig sets a git config key in the repository's config.
+func (r *RealRunner) SetConfig(ctx context.Context, repoPath, key, value string) error {
+	r.log().Debug("setting config", "path", repoPath, "key", key, "value", value)
+	return r.run(ctx, "-C", repoPath, "config", key, value)
+}
+
+// GetWorktreeConfig returns the value of a config key set for one worktree
+// with SetWorktreeConfig, or an empty string if unset. Keys in the shared
+// repository config are not read.
+func (r *RealRunner) GetWorktreeConfig(ctx context.Context, worktreePath, key string) (string, error) {
+	enabled, err := r.GetConfig(ctx, worktreePath, "extensions.worktreeConfig")
+	if err != nil || enabled != "true" {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", worktreePath, "config", "--worktree", "--get", key)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("git config --worktree --get %s: %w", key, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SetWorktreeConfig sets a config key for one worktree only, so worktrees
+// of the same repository in different workspaces don't share it.
+func (r *RealRunner) SetWorktreeConfig(ctx context.Context, worktreePath, key, value string) error {
+	if err := r.enableWorktreeConfig(ctx, worktreePath); err != nil {
+		return fmt.Errorf("enabling per-worktree config: %w", err)
+	}
+	r.log().Debug("setting worktree config", "path", worktreePath, "key", key, "value", value)
+	return r.run(ctx, "-C", worktreePath, "config", "--worktree", key, value)
+}
+
+// enableWorktreeConfig turns on extensions.worktreeConfig in the repository
+// a worktree belongs to. core.bare is moved from the shared config to the
+// bare repo's own config.worktree first: once the extension is on, linked
+// worktrees would otherwise read core.bare=true and stop working.
+func (r *RealRunner) enableWorktreeConfig(ctx context.Context, worktreePath string) error {
+	enabled, err := r.GetConfig(ctx, worktreePath, "extensions.worktreeConfig")
+	if err != nil || enabled == "true" {
+		return err
+	}
+	common, err := r.CommonDir(ctx, worktreePath)
+	if err != nil {
+		return err
+	}
+	shared := filepath.Join(common, "config")
+	bare, err := r.GetConfig(ctx, common, "core.bare")
+	if err != nil {
+		return err
+	}
+	if bare == "true" {
+		if err := r.run(ctx, "config", "--file", filepath.Join(common, "config.worktree"), "core.bare", "true"); err != nil {
+			return err
+		}
+		if err := r.run(ctx, "config", "--file", shared, "--unset", "core.bare"); err != nil {
+			return err
+		}
+	}
+	return r.run(ctx, "config", "--file", shared, "extensions.worktreeConfig", "true")
+}
+
+// DiffWorktree returns a binary patch of every uncommitted change in a
+// worktree relative to HEAD, including untracked (but not ignored) files.
+// Changes are staged into a copy of the index, so the worktree's own index
+// is left untouched.
+func (r *RealRunner) DiffWorktree(ctx context.Context, worktreePath string) ([]byte, error) {
+	r.log().Debug("capturing worktree changes", "path", worktreePath)
+
+	tmp, err := os.MkdirTemp("", "flow-index-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	tmpIndex := filepath.Join(tmp, "index")
+
+	index, err := r.output(ctx, "-C", worktreePath, "rev-parse", "--path-format=absolute", "--git-path", "index")
+	if err != nil {
+		return nil, err
+	}
+	if data, err := os.ReadFile(index); err == nil {
+		if err := os.WriteFile(tmpIndex, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + tmpIndex}
+	if _, err := r.rawOutput(ctx, env, "-C", worktreePath, "add", "-A"); err != nil {
+		return nil, err
+	}
+	return r.rawOutput(ctx, env, "-C", worktreePath, "diff", "--cached", "--binary", "HEAD")
+}
+
+// ApplyPatch applies a patch created by DiffWorktree to a worktree.
+func (r *RealRunner) ApplyPatch(ctx context.Context, worktreePath, patchFile string) error {
+	r.log().Debug("applying patch", "path", worktreePath, "patch", patchFile)
+	return r.run(ctx, "-C", worktreePath, "apply", "--binary", patchFile)
+}
+
+// CreateBundle writes the commits reachable from ref that no remote-tracking
+// ref contains to a git bundle file.
+func (r *RealRunner) CreateBundle(ctx context.Context, repoPath, bundleFile, ref string) error {
+	r.log().Debug("creating bundle", "path", repoPath, "bundle", bundleFile, "ref", ref)
+	return r.run(ctx, "-C", repoPath, "bundle", "create", bundleFile, ref, "--not", "--remotes")
+}
+
+// FetchBundle fetches refs from a bundle file into a repository.
+func (r *RealRunner) FetchBundle(ctx context.Context, repoPath, bundleFile, refspec string) error {
+	r.log().Debug("fetching bundle", "path", repoPath, "bundle", bundleFile, "refspec", refspec)
+	return r.run(ctx, "-C", repoPath, "fetch", bundleFile, refspec)
+}
+
+// StashCount counts stash entries made on branch. The stash is shared by
+// every worktree of a repository, so entries are matched by the branch named
+// in their message. A detached HEAD matches stashes made with no branch.
+func (r *RealRunner) StashCount(ctx context.Context, worktreePath, branch string) (int, error) {
+	r.log().Debug("counting stashes", "path", worktreePath, "branch", branch)
+	out, err := r.output(ctx, "-C", worktreePath, "stash", "list", "--format=%gs")
+	if err != nil {
+		return 0, err
+	}
+	if branch == "HEAD" {
+		branch = "(no branch)"
+	}
+	n := 0
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "WIP on "+branch+":") || strings.HasPrefix(line, "On "+branch+":") {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PruneWorktrees removes administrative entries for worktrees whose
+// directories no longer exist.
+func (r *RealRunner) PruneWorktrees(ctx context.Context, bareRepo string) error {
+	r.log().Debug("pruning worktree entries", "bare_repo", bareRepo)
+	return r.run(ctx, "-C", bareRepo, "worktree", "prune")
+}
+
+// ListWorktrees returns the paths of the linked worktrees registered in a
+// bare repo, including ones whose directories are gone.
+func (r *RealRunner) ListWorktrees(ctx context.Context, bareRepo string) ([]string, error) {
+	r.log().Debug("listing worktrees", "bare_repo", bareRepo)
+	out, err := r.output(ctx, "-C", bareRepo, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, block := range strings.Split(out, "\n\n") {
+		lines := strings.Split(block, "\n")
+		path, ok := strings.CutPrefix(lines[0], "worktree ")
+		if !ok || slices.Contains(lines[1:], "bare") {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// GarbageCollect runs git gc to repack objects and drop unreachable ones.
+func (r *RealRunner) GarbageCollect(ctx context.Context, repoPath string) error {
+	r.log().Debug("collecting garbage", "path", repoPath)
+	return r.run(ctx, "-C", repoPath, "gc", "--quiet")
+}
+
+// ListRefs returns the refs under prefix (e.g. "refs/heads/") mapped to the
+// commits they point at.
+func (r *RealRunner) ListRefs(ctx context.Context, repoPath, prefix string) (map[string]string, error) {
+	r.log().Debug("listing refs", "path", repoPath, "prefix", prefix)
+	out, err := r.output(ctx, "-C", repoPath, "for-each-ref", "--format=%(refname) %(objectname)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if name, sha, ok := strings.Cut(line, " "); ok {
+			refs[name] = sha
+		}
+	}
+	return refs, nil
+}
@@ -5,8 +5,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/milldr/flow/internal/giturl"
 )
 
 // initTestRepo creates a local git repo with one commit on "main", then
@@ -58,7 +61,7 @@ func TestBareCloneArgs(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // cancel immediately
 
-	err := r.BareClone(ctx, "github.com/test/repo", "/tmp/nonexistent-bare-clone-test")
+	err := r.BareClone(ctx, "github.com/test/repo", "/tmp/nonexistent-bare-clone-test", CloneOptions{})
 	if err == nil {
 		t.Fatal("expected error with cancelled context")
 	}
@@ -130,7 +133,7 @@ func TestBareCloneLocal(t *testing.T) {
 
 	r := &RealRunner{}
 	dest := filepath.Join(dir, "cloned.git")
-	if err := r.BareClone(context.Background(), src, dest); err != nil {
+	if err := r.BareClone(context.Background(), src, dest, CloneOptions{}); err != nil {
 		t.Fatalf("BareClone: %v", err)
 	}
 	if _, err := os.Stat(filepath.Join(dest, "HEAD")); err != nil {
@@ -146,6 +149,51 @@ func TestFetchReal(t *testing.T) {
 	}
 }
 
+func TestURLRules(t *testing.T) {
+	ctx := context.Background()
+	bare := initTestRepo(t)
+	dir := filepath.Dir(bare)
+	r := &RealRunner{URLs: &giturl.Rules{
+		Rewrites: []giturl.Rewrite{{InsteadOf: "https://mirror.test/", URL: dir + "/"}},
+	}}
+
+	// Bare hostnames get https:// before the rules apply. The clone goes
+	// through the rewrite, but origin keeps the URL it was given.
+	dest := filepath.Join(dir, "cloned.git")
+	if err := r.BareClone(ctx, "mirror.test/src", dest, CloneOptions{}); err != nil {
+		t.Fatalf("BareClone: %v", err)
+	}
+	origin, err := r.GetConfig(ctx, dest, "remote.origin.url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://mirror.test/src"; origin != want {
+		t.Errorf("origin = %q, want %q", origin, want)
+	}
+
+	// Fetches go through the rewrite without persisting it.
+	if err := r.Fetch(ctx, dest); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if ok, err := r.FetchBranch(ctx, dest, "main"); err != nil || !ok {
+		t.Fatalf("FetchBranch = %v, %v", ok, err)
+	}
+	origin, err = r.GetConfig(ctx, dest, "remote.origin.url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://mirror.test/src"; origin != want {
+		t.Errorf("origin after fetch = %q, want %q", origin, want)
+	}
+	config, err := os.ReadFile(filepath.Join(dest, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(config), dir) {
+		t.Errorf("rewritten URL persisted in config:\n%s", config)
+	}
+}
+
 func TestDefaultBranch(t *testing.T) {
 	bare := initTestRepo(t)
 	r := &RealRunner{}
@@ -219,6 +267,27 @@ func TestAddWorktreeNewBranch(t *testing.T) {
 	}
 }
 
+func TestCheckoutBranchUsedElsewhere(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+	dir := t.TempDir()
+	other, wt := filepath.Join(dir, "other"), filepath.Join(dir, "wt")
+
+	if err := r.AddWorktree(ctx, bare, other, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddWorktreeNewBranch(ctx, bare, wt, "feat/x", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CheckoutBranch(ctx, wt, "main"); err != nil {
+		t.Fatalf("CheckoutBranch of a branch checked out in another worktree: %v", err)
+	}
+	if got, err := r.CurrentBranch(ctx, wt); err != nil || got != "main" {
+		t.Errorf("CurrentBranch = %q, %v; want main", got, err)
+	}
+}
+
 func TestIsClean(t *testing.T) {
 	bare := initTestRepo(t)
 	r := &RealRunner{}
@@ -315,3 +384,537 @@ func TestEnsureRemoteRef(t *testing.T) {
 		t.Error("expected origin/main ref to exist")
 	}
 }
+
+// commitFile writes a file in a worktree and commits it.
+func commitFile(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", name}, {"commit", "-m", "add " + name}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestUnpushedCommits(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	if err := r.EnsureRemoteRef(ctx, bare, "main"); err != nil {
+		t.Fatalf("EnsureRemoteRef: %v", err)
+	}
+	wtPath := filepath.Join(t.TempDir(), "wt-unpushed")
+	if err := r.AddWorktreeNewBranch(ctx, bare, wtPath, "feat/local", "origin/main"); err != nil {
+		t.Fatalf("AddWorktreeNewBranch: %v", err)
+	}
+
+	n, err := r.UnpushedCommits(ctx, wtPath, "HEAD")
+	if err != nil {
+		t.Fatalf("UnpushedCommits: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("UnpushedCommits (fresh branch) = %d, want 0", n)
+	}
+
+	commitFile(t, wtPath, "a.txt")
+	commitFile(t, wtPath, "b.txt")
+
+	n, err = r.UnpushedCommits(ctx, bare, "refs/heads/feat/local")
+	if err != nil {
+		t.Fatalf("UnpushedCommits: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("UnpushedCommits = %d, want 2", n)
+	}
+
+	n, err = r.UnpushedCommits(ctx, bare, "refs/heads/does-not-exist")
+	if err != nil {
+		t.Fatalf("UnpushedCommits (missing ref): %v", err)
+	}
+	if n != 0 {
+		t.Errorf("UnpushedCommits (missing ref) = %d, want 0", n)
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+	wtPath := filepath.Join(t.TempDir(), "wt-common")
+
+	if err := r.AddWorktree(ctx, bare, wtPath, "main"); err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+
+	got, err := r.CommonDir(ctx, wtPath)
+	if err != nil {
+		t.Fatalf("CommonDir: %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(bare)
+	if resolved, _ := filepath.EvalSymlinks(got); resolved != want {
+		t.Errorf("CommonDir = %q, want %q", got, bare)
+	}
+}
+
+func TestCommitsAheadAndRefExists(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	wtPath := filepath.Join(t.TempDir(), "wt-ahead")
+	if err := r.AddWorktreeNewBranch(ctx, bare, wtPath, "feat/ahead", "main"); err != nil {
+		t.Fatalf("AddWorktreeNewBranch: %v", err)
+	}
+	commitFile(t, wtPath, "one.txt")
+
+	n, err := r.CommitsAhead(ctx, bare, "main", "feat/ahead")
+	if err != nil {
+		t.Fatalf("CommitsAhead: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("CommitsAhead = %d, want 1", n)
+	}
+
+	for ref, want := range map[string]bool{
+		"refs/heads/main":      true,
+		"feat/ahead":           true,
+		"refs/heads/missing":   false,
+		"origin/never-fetched": false,
+	} {
+		got, err := r.RefExists(ctx, bare, ref)
+		if err != nil {
+			t.Fatalf("RefExists(%q): %v", ref, err)
+		}
+		if got != want {
+			t.Errorf("RefExists(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func TestUpdateRef(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	ref := "refs/flow/backup/20260101-000000/main"
+	if err := r.UpdateRef(ctx, bare, ref, "refs/heads/main"); err != nil {
+		t.Fatalf("UpdateRef: %v", err)
+	}
+	ok, err := r.RefExists(ctx, bare, ref)
+	if err != nil {
+		t.Fatalf("RefExists: %v", err)
+	}
+	if !ok {
+		t.Errorf("backup ref %s not created", ref)
+	}
+	if n, err := r.CommitsAhead(ctx, bare, "refs/heads/main", ref); err != nil || n != 0 {
+		t.Errorf("CommitsAhead(main, backup) = %d, %v; want 0", n, err)
+	}
+
+	main, err := r.ResolveRef(ctx, bare, "main")
+	if err != nil {
+		t.Fatalf("ResolveRef: %v", err)
+	}
+	backup, err := r.ResolveRef(ctx, bare, ref)
+	if err != nil {
+		t.Fatalf("ResolveRef: %v", err)
+	}
+	if len(main) != 40 || main != backup {
+		t.Errorf("ResolveRef main = %q, backup = %q; want the same full hash", main, backup)
+	}
+	if _, err := r.ResolveRef(ctx, bare, "does-not-exist"); err == nil {
+		t.Error("ResolveRef of missing ref should fail")
+	}
+}
+
+func TestGetSetConfig(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	key := "branch.feat/x.flowBase"
+	got, err := r.GetConfig(ctx, bare, key)
+	if err != nil {
+		t.Fatalf("GetConfig (unset): %v", err)
+	}
+	if got != "" {
+		t.Errorf("GetConfig (unset) = %q, want empty", got)
+	}
+
+	if err := r.SetConfig(ctx, bare, key, "staging"); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	got, err = r.GetConfig(ctx, bare, key)
+	if err != nil {
+		t.Fatalf("GetConfig: %v", err)
+	}
+	if got != "staging" {
+		t.Errorf("GetConfig = %q, want staging", got)
+	}
+}
+
+func TestWorktreeConfig(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+	dir := t.TempDir()
+	one, two := filepath.Join(dir, "one"), filepath.Join(dir, "two")
+	if err := r.AddWorktree(ctx, bare, one, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddWorktree(ctx, bare, two, "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "branch.main.flowBase"
+	if err := r.SetConfig(ctx, bare, key, "shared"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.GetWorktreeConfig(ctx, one, key); err != nil || got != "" {
+		t.Errorf("GetWorktreeConfig before any is set = %q, %v; want empty", got, err)
+	}
+
+	if err := r.SetWorktreeConfig(ctx, one, key, "develop"); err != nil {
+		t.Fatalf("SetWorktreeConfig: %v", err)
+	}
+	if err := r.SetWorktreeConfig(ctx, two, key, "staging"); err != nil {
+		t.Fatalf("SetWorktreeConfig: %v", err)
+	}
+	for path, want := range map[string]string{one: "develop", two: "staging"} {
+		if got, err := r.GetWorktreeConfig(ctx, path, key); err != nil || got != want {
+			t.Errorf("GetWorktreeConfig(%s) = %q, %v; want %s", filepath.Base(path), got, err, want)
+		}
+	}
+
+	// Turning on per-worktree config must leave the worktrees usable and
+	// the bare repo bare.
+	if _, err := r.IsClean(ctx, one); err != nil {
+		t.Errorf("worktree broken after enabling per-worktree config: %v", err)
+	}
+	if out, err := r.output(ctx, "-C", bare, "rev-parse", "--is-bare-repository"); err != nil || out != "true" {
+		t.Errorf("bare repo is-bare = %q, %v; want true", out, err)
+	}
+}
+
+func TestRescueRoundTrip(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	if err := r.EnsureRemoteRef(ctx, bare, "main"); err != nil {
+		t.Fatalf("EnsureRemoteRef: %v", err)
+	}
+	wtPath := filepath.Join(t.TempDir(), "wt")
+	if err := r.AddWorktreeNewBranch(ctx, bare, wtPath, "feat/rescue", "origin/main"); err != nil {
+		t.Fatalf("AddWorktreeNewBranch: %v", err)
+	}
+	commitFile(t, wtPath, "committed.txt")
+
+	// Dirty the worktree: modify a tracked file and add an untracked one.
+	if err := os.WriteFile(filepath.Join(wtPath, "README.md"), []byte("# changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wtPath, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch, err := r.DiffWorktree(ctx, wtPath)
+	if err != nil {
+		t.Fatalf("DiffWorktree: %v", err)
+	}
+	if !strings.Contains(string(patch), "new.txt") || !strings.Contains(string(patch), "README.md") {
+		t.Errorf("patch missing changes:\n%s", patch)
+	}
+	// The worktree's own index must be untouched.
+	status, err := r.output(ctx, "-C", wtPath, "status", "--porcelain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(status, "?? new.txt") {
+		t.Errorf("status = %q, want new.txt still untracked", status)
+	}
+
+	dir := t.TempDir()
+	patchFile := filepath.Join(dir, "wt.patch")
+	bundleFile := filepath.Join(dir, "wt.bundle")
+	if err := os.WriteFile(patchFile, patch, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateBundle(ctx, bare, bundleFile, "refs/heads/feat/rescue"); err != nil {
+		t.Fatalf("CreateBundle: %v", err)
+	}
+
+	// Lose everything, then restore from the patch and bundle.
+	if err := r.RemoveWorktree(ctx, bare, wtPath); err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	if err := r.DeleteBranch(ctx, bare, "feat/rescue"); err != nil {
+		t.Fatalf("DeleteBranch: %v", err)
+	}
+	if err := r.FetchBundle(ctx, bare, bundleFile, "refs/heads/feat/rescue:refs/heads/feat/rescue"); err != nil {
+		t.Fatalf("FetchBundle: %v", err)
+	}
+	if err := r.AddWorktree(ctx, bare, wtPath, "feat/rescue"); err != nil {
+		t.Fatalf("AddWorktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wtPath, "committed.txt")); err != nil {
+		t.Errorf("committed.txt not restored from bundle: %v", err)
+	}
+	if err := r.ApplyPatch(ctx, wtPath, patchFile); err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(wtPath, "README.md"))
+	if err != nil || string(data) != "# changed" {
+		t.Errorf("README.md = %q, %v; want patched content", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(wtPath, "new.txt")); err != nil {
+		t.Errorf("new.txt not restored from patch: %v", err)
+	}
+}
+
+func TestStashCount(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	if err := r.EnsureRemoteRef(ctx, bare, "main"); err != nil {
+		t.Fatalf("EnsureRemoteRef: %v", err)
+	}
+	wtPath := filepath.Join(t.TempDir(), "wt")
+	if err := r.AddWorktreeNewBranch(ctx, bare, wtPath, "feat/stash", "origin/main"); err != nil {
+		t.Fatalf("AddWorktreeNewBranch: %v", err)
+	}
+
+	n, err := r.StashCount(ctx, wtPath, "feat/stash")
+	if err != nil {
+		t.Fatalf("StashCount: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("StashCount (no stashes) = %d, want 0", n)
+	}
+
+	if err := os.WriteFile(filepath.Join(wtPath, "README.md"), []byte("# changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "-C", wtPath, "stash")
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git stash: %v\n%s", err, out)
+	}
+
+	n, err = r.StashCount(ctx, wtPath, "feat/stash")
+	if err != nil {
+		t.Fatalf("StashCount: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("StashCount = %d, want 1", n)
+	}
+	if n, _ := r.StashCount(ctx, wtPath, "feat"); n != 0 {
+		t.Errorf("StashCount(other branch) = %d, want 0", n)
+	}
+}
+
+func TestPruneWorktrees(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	if err := r.EnsureRemoteRef(ctx, bare, "main"); err != nil {
+		t.Fatalf("EnsureRemoteRef: %v", err)
+	}
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "keep")
+	gone := filepath.Join(dir, "gone")
+	if err := r.AddWorktreeNewBranch(ctx, bare, keep, "feat/keep", "origin/main"); err != nil {
+		t.Fatalf("AddWorktreeNewBranch: %v", err)
+	}
+	if err := r.AddWorktreeNewBranch(ctx, bare, gone, "feat/gone", "origin/main"); err != nil {
+		t.Fatalf("AddWorktreeNewBranch: %v", err)
+	}
+
+	paths, err := r.ListWorktrees(ctx, bare)
+	if err != nil {
+		t.Fatalf("ListWorktrees: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("ListWorktrees = %v, want 2 worktrees", paths)
+	}
+
+	if err := os.RemoveAll(gone); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PruneWorktrees(ctx, bare); err != nil {
+		t.Fatalf("PruneWorktrees: %v", err)
+	}
+	paths, err = r.ListWorktrees(ctx, bare)
+	if err != nil {
+		t.Fatalf("ListWorktrees: %v", err)
+	}
+	if len(paths) != 1 || filepath.Base(paths[0]) != "keep" {
+		t.Errorf("ListWorktrees after prune = %v, want [keep]", paths)
+	}
+
+	if err := r.GarbageCollect(ctx, bare); err != nil {
+		t.Errorf("GarbageCollect: %v", err)
+	}
+}
+
+func TestListRefs(t *testing.T) {
+	bare := initTestRepo(t)
+	r := &RealRunner{}
+	ctx := context.Background()
+
+	refs, err := r.ListRefs(ctx, bare, "refs/heads/")
+	if err != nil {
+		t.Fatalf("ListRefs: %v", err)
+	}
+	if len(refs) != 1 || len(refs["refs/heads/main"]) != 40 {
+		t.Errorf("ListRefs = %v, want only refs/heads/main", refs)
+	}
+
+	refs, err = r.ListRefs(ctx, bare, "refs/flow/")
+	if err != nil {
+		t.Fatalf("ListRefs: %v", err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("ListRefs(refs/flow/) = %v, want none", refs)
+	}
+}
+
+func TestCloneOptions(t *testing.T) {
+	ctx := context.Background()
+	bare := initTestRepo(t)
+	dir := filepath.Dir(bare)
+	src := filepath.Join(dir, "src")
+	commitFile(t, src, "second.txt")
+	for _, args := range [][]string{{"branch", "feat"}, {"config", "uploadpack.allowFilter", "true"}} {
+		if out, err := exec.Command("git", append([]string{"-C", src}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	// file:// makes git use the transport protocol, which honors --depth and
+	// --filter for local repos.
+	url := "file://" + src
+	r := &RealRunner{}
+
+	t.Run("depth", func(t *testing.T) {
+		dest := filepath.Join(dir, "shallow.git")
+		if err := r.BareClone(ctx, url, dest, CloneOptions{Depth: 1}); err != nil {
+			t.Fatalf("BareClone: %v", err)
+		}
+		if out, _ := r.output(ctx, "-C", dest, "rev-parse", "--is-shallow-repository"); out != "true" {
+			t.Errorf("is-shallow-repository = %q, want true", out)
+		}
+		// --depth alone must not drop the other branches.
+		if ok, _ := r.BranchExists(ctx, dest, "feat"); !ok {
+			t.Error("expected feat in shallow clone")
+		}
+		if err := r.Fetch(ctx, dest); err != nil {
+			t.Errorf("Fetch: %v", err)
+		}
+	})
+
+	t.Run("single branch", func(t *testing.T) {
+		dest := filepath.Join(dir, "single.git")
+		if err := r.BareClone(ctx, url, dest, CloneOptions{SingleBranch: true}); err != nil {
+			t.Fatalf("BareClone: %v", err)
+		}
+		if ok, _ := r.BranchExists(ctx, dest, "feat"); ok {
+			t.Fatal("feat should not be cloned")
+		}
+		found, err := r.FetchBranch(ctx, dest, "feat")
+		if err != nil || !found {
+			t.Fatalf("FetchBranch(feat) = %v, %v", found, err)
+		}
+		if ok, _ := r.BranchExists(ctx, dest, "feat"); !ok {
+			t.Error("expected feat after FetchBranch")
+		}
+		found, err = r.FetchBranch(ctx, dest, "missing")
+		if err != nil || found {
+			t.Errorf("FetchBranch(missing) = %v, %v, want false, nil", found, err)
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		dest := filepath.Join(dir, "partial.git")
+		if err := r.BareClone(ctx, url, dest, CloneOptions{Filter: "blob:none"}); err != nil {
+			t.Fatalf("BareClone: %v", err)
+		}
+		if got, _ := r.GetConfig(ctx, dest, "remote.origin.partialclonefilter"); got != "blob:none" {
+			t.Errorf("partialclonefilter = %q, want blob:none", got)
+		}
+		// Checking out fetches the missing blobs.
+		wt := filepath.Join(dir, "partial-wt")
+		if err := r.AddWorktree(ctx, dest, wt, "feat"); err != nil {
+			t.Fatalf("AddWorktree: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(wt, "second.txt")); err != nil {
+			t.Errorf("expected second.txt in worktree: %v", err)
+		}
+	})
+}
+
+func TestPinnedWorktree(t *testing.T) {
+	ctx := context.Background()
+	bare := initTestRepo(t)
+	src := filepath.Join(filepath.Dir(bare), "src")
+	r := &RealRunner{}
+
+	// Tag and commit made after the clone, so the bare repo has neither.
+	commitFile(t, src, "tagged.txt")
+	if out, err := exec.Command("git", "-C", src, "tag", "v1.0.0").CombinedOutput(); err != nil {
+		t.Fatalf("git tag: %v\n%s", err, out)
+	}
+	commitFile(t, src, "later.txt")
+	later, err := r.ResolveRef(ctx, src, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.FetchRef(ctx, bare, "v1.0.0"); err != nil {
+		t.Fatalf("FetchRef(tag): %v", err)
+	}
+	tagged, err := r.ResolveRef(ctx, bare, "v1.0.0")
+	if err != nil {
+		t.Fatalf("ResolveRef(v1.0.0) after FetchRef: %v", err)
+	}
+	if err := r.FetchRef(ctx, bare, later); err != nil {
+		t.Fatalf("FetchRef(commit): %v", err)
+	}
+	if ok, _ := r.RefExists(ctx, bare, later); !ok {
+		t.Error("expected the commit in the bare repo after FetchRef")
+	}
+	if err := r.FetchRef(ctx, bare, "v9.9.9"); err == nil {
+		t.Error("FetchRef(v9.9.9) = nil, want an error for a missing ref")
+	}
+
+	wt := filepath.Join(t.TempDir(), "pinned")
+	if err := r.AddWorktreeDetached(ctx, bare, wt, tagged); err != nil {
+		t.Fatalf("AddWorktreeDetached: %v", err)
+	}
+	if branch, _ := r.CurrentBranch(ctx, wt); branch != "HEAD" {
+		t.Errorf("CurrentBranch = %q, want HEAD (detached)", branch)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "later.txt")); !os.IsNotExist(err) {
+		t.Error("later.txt should not be in a worktree pinned to v1.0.0")
+	}
+
+	if err := r.CheckoutDetached(ctx, wt, later); err != nil {
+		t.Fatalf("CheckoutDetached: %v", err)
+	}
+	if head, _ := r.ResolveRef(ctx, wt, "HEAD"); head != later {
+		t.Errorf("HEAD = %s, want %s", head, later)
+	}
+}
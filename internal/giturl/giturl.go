@@ -0,0 +1,117 @@
+// Package giturl normalizes git remote URLs to canonical repo keys.
+package giturl
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Normalize returns the canonical host/owner/repo key for a git remote, so
+// every spelling of the same repo maps to one key:
+//
+//	git@github.com:org/repo.git        → github.com/org/repo
+//	https://github.com/org/repo        → github.com/org/repo
+//	ssh://git@host:22/org/repo.git     → host/org/repo
+//	ssh://git@host:2222/org/repo.git   → host:2222/org/repo
+//	github.com/org/repo                → github.com/org/repo
+//
+// The host is lowercased and users, default ports and a trailing .git are
+// dropped. A non-default port is kept, since it may be a different server.
+// Local paths and file:// URLs keep their path without the leading slash.
+func Normalize(raw string) string {
+	s := strings.TrimSpace(raw)
+	host, p := split(s)
+	p = strings.Trim(path.Clean("/"+p), "/")
+	p = strings.TrimSuffix(p, ".git")
+	if host == "" {
+		return p
+	}
+	if p == "" {
+		return host
+	}
+	return host + "/" + p
+}
+
+// Slug returns the repo path without the host, e.g. "org/repo".
+func Slug(raw string) string {
+	key := Normalize(raw)
+	if _, rest, ok := strings.Cut(key, "/"); ok && !isLocal(raw) {
+		return rest
+	}
+	return key
+}
+
+// Name returns the last segment of the repo key, e.g. "repo".
+func Name(raw string) string {
+	return path.Base(Normalize(raw))
+}
+
+// split separates a remote into lowercased host and path.
+func split(s string) (string, string) {
+	// scheme://[user@]host[:port]/path
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err == nil {
+			if u.Scheme == "file" {
+				return "", u.Path
+			}
+			host := strings.ToLower(u.Hostname())
+			if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+				host += ":" + port
+			}
+			return host, u.Path
+		}
+	}
+
+	if isLocal(s) {
+		return "", s
+	}
+
+	// scp-like: [user@]host:path, unless it is a key with a port
+	if i := strings.Index(s, ":"); i > 0 && !strings.Contains(s[:i], "/") && !hasPort(s[i+1:]) {
+		host := s[:i]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+		return strings.ToLower(host), s[i+1:]
+	}
+
+	// host/path without a scheme
+	host, p, _ := strings.Cut(s, "/")
+	if j := strings.LastIndex(host, "@"); j >= 0 {
+		host = host[j+1:]
+	}
+	return strings.ToLower(host), p
+}
+
+// defaultPorts maps URL schemes to the port that needn't be part of a key.
+var defaultPorts = map[string]string{
+	"ssh":     "22",
+	"git+ssh": "22",
+	"https":   "443",
+	"http":    "80",
+	"git":     "9418",
+}
+
+// hasPort reports whether rest, what follows the first colon of a remote
+// without a scheme, starts with a port: digits and then a slash, as in the
+// key host:2222/org/repo.
+func hasPort(rest string) bool {
+	port, _, ok := strings.Cut(rest, "/")
+	if !ok || port == "" {
+		return false
+	}
+	for _, c := range port {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isLocal reports whether s is a filesystem path rather than a remote.
+func isLocal(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") ||
+		strings.HasPrefix(s, "../") || strings.HasPrefix(s, "file://")
+}
@@ -0,0 +1,88 @@
+package giturl
+
+import (
+	"os"
+	"strings"
+)
+
+// Transport is the protocol used to reach a host.
+type Transport string
+
+// Supported transports.
+const (
+	TransportSSH   Transport = "ssh"
+	TransportHTTPS Transport = "https"
+)
+
+// Rewrite replaces a URL prefix, like git's url.<url>.insteadOf. $VAR and
+// ${VAR} in URL are expanded from the environment when applied, so tokens
+// can stay out of the config file.
+type Rewrite struct {
+	InsteadOf string `yaml:"insteadOf"`
+	URL       string `yaml:"url"`
+}
+
+// Rules rewrite the URLs in state files into the URLs git should use on
+// this machine.
+type Rules struct {
+	// Transports maps a host to the protocol to reach it with.
+	Transports map[string]Transport
+	Rewrites   []Rewrite
+}
+
+// Apply returns the URL to clone or fetch raw with. The host's preferred
+// transport is applied first, then the longest matching rewrite. A URL with
+// an explicit non-default port keeps its transport, since the port belongs
+// to it. Applying the rules to their own output returns it unchanged.
+func (r *Rules) Apply(raw string) string {
+	if r == nil {
+		return raw
+	}
+	u := strings.TrimSpace(raw)
+
+	if !isLocal(u) {
+		key := Normalize(u)
+		host, p, _ := strings.Cut(key, "/")
+		if strings.Contains(host, ":") {
+			host = ""
+		}
+		switch r.Transports[host] {
+		case TransportHTTPS:
+			u = "https://" + key
+		case TransportSSH:
+			u = "git@" + host + ":" + p + ".git"
+		}
+	}
+
+	best := -1
+	for i, rw := range r.Rewrites {
+		if rw.InsteadOf != "" && strings.HasPrefix(u, rw.InsteadOf) &&
+			(best < 0 || len(rw.InsteadOf) > len(r.Rewrites[best].InsteadOf)) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		rw := r.Rewrites[best]
+		u = os.ExpandEnv(rw.URL) + strings.TrimPrefix(u, rw.InsteadOf)
+	}
+	return u
+}
+
+// Redact hides the credentials in a URL with a scheme, for logging.
+// Anything else is returned unchanged.
+func Redact(s string) string {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok {
+		return s
+	}
+	authority, path, _ := strings.Cut(rest, "/")
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return s
+	}
+	u := scheme + "://***" + authority[at:]
+	if strings.Contains(rest, "/") {
+		u += "/" + path
+	}
+	return u
+}
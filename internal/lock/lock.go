@@ -0,0 +1,109 @@
+// Package lock provides advisory file locks that keep flow processes from
+// working on the same workspace or bare repo at the same time.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBusy matches a *BusyError with errors.Is.
+var ErrBusy = errors.New("busy")
+
+// pollInterval is how often a held lock is retried while waiting.
+const pollInterval = 100 * time.Millisecond
+
+// BusyError is returned when a lock is still held by another process after
+// the wait timeout.
+type BusyError struct {
+	Name    string // what is locked, e.g. "workspace calm-delta"
+	PID     int    // holder's process ID; zero if unknown
+	Command string // holder's command line
+}
+
+func (e *BusyError) Error() string {
+	if e.PID == 0 {
+		return e.Name + " is busy (locked by another flow process)"
+	}
+	return fmt.Sprintf("%s is busy (pid %d, command %q)", e.Name, e.PID, e.Command)
+}
+
+// Is reports whether target is ErrBusy.
+func (e *BusyError) Is(target error) bool {
+	return target == ErrBusy
+}
+
+// Lock is a held lock.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire takes an exclusive lock on the file at path, creating it and its
+// directory if needed. While another process (or another Acquire in this
+// one) holds it, Acquire retries until timeout has passed and then returns
+// a *BusyError naming the holder; a zero timeout tries once. The holder's
+// PID and command line are written to the file for that error.
+func Acquire(ctx context.Context, path, name string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = f.Close()
+			return nil, fmt.Errorf("locking %s: %w", name, err)
+		}
+		if !time.Now().Before(deadline) {
+			_ = f.Close()
+			return nil, holder(path, name)
+		}
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	info := strconv.Itoa(os.Getpid()) + "\n" + strings.Join(os.Args, " ") + "\n"
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(info), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release unlocks and closes the lock file. The file is left in place;
+// removing it could let two processes lock different files at one path.
+func (l *Lock) Release() {
+	_ = syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	_ = l.f.Close()
+}
+
+// holder reads the holder's PID and command from a lock file.
+func holder(path, name string) *BusyError {
+	busy := &BusyError{Name: name}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return busy
+	}
+	pid, command, _ := strings.Cut(string(data), "\n")
+	busy.PID, _ = strconv.Atoi(pid)
+	busy.Command = strings.TrimSpace(command)
+	return busy
+}
@@ -0,0 +1,83 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireBusy(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "locks", "ws.lock")
+
+	held, err := Acquire(ctx, path, "workspace ws", 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	start := time.Now()
+	_, err = Acquire(ctx, path, "workspace ws", 250*time.Millisecond)
+	if !errors.Is(err, ErrBusy) {
+		t.Fatalf("err = %v, want ErrBusy", err)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("returned after %v, want to wait for the timeout", waited)
+	}
+
+	var busy *BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("err = %T, want *BusyError", err)
+	}
+	if busy.PID != os.Getpid() {
+		t.Errorf("PID = %d, want %d", busy.PID, os.Getpid())
+	}
+	if !strings.Contains(err.Error(), "workspace ws is busy (pid ") {
+		t.Errorf("message = %q", err.Error())
+	}
+
+	held.Release()
+	l, err := Acquire(ctx, path, "workspace ws", 0)
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	l.Release()
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "repo.lock")
+
+	held, err := Acquire(ctx, path, "repo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		held.Release()
+	}()
+
+	l, err := Acquire(ctx, path, "repo", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	l.Release()
+}
+
+func TestAcquireCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo.lock")
+	held, err := Acquire(context.Background(), path, "repo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Acquire(ctx, path, "repo", time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
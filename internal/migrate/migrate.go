@@ -0,0 +1,95 @@
+// Package migrate upgrades flow's YAML documents from older apiVersions to
+// the current one.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the apiVersion flow writes and validates against.
+const CurrentVersion = "flow/v1"
+
+var (
+	// ErrNoMigration is returned for a document whose apiVersion no
+	// registered step upgrades from.
+	ErrNoMigration = errors.New("no migration")
+	// ErrVersionRemoved is returned when a step drops apiVersion.
+	ErrVersionRemoved = errors.New("apiVersion was removed")
+)
+
+// Step upgrades one kind of document from one apiVersion to the next.
+type Step struct {
+	Kind string // State, Status or Config
+	From string
+	To   string
+	// Apply edits the document's top-level mapping in place. Upgrade sets
+	// apiVersion to To afterwards.
+	Apply func(doc *yaml.Node) error
+}
+
+// Registry is an ordered set of steps.
+type Registry []Step
+
+// Documents holds the steps Load applies to state, status and config files.
+// When the format changes, bump CurrentVersion and add a step from the
+// previous version for each kind it affects.
+var Documents Registry
+
+// Upgrade migrates doc, a parsed document of the given kind, to
+// CurrentVersion in place, one step at a time. It returns the apiVersion doc
+// had, or "" if it needed no migration. A document without an apiVersion is
+// left for validation to reject.
+func (r Registry) Upgrade(kind string, doc *yaml.Node) (string, error) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) == 1 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return "", nil
+	}
+	version := apiVersion(root)
+	if version == nil || version.Value == "" || version.Value == CurrentVersion {
+		return "", nil
+	}
+
+	from := version.Value
+	for steps := 0; version.Value != CurrentVersion; steps++ {
+		step, ok := r.find(kind, version.Value)
+		if !ok || steps > len(r) {
+			return "", fmt.Errorf("%w from %s for %s", ErrNoMigration, version.Value, kind)
+		}
+		if err := step.Apply(root); err != nil {
+			return "", fmt.Errorf("migrating %s from %s to %s: %w", kind, step.From, step.To, err)
+		}
+		// Apply may have replaced the node.
+		if version = apiVersion(root); version == nil {
+			return "", fmt.Errorf("migrating %s from %s to %s: %w", kind, step.From, step.To, ErrVersionRemoved)
+		}
+		version.Value = step.To
+		version.Tag = "!!str"
+		version.Style = 0
+	}
+	return from, nil
+}
+
+func (r Registry) find(kind, from string) (Step, bool) {
+	for _, s := range r {
+		if s.Kind == kind && s.From == from {
+			return s, true
+		}
+	}
+	return Step{}, false
+}
+
+// apiVersion returns the value node of a mapping's apiVersion key.
+func apiVersion(m *yaml.Node) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == "apiVersion" && m.Content[i+1].Kind == yaml.ScalarNode {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renameKey returns a step body that renames a top-level key.
+func renameKey(from, to string) func(*yaml.Node) error {
+	return func(doc *yaml.Node) error {
+		for i := 0; i+1 < len(doc.Content); i += 2 {
+			if doc.Content[i].Value == from {
+				doc.Content[i].Value = to
+			}
+		}
+		return nil
+	}
+}
+
+var testRegistry = Registry{
+	{Kind: "State", From: "flow/v0", To: "flow/v0.5", Apply: renameKey("meta", "metadata")},
+	{Kind: "State", From: "flow/v0.5", To: CurrentVersion, Apply: renameKey("repositories", "repos")},
+	{Kind: "Status", From: "flow/v0", To: CurrentVersion, Apply: renameKey("states", "spec")},
+}
+
+func parse(t *testing.T, data string) *yaml.Node {
+	t.Helper()
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &n); err != nil {
+		t.Fatal(err)
+	}
+	return &n
+}
+
+func TestUpgrade(t *testing.T) {
+	doc := parse(t, `# old workspace
+apiVersion: flow/v0
+kind: State
+meta:
+  name: ws # kept
+repositories: []
+`)
+	from, err := testRegistry.Upgrade("State", doc)
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if from != "flow/v0" {
+		t.Errorf("from = %q, want flow/v0", from)
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# old workspace
+apiVersion: flow/v1
+kind: State
+metadata:
+    name: ws # kept
+repos: []
+`
+	if string(out) != want {
+		t.Errorf("upgraded:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestUpgradeCurrent(t *testing.T) {
+	doc := parse(t, "apiVersion: flow/v1\nkind: State\n")
+	from, err := testRegistry.Upgrade("State", doc)
+	if err != nil || from != "" {
+		t.Errorf("Upgrade = %q, %v; want no migration", from, err)
+	}
+}
+
+func TestUpgradeUnknownVersion(t *testing.T) {
+	// Steps are per kind: the Status step doesn't apply to a Config.
+	doc := parse(t, "apiVersion: flow/v0\nkind: Config\n")
+	_, err := testRegistry.Upgrade("Config", doc)
+	if !errors.Is(err, ErrNoMigration) {
+		t.Fatalf("err = %v, want ErrNoMigration", err)
+	}
+	if !strings.Contains(err.Error(), "flow/v0") {
+		t.Errorf("err = %v, want it to name the version", err)
+	}
+}
+
+func TestUpgradeStepError(t *testing.T) {
+	errBoom := errors.New("boom")
+	r := Registry{{Kind: "State", From: "flow/v0", To: CurrentVersion, Apply: func(*yaml.Node) error { return errBoom }}}
+	_, err := r.Upgrade("State", parse(t, "apiVersion: flow/v0\n"))
+	if !errors.Is(err, errBoom) {
+		t.Errorf("err = %v, want the step's error", err)
+	}
+}
@@ -0,0 +1,209 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Errors reported by Check.
+var (
+	ErrUnknownField   = errors.New("unknown field")
+	ErrDuplicateField = errors.New("duplicate field")
+	ErrWrongType      = errors.New("wrong type")
+	ErrMissingField   = errors.New("required field is missing")
+	ErrInvalidValue   = errors.New("invalid value")
+)
+
+// Problem is one thing wrong with a document.
+type Problem struct {
+	Field  string // where in the document, e.g. spec.repos[2].branch
+	Line   int    // 1-based position in the file; zero if unknown
+	Column int
+	Err    error
+}
+
+func (p *Problem) Error() string {
+	msg := p.Err.Error()
+	if p.Field != "" {
+		msg = p.Field + ": " + msg
+	}
+	if p.Line > 0 {
+		msg = fmt.Sprintf("%d:%d: %s", p.Line, p.Column, msg)
+	}
+	return msg
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// Check reports every place where the YAML value n doesn't match s.
+// A missing required field is reported at the mapping that lacks it.
+func Check(n *yaml.Node, s *Schema) []*Problem {
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return nil
+		}
+		n = n.Content[0]
+	}
+	var c checker
+	c.walk(n, s, "")
+	return c.problems
+}
+
+type checker struct {
+	problems []*Problem
+}
+
+func (c *checker) add(n *yaml.Node, field string, err error) {
+	c.problems = append(c.problems, &Problem{Field: field, Line: n.Line, Column: n.Column, Err: err})
+}
+
+func (c *checker) walk(n *yaml.Node, s *Schema, field string) {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	// A null value is the same as leaving the field out.
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		c.object(n, s, field)
+	case "array":
+		if n.Kind != yaml.SequenceNode {
+			c.add(n, field, wrongType("a list", n))
+			return
+		}
+		if len(n.Content) < s.MinItems {
+			c.add(n, field, fmt.Errorf("%w: must have at least %d items", ErrInvalidValue, s.MinItems))
+		}
+		for i, item := range n.Content {
+			if s.Items != nil {
+				c.walk(item, s.Items, fmt.Sprintf("%s[%d]", field, i))
+			}
+		}
+	case "string":
+		if n.Kind != yaml.ScalarNode {
+			c.add(n, field, wrongType("a string", n))
+			return
+		}
+		c.value(n, s, field)
+	case "boolean":
+		if n.Kind != yaml.ScalarNode || n.Tag != "!!bool" {
+			c.add(n, field, wrongType("true or false", n))
+		}
+	case "integer", "number":
+		if n.Kind != yaml.ScalarNode || (n.Tag != "!!int" && (s.Type == "integer" || n.Tag != "!!float")) {
+			c.add(n, field, wrongType("a number", n))
+			return
+		}
+		if s.Minimum != nil {
+			if v, err := strconv.ParseFloat(n.Value, 64); err == nil && v < float64(*s.Minimum) {
+				c.add(n, field, fmt.Errorf("%w: must be at least %d", ErrInvalidValue, *s.Minimum))
+			}
+		}
+	}
+}
+
+func (c *checker) object(n *yaml.Node, s *Schema, field string) {
+	if n.Kind != yaml.MappingNode {
+		c.add(n, field, wrongType("a mapping", n))
+		return
+	}
+
+	seen := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Value == "<<" {
+			continue
+		}
+		name := joinField(field, key.Value)
+		if prev, ok := seen[key.Value]; ok {
+			c.add(key, name, fmt.Errorf("%w, already set on line %d", ErrDuplicateField, prev.Line))
+			continue
+		}
+		seen[key.Value] = key
+
+		switch prop, ok := s.Properties[key.Value]; {
+		case ok:
+			c.walk(value, prop, name)
+		case s.Values != nil:
+			c.walk(value, s.Values, name)
+		case s.Closed:
+			c.add(key, name, ErrUnknownField)
+		}
+	}
+
+	for _, name := range s.Required {
+		if _, ok := seen[name]; !ok && !mergedKey(n, name) {
+			c.add(n, joinField(field, name), ErrMissingField)
+		}
+	}
+}
+
+// value checks a scalar against const and enum.
+func (c *checker) value(n *yaml.Node, s *Schema, field string) {
+	if s.Const != "" && n.Value != s.Const {
+		c.add(n, field, fmt.Errorf("%w: must be %s", ErrInvalidValue, s.Const))
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, n.Value) {
+		c.add(n, field, fmt.Errorf("%w: must be one of %v", ErrInvalidValue, s.Enum))
+	}
+}
+
+// mergedKey reports whether a mapping gets key from a << merge.
+func mergedKey(n *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value != "<<" {
+			continue
+		}
+		sources := []*yaml.Node{n.Content[i+1]}
+		if sources[0].Kind == yaml.SequenceNode {
+			sources = sources[0].Content
+		}
+		for _, src := range sources {
+			if src.Kind == yaml.AliasNode && src.Alias != nil {
+				src = src.Alias
+			}
+			if src.Kind == yaml.MappingNode && (hasKey(src, key) || mergedKey(src, key)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasKey(n *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+func joinField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func wrongType(want string, n *yaml.Node) error {
+	got := "a value"
+	switch n.Kind {
+	case yaml.MappingNode:
+		got = "a mapping"
+	case yaml.SequenceNode:
+		got = "a list"
+	case yaml.ScalarNode:
+		got = fmt.Sprintf("%q", n.Value)
+	}
+	return fmt.Errorf("%w: want %s, got %s", ErrWrongType, want, got)
+}
@@ -0,0 +1,123 @@
+// Package schema generates JSON Schemas for flow's YAML files from their Go
+// types, and checks YAML documents against them, so editors and flow
+// validate the same rules.
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Draft is the JSON Schema dialect generated schemas declare.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is the subset of JSON Schema that flow's file formats need.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Const       string             `json:"const,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	MinItems    int                `json:"minItems,omitempty"`
+	Minimum     *int               `json:"minimum,omitempty"`
+
+	// Closed rejects properties not listed in Properties.
+	Closed bool `json:"-"`
+	// Values is the schema for every property of a map-like object.
+	Values *Schema `json:"-"`
+}
+
+// MarshalJSON encodes Closed and Values as additionalProperties.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	out := struct {
+		*plain
+		AdditionalProperties any `json:"additionalProperties,omitempty"`
+	}{plain: (*plain)(s)}
+	switch {
+	case s.Values != nil:
+		out.AdditionalProperties = s.Values
+	case s.Closed:
+		out.AdditionalProperties = false
+	}
+	return json.Marshal(out)
+}
+
+// Generate derives a schema from the Go type of v, using the same yaml
+// struct tags the YAML decoder does. Struct fields without omitempty are
+// required, and structs reject unknown properties.
+func Generate(v any) *Schema {
+	return generate(reflect.TypeOf(v))
+}
+
+func generate(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema), Closed: true}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			s.Properties[name] = generate(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: generate(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", Values: generate(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{}
+	}
+}
+
+// At returns the schema at a path of property names, with "[]" stepping
+// into array items, or nil if there is none.
+func (s *Schema) At(path ...string) *Schema {
+	for _, part := range path {
+		if s == nil {
+			return nil
+		}
+		if part == "[]" {
+			s = s.Items
+		} else {
+			s = s.Properties[part]
+		}
+	}
+	return s
+}
+
+// Header returns the comment line that points YAML editors using
+// yaml-language-server at the schema at ref, a path relative to the file or
+// a URL.
+func Header(ref string) string {
+	return "# yaml-language-server: $schema=" + ref + "\n"
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type testDoc struct {
+	Name    string            `yaml:"name"`
+	Count   int               `yaml:"count,omitempty"`
+	Enabled *bool             `yaml:"enabled,omitempty"`
+	Tags    []string          `yaml:"tags,omitempty"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+	Inner   struct {
+		Mode string `yaml:"mode"`
+	} `yaml:"inner,omitempty"`
+}
+
+func TestGenerate(t *testing.T) {
+	s := Generate(testDoc{})
+	s.At("inner", "mode").Enum = []string{"fast", "slow"}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"object","properties":{` +
+		`"count":{"type":"integer"},` +
+		`"enabled":{"type":"boolean"},` +
+		`"inner":{"type":"object","properties":{"mode":{"type":"string","enum":["fast","slow"]}},"required":["mode"],"additionalProperties":false},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"string"}},` +
+		`"name":{"type":"string"},` +
+		`"tags":{"type":"array","items":{"type":"string"}}},` +
+		`"required":["name"],"additionalProperties":false}`
+	if string(data) != want {
+		t.Errorf("schema:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := Generate(testDoc{})
+	s.At("inner", "mode").Enum = []string{"fast", "slow"}
+	s.At("count").Minimum = new(int)
+	s.At("name").Const = "doc"
+
+	data := `count: -1
+enabled: yes please
+tags: [a, {b: c}]
+labels:
+  x: y
+inner:
+  mode: medium
+extra: 1
+count: 2
+`
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	problems := Check(&n, s)
+	for _, p := range problems {
+		got = append(got, p.Error())
+	}
+	want := []string{
+		"1:8: count: invalid value: must be at least 0",
+		`2:10: enabled: wrong type: want true or false, got "yes please"`,
+		"3:11: tags[1]: wrong type: want a string, got a mapping",
+		"7:9: inner.mode: invalid value: must be one of [fast slow]",
+		"8:1: extra: unknown field",
+		"9:1: count: duplicate field, already set on line 1",
+		"1:1: name: required field is missing",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !errors.Is(problems[4], ErrUnknownField) || !errors.Is(problems[6], ErrMissingField) {
+		t.Errorf("problems don't wrap their sentinels: %v, %v", problems[4], problems[6])
+	}
+}
+
+func TestCheckMergedKeys(t *testing.T) {
+	data := `base: &base
+  mode: fast
+name: doc
+inner:
+  <<: *base
+`
+	s := Generate(testDoc{})
+	s.Properties["base"] = &Schema{Type: "object"}
+
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &n); err != nil {
+		t.Fatal(err)
+	}
+	if problems := Check(&n, s); len(problems) != 0 {
+		t.Errorf("problems = %v, want none", problems)
+	}
+}
@@ -0,0 +1,131 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one thing wrong with a state file.
+type Problem = schema.Problem
+
+// Rule is an extra check run by Check on a decoded state, for problems that
+// need more than the file to find. Problems are located by their Field.
+type Rule func(s *State) []*Problem
+
+// yamlLine extracts the line number yaml.v3 puts in syntax errors.
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// Check parses the content of a state file and reports every problem with
+// it — invalid YAML, anything the state schema rejects, and anything
+// Validate rejects, plus anything rules find — ordered by position in the
+// file. A file with an older apiVersion is checked as Load would upgrade it.
+// It returns nil for a valid file.
+func Check(data []byte, rules ...Rule) []*Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		p := &Problem{Err: fmt.Errorf("%w: %s", ErrInvalidYAML, strings.TrimPrefix(err.Error(), "yaml: "))}
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Column = 1
+			p.Err = fmt.Errorf("%w: %s", ErrInvalidYAML, m[2])
+		}
+		return []*Problem{p}
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) != 1 {
+		return []*Problem{{Err: fmt.Errorf("%w: empty document", ErrInvalidYAML)}}
+	}
+	doc := root.Content[0]
+	if _, err := migrate.Documents.Upgrade("State", &root); err != nil && !errors.Is(err, migrate.ErrNoMigration) {
+		return []*Problem{{Field: "apiVersion", Line: doc.Line, Column: doc.Column, Err: err}}
+	}
+
+	var problems []*Problem
+	var s State
+	if err := root.Decode(&s); err != nil {
+		// Values of the wrong type leave nothing reliable to validate, so
+		// the schema's problems are all there is to report.
+		problems = schema.Check(doc, stateSchema())
+		if len(problems) == 0 {
+			problems = append(problems, &Problem{Err: fmt.Errorf("%w: %w", ErrWrongType, err)})
+		}
+	} else {
+		// Validate checks the same required fields and values as the schema
+		// but says more about them, so only the schema's structural problems
+		// are kept.
+		for _, p := range schema.Check(doc, stateSchema()) {
+			if !errors.Is(p, schema.ErrMissingField) && !errors.Is(p, schema.ErrInvalidValue) {
+				problems = append(problems, p)
+			}
+		}
+		found := validate(&s)
+		for _, rule := range rules {
+			found = append(found, rule(&s)...)
+		}
+		nodes := make(map[string]*yaml.Node)
+		index(doc, "", nodes)
+		for _, p := range found {
+			locate(p, nodes, doc)
+			problems = append(problems, p)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return problems
+}
+
+// index records the value node for every field path in n.
+func index(n *yaml.Node, field string, nodes map[string]*yaml.Node) {
+	if _, ok := nodes[field]; ok {
+		return
+	}
+	nodes[field] = n
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			if field != "" {
+				key = field + "." + key
+			}
+			index(n.Content[i+1], key, nodes)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			index(item, fmt.Sprintf("%s[%d]", field, i), nodes)
+		}
+	}
+}
+
+// locate sets a validation problem's position from the closest node that
+// exists for its field: the field itself, or the nearest enclosing one.
+func locate(p *Problem, nodes map[string]*yaml.Node, doc *yaml.Node) {
+	field := p.Field
+	for {
+		if n, ok := nodes[field]; ok {
+			p.Line, p.Column = n.Line, n.Column
+			return
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			p.Line, p.Column = doc.Line, doc.Column
+			return
+		}
+		field = field[:i]
+	}
+}
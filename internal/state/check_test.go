@@ -0,0 +1,130 @@
+package state
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/milldr/flow/internal/schema"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string // Problem.Error() of each problem, in order
+		errs []error
+	}{
+		{
+			name: "valid",
+			data: `apiVersion: flow/v1
+kind: State
+metadata:
+  created: "2026-02-18T12:00:00Z"
+spec:
+  repos:
+    - url: github.com/org/repo
+      branch: main
+`,
+		},
+		{
+			name: "syntax error",
+			data: "apiVersion: flow/v1\nspec:\n\trepos: []\n",
+			want: []string{"3:1: invalid YAML: found character that cannot start any token"},
+			errs: []error{ErrInvalidYAML},
+		},
+		{
+			name: "unknown field and wrong type",
+			data: `apiVersion: flow/v1
+kind: State
+metadata:
+  archived: maybe
+spec:
+  repos:
+    - url: github.com/org/repo
+      brnach: main
+      clone:
+        depth: [1]
+`,
+			want: []string{
+				`4:13: metadata.archived: wrong type: want true or false, got "maybe"`,
+				"8:7: spec.repos[0].brnach: unknown field",
+				"10:16: spec.repos[0].clone.depth: wrong type: want a number, got a list",
+			},
+			errs: []error{ErrWrongType, ErrUnknownField, ErrWrongType},
+		},
+		{
+			name: "duplicate field",
+			data: "apiVersion: flow/v1\nkind: State\nkind: State\n",
+			want: []string{
+				"1:1: spec: required field is missing",
+				"3:1: kind: duplicate field, already set on line 2",
+			},
+			errs: []error{schema.ErrMissingField, ErrDuplicateField},
+		},
+		{
+			name: "every validation problem",
+			data: `apiVersion: flow/v2
+kind: State
+spec:
+  repos:
+    - url: github.com/org/repo
+      branch: feat..x
+    - url: github.com/other/repo
+      branch: main
+    - url: github.com/org/api
+      brnach: main
+`,
+			want: []string{
+				"1:13: apiVersion: must be flow/v1",
+				`6:15: spec.repos[0].branch: invalid branch name "feat..x"`,
+				`7:7: spec.repos[1]: duplicate path "repo", also used by spec.repos[0]`,
+				"9:7: spec.repos[2]: branch or ref is required",
+				"10:7: spec.repos[2].brnach: unknown field",
+			},
+			errs: []error{ErrInvalidAPIVersion, ErrInvalidBranchName, ErrDuplicatePath, ErrMissingRepoBranch, ErrUnknownField},
+		},
+		{
+			name: "missing section",
+			data: "apiVersion: flow/v1\nkind: State\n",
+			want: []string{"1:1: spec.repos: must not be empty"},
+			errs: []error{ErrMissingRepos},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Check([]byte(tt.data))
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			for i, err := range tt.errs {
+				if !errors.Is(problems[i], err) {
+					t.Errorf("problem %d = %v, want %v", i, problems[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidBranchName(t *testing.T) {
+	valid := []string{"main", "feat/x", "feature/ipv6", "v1.2.3", "a@b", "x.lock.y", "héllo"}
+	invalid := []string{
+		"", "-x", "HEAD", "@", "a..b", "a b", "a~", "a^", "a:", "a?", "a*", "a[", `a\b`,
+		"a/", "/a", "a//b", ".a", "a/.b", "a.lock", "a/b.lock/c", "a.", "a@{1}", "a\tb",
+	}
+	for _, name := range valid {
+		if !validBranchName(name) {
+			t.Errorf("validBranchName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range invalid {
+		if validBranchName(name) {
+			t.Errorf("validBranchName(%q) = true, want false", name)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultIndent is the indentation yaml.Marshal uses, kept for files flow
+// writes from scratch.
+const defaultIndent = 4
+
+// document is the parsed state file a State was loaded from. Save patches
+// it rather than re-marshaling the State, so comments, anchors and key
+// order the user or an agent wrote survive programmatic edits.
+type document struct {
+	root *yaml.Node // the file as parsed
+	// base is the State as loaded, re-encoded. Diffing it against the State
+	// being saved finds exactly the fields flow changed.
+	base   *yaml.Node
+	indent int
+}
+
+// newDocument keeps root, the parsed content of data, for patching. It
+// returns nil when root isn't a single YAML mapping, in which case Save
+// falls back to plain marshaling.
+func newDocument(root *yaml.Node, data []byte, s *State) *document {
+	if root.Kind != yaml.DocumentNode || len(root.Content) != 1 || root.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	var base yaml.Node
+	if err := base.Encode(s); err != nil {
+		return nil
+	}
+	return &document{root: root, base: &base, indent: detectIndent(data)}
+}
+
+// marshal encodes s, patching the loaded document when there is one.
+func (s *State) marshal() ([]byte, error) {
+	if s.doc == nil {
+		return yaml.Marshal(s)
+	}
+
+	var next yaml.Node
+	if err := next.Encode(s); err != nil {
+		return nil, err
+	}
+	s.doc.root.Content[0] = patchNode(s.doc.root.Content[0], s.doc.base, &next)
+	s.doc.base = &next
+	untagMergeKeys(s.doc.root)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(s.doc.indent)
+	if err := enc.Encode(s.doc.root); err != nil {
+		return nil, fmt.Errorf("encoding patched document: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// patchNode applies the difference between base and next to dst, the node
+// in the user's document that base was decoded from, and returns the node
+// to put in dst's place. Unchanged subtrees are left exactly as written.
+func patchNode(dst, base, next *yaml.Node) *yaml.Node {
+	if equalNodes(base, next) {
+		return dst
+	}
+	// Aliases, merge keys and changed kinds can't be patched piecewise.
+	if dst.Kind != base.Kind || base.Kind != next.Kind {
+		return replaceNode(dst, next)
+	}
+	switch dst.Kind {
+	case yaml.MappingNode:
+		patchMapping(dst, base, next)
+		return dst
+	case yaml.SequenceNode:
+		patchSequence(dst, base, next)
+		return dst
+	default:
+		return replaceNode(dst, next)
+	}
+}
+
+// patchMapping updates dst's changed values in place, appends added keys
+// and removes dropped ones. Keys flow doesn't know about are left alone, and
+// keys dst gets unchanged from a merge key (<<) aren't written out.
+func patchMapping(dst, base, next *yaml.Node) {
+	for i := 0; i+1 < len(next.Content); i += 2 {
+		key, value := next.Content[i], next.Content[i+1]
+		di := mappingIndex(dst, key.Value)
+		if di < 0 {
+			if merged := mergedValue(dst, key.Value); merged == nil || !equalNodes(merged, value) {
+				dst.Content = append(dst.Content, key, value)
+			}
+			continue
+		}
+		if bi := mappingIndex(base, key.Value); bi >= 0 {
+			dst.Content[di+1] = patchNode(dst.Content[di+1], base.Content[bi+1], value)
+		} else {
+			// Written with its zero value, which base omits.
+			dst.Content[di+1] = replaceNode(dst.Content[di+1], value)
+		}
+	}
+	for i := 0; i+1 < len(base.Content); i += 2 {
+		key := base.Content[i].Value
+		if mappingIndex(next, key) >= 0 {
+			continue
+		}
+		if di := mappingIndex(dst, key); di >= 0 {
+			dst.Content = append(dst.Content[:di], dst.Content[di+2:]...)
+		}
+	}
+}
+
+// patchSequence patches items in place when the length is unchanged.
+// Otherwise unchanged items keep their written form and the rest are
+// replaced.
+func patchSequence(dst, base, next *yaml.Node) {
+	if len(dst.Content) != len(base.Content) {
+		dst.Content = next.Content
+		return
+	}
+	if len(base.Content) == len(next.Content) {
+		for i := range next.Content {
+			dst.Content[i] = patchNode(dst.Content[i], base.Content[i], next.Content[i])
+		}
+		return
+	}
+
+	used := make([]bool, len(base.Content))
+	content := make([]*yaml.Node, 0, len(next.Content))
+	for _, item := range next.Content {
+		kept := item
+		for j, b := range base.Content {
+			if !used[j] && equalNodes(b, item) {
+				used[j] = true
+				kept = dst.Content[j]
+				break
+			}
+		}
+		content = append(content, kept)
+	}
+	dst.Content = content
+}
+
+// replaceNode returns next in place of dst, carrying over dst's comments.
+// An anchored node is overwritten in place so aliases to it stay valid.
+func replaceNode(dst, next *yaml.Node) *yaml.Node {
+	if next.HeadComment == "" {
+		next.HeadComment = dst.HeadComment
+	}
+	if next.LineComment == "" {
+		next.LineComment = dst.LineComment
+	}
+	if next.FootComment == "" {
+		next.FootComment = dst.FootComment
+	}
+	if dst.Anchor != "" {
+		anchor := dst.Anchor
+		*dst = *next
+		dst.Anchor = anchor
+		return dst
+	}
+	return next
+}
+
+// mergedValue returns the value a mapping gets for key through its merge
+// keys, or nil. Like YAML, the mapping merged first wins.
+func mergedValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != "<<" {
+			continue
+		}
+		sources := []*yaml.Node{m.Content[i+1]}
+		if sources[0].Kind == yaml.SequenceNode {
+			sources = sources[0].Content
+		}
+		for _, src := range sources {
+			src = resolveAlias(src)
+			if src.Kind != yaml.MappingNode {
+				continue
+			}
+			if j := mappingIndex(src, key); j >= 0 {
+				return resolveAlias(src.Content[j+1])
+			}
+			if v := mergedValue(src, key); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// resolveAlias returns the node an alias points at, or n itself.
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// untagMergeKeys clears the !!merge tag the parser gives merge keys, which
+// the encoder would otherwise write out as "!!merge <<".
+func untagMergeKeys(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if k := n.Content[i]; k.Value == "<<" && k.Tag == "!!merge" {
+				k.Tag = ""
+			}
+		}
+	}
+	for _, c := range n.Content {
+		untagMergeKeys(c)
+	}
+}
+
+// mappingIndex returns the index of key's key node in a mapping, or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// equalNodes reports whether two encoded nodes hold the same data.
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Tag != b.Tag || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectIndent returns the indentation of the first indented line in data.
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == line || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n >= 2 {
+			return n
+		}
+	}
+	return defaultIndent
+}
@@ -0,0 +1,181 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const commentedState = `# Workspace for the IPv6 rollout.
+kind: State
+apiVersion: flow/v1
+metadata:
+  name: vpc-ipv6 # shown in flow list
+  created: "2026-02-18T12:00:00Z"
+spec:
+  repos:
+    # The service itself.
+    - url: github.com/acme/vpc-service
+      branch: &feature feature/ipv6
+    - url: github.com/acme/subnet-manager
+      branch: *feature
+      path: subnets # short name
+`
+
+func TestSavePreservesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := os.WriteFile(path, []byte(commentedState), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Metadata.Archived = true
+	st.Metadata.Name = "vpc-v6"
+	st.Spec.Repos[1].Path = ""
+	st.Spec.Repos[1].Base = "develop"
+	if err := Save(path, st); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	want := `# Workspace for the IPv6 rollout.
+kind: State
+apiVersion: flow/v1
+metadata:
+  name: vpc-v6 # shown in flow list
+  created: "2026-02-18T12:00:00Z"
+  archived: true
+spec:
+  repos:
+    # The service itself.
+    - url: github.com/acme/vpc-service
+      branch: &feature feature/ipv6
+    - url: github.com/acme/subnet-manager
+      branch: *feature
+      base: develop
+`
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("saved state:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Saving again with no changes leaves the file as it is.
+	if err := Save(path, st); err != nil {
+		t.Fatalf("second Save: %v", err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != want {
+		t.Errorf("unchanged save rewrote the file:\n%s", again)
+	}
+
+	// Removing a repo keeps the comments on the ones that remain.
+	st.Metadata.Archived = false
+	st.Spec.Repos = st.Spec.Repos[:1]
+	if err := Save(path, st); err != nil {
+		t.Fatalf("third Save: %v", err)
+	}
+	want = `# Workspace for the IPv6 rollout.
+kind: State
+apiVersion: flow/v1
+metadata:
+  name: vpc-v6 # shown in flow list
+  created: "2026-02-18T12:00:00Z"
+spec:
+  repos:
+    # The service itself.
+    - url: github.com/acme/vpc-service
+      branch: &feature feature/ipv6
+`
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("saved state:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSaveChangesAnchoredValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := os.WriteFile(path, []byte(commentedState), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changing the aliased copy must not touch the anchor it points at.
+	st.Spec.Repos[1].Branch = "feature/other"
+	if err := Save(path, st); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.Spec.Repos[0].Branch; got != "feature/ipv6" {
+		t.Errorf("repos[0].branch = %q, want feature/ipv6", got)
+	}
+	if got := loaded.Spec.Repos[1].Branch; got != "feature/other" {
+		t.Errorf("repos[1].branch = %q, want feature/other", got)
+	}
+}
+
+func TestSaveKeepsMergeKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	const merged = `kind: State
+apiVersion: flow/v1
+metadata:
+  name: vpc-ipv6
+spec:
+  repos:
+    - &svc
+      url: github.com/acme/vpc-service
+      branch: feature/ipv6
+    - <<: *svc
+      url: github.com/acme/subnet-manager
+    - <<: *svc
+      url: github.com/acme/route-manager
+`
+	if err := os.WriteFile(path, []byte(merged), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Spec.Repos[1].Path = "subnets"
+	st.Spec.Repos[2].Branch = "feature/routes"
+	if err := Save(path, st); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys that still come from the merge aren't written out, a changed one
+	// overrides it, and the merge key keeps its plain form.
+	want := `kind: State
+apiVersion: flow/v1
+metadata:
+  name: vpc-ipv6
+spec:
+  repos:
+    - &svc
+      url: github.com/acme/vpc-service
+      branch: feature/ipv6
+    - <<: *svc
+      url: github.com/acme/subnet-manager
+      path: subnets
+    - <<: *svc
+      url: github.com/acme/route-manager
+      branch: feature/routes
+`
+	if string(data) != want {
+		t.Errorf("saved:\n%s\nwant:\n%s", data, want)
+	}
+}
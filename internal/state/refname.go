@@ -0,0 +1,40 @@
+package state
+
+import "strings"
+
+// validBranchName reports whether name follows git's rules for branch names
+// (see git check-ref-format): no empty, dot-leading or .lock-suffixed
+// components, no "..", "@{", control characters, spaces or any of ~^:?*[\,
+// not "@" or "HEAD", and not starting with "-" or ending with "." or "/".
+func validBranchName(name string) bool {
+	if name == "" || name == "@" || name == "HEAD" || strings.HasPrefix(name, "-") {
+		return false
+	}
+	if strings.HasSuffix(name, ".") || strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return false
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// isCommitHash reports whether s is a full SHA-1 or SHA-256 commit hash.
+func isCommitHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
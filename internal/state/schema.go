@@ -0,0 +1,27 @@
+package state
+
+import (
+	"sync"
+
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/schema"
+)
+
+// stateSchema is built once; Check runs against it on every call.
+var stateSchema = sync.OnceValue(Schema)
+
+// Schema returns the JSON Schema for state files, generated from State. It
+// requires only what Validate requires, so an editor using it and flow
+// validate agree on what a valid file is.
+func Schema() *schema.Schema {
+	s := schema.Generate(State{})
+	s.Schema = schema.Draft
+	s.Title = "flow workspace state"
+	s.Required = []string{"apiVersion", "kind", "spec"}
+	s.At("apiVersion").Const = migrate.CurrentVersion
+	s.At("kind").Const = "State"
+	s.At("metadata").Required = nil
+	s.At("spec", "repos").MinItems = 1
+	s.At("spec", "repos", "[]", "clone", "depth").Minimum = new(int)
+	return s
+}
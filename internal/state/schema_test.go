@@ -0,0 +1,46 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateWritesSchemaHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	header := "# yaml-language-server: $schema=../../schemas/state.json\n"
+
+	s := NewState("ws", "", []Repo{{URL: "github.com/org/repo", Branch: "feat"}})
+	if err := Create(path, s, "../../schemas/state.json"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	s.Spec.Repos[0].Branch = "other"
+	if err := Save(path, s); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), header) {
+		t.Errorf("file doesn't start with the schema header:\n%s", data)
+	}
+	if problems := Check(data); problems != nil {
+		t.Errorf("Check = %v, want no problems", problems)
+	}
+}
+
+func TestSchemaMatchesValidate(t *testing.T) {
+	s := Schema()
+	if got := strings.Join(s.Required, ","); got != "apiVersion,kind,spec" {
+		t.Errorf("required = %s, want apiVersion,kind,spec", got)
+	}
+	if got := strings.Join(s.At("spec", "repos", "[]").Required, ","); got != "url" {
+		t.Errorf("repo required = %s, want url", got)
+	}
+	if s.At("metadata").Required != nil {
+		t.Errorf("metadata required = %v, want none", s.At("metadata").Required)
+	}
+}
@@ -4,69 +4,222 @@ package state
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path"
+	"path/filepath"
 	"strings"
 
+	"github.com/milldr/flow/internal/fsutil"
+	"github.com/milldr/flow/internal/giturl"
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/schema"
 	"gopkg.in/yaml.v3"
 )
 
 // Validation errors for state files.
 var (
-	ErrInvalidAPIVersion = errors.New("apiVersion must be flow/v1")
-	ErrInvalidKind       = errors.New("kind must be State")
-	ErrMissingRepos      = errors.New("spec.repos must not be empty")
+	ErrInvalidAPIVersion = errors.New("must be flow/v1")
+	ErrInvalidKind       = errors.New("must be State")
+	ErrMissingRepos      = errors.New("must not be empty")
 	ErrMissingRepoURL    = errors.New("url is required")
-	ErrMissingRepoBranch = errors.New("branch is required")
+	ErrMissingRepoBranch = errors.New("branch or ref is required")
+	ErrBranchAndRef      = errors.New("branch and ref are mutually exclusive")
+	ErrInvalidRef        = errors.New("invalid ref")
+	ErrInvalidCommit     = errors.New("commit must be a full commit hash")
+	ErrBaseWithRef       = errors.New("base only applies to a branch")
+	ErrInvalidDepth      = errors.New("clone.depth must not be negative")
+	ErrInvalidBranchName = errors.New("invalid branch name")
+	ErrDuplicatePath     = errors.New("duplicate path")
+	ErrDuplicateRepo     = errors.New("duplicate repo and branch")
+	ErrPathEscapes       = errors.New("path must be inside the workspace")
+	ErrReservedPath      = errors.New("path is reserved for flow's own files")
+	ErrBranchIsBase      = errors.New("branch must differ from its base")
+	ErrInvalidYAML       = errors.New("invalid YAML")
+	ErrUnknownField      = schema.ErrUnknownField
+	ErrDuplicateField    = schema.ErrDuplicateField
+	ErrWrongType         = schema.ErrWrongType
 )
 
 // Load reads and parses a state file from disk.
 func Load(path string) (*State, error) {
-	data, err := os.ReadFile(path)
+	data, version, err := fsutil.ReadVersioned(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var s State
-	if err := yaml.Unmarshal(data, &s); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("parsing state file: %w", err)
 	}
+	from, err := migrate.Documents.Upgrade("State", &root)
+	if err != nil && !errors.Is(err, migrate.ErrNoMigration) {
+		return nil, err
+	}
+
+	var s State
+	if root.Kind != 0 {
+		if err := root.Decode(&s); err != nil {
+			return nil, fmt.Errorf("parsing state file: %w", err)
+		}
+	}
+	s.version = version
+	s.migratedFrom = from
+	s.doc = newDocument(&root, data, &s)
 
 	return &s, nil
 }
 
-// Save writes a state to disk as YAML.
+// MigratedFrom returns the apiVersion the file had when Load upgraded it to
+// the current one, or "" if it was already current. Save writes the
+// upgraded form.
+func (s *State) MigratedFrom() string {
+	return s.migratedFrom
+}
+
+// Save atomically writes a state to disk as YAML. A state read by Load is
+// written by patching the loaded file, so only changed fields are touched
+// and comments, anchors and key order are kept. If s was loaded from path
+// and the file has changed since, it is left alone and
+// fsutil.ErrConcurrentModification is returned.
 func Save(path string, s *State) error {
+	data, err := s.marshal()
+	if err != nil {
+		return fmt.Errorf("marshaling state: %w", err)
+	}
+
+	s.version, err = fsutil.WriteVersioned(path, data, 0o644, s.version)
+	return err
+}
+
+// Create writes s as a new state file at path, replacing any file there.
+// A non-empty schemaRef adds a comment pointing YAML editors at the state
+// schema, at schemaRef relative to the file.
+func Create(path string, s *State, schemaRef string) error {
 	data, err := yaml.Marshal(s)
 	if err != nil {
 		return fmt.Errorf("marshaling state: %w", err)
 	}
+	if schemaRef != "" {
+		data = append([]byte(schema.Header(schemaRef)), data...)
+	}
+
+	s.version, err = fsutil.WriteVersioned(path, data, 0o644, fsutil.Version{})
+	if err != nil {
+		return err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err == nil {
+		s.doc = newDocument(&root, data, s)
+	}
+	return nil
+}
+
+// reservedPaths are files and directories flow writes at the top of a
+// workspace, which a repo path must not use.
+var reservedPaths = []string{"state.yaml", "status.yaml", "CLAUDE.md", ".claude", "archive"}
 
-	return os.WriteFile(path, data, 0o644)
+// Validate checks that a State has all required fields and that its repos
+// can be rendered side by side, plus anything rules find. Every problem
+// found is returned, joined; Check also reports their positions in the file.
+func Validate(s *State, rules ...Rule) error {
+	problems := validate(s)
+	for _, rule := range rules {
+		problems = append(problems, rule(s)...)
+	}
+	errs := make([]error, len(problems))
+	for i, p := range problems {
+		errs[i] = p
+	}
+	return errors.Join(errs...)
 }
 
-// Validate checks that a State has all required fields.
-func Validate(s *State) error {
-	if s.APIVersion != "flow/v1" {
-		return ErrInvalidAPIVersion
+// validate returns every problem with a decoded State, without positions.
+// Each problem's Field also says where Check should point.
+func validate(s *State) []*Problem {
+	var problems []*Problem
+	add := func(field string, err error) {
+		problems = append(problems, &Problem{Field: field, Err: err})
+	}
+
+	if s.APIVersion != migrate.CurrentVersion {
+		add("apiVersion", ErrInvalidAPIVersion)
 	}
 	if s.Kind != "State" {
-		return ErrInvalidKind
+		add("kind", ErrInvalidKind)
 	}
 	if len(s.Spec.Repos) == 0 {
-		return ErrMissingRepos
+		add("spec.repos", ErrMissingRepos)
 	}
 
+	paths := make(map[string]int)
+	repos := make(map[string]int)
 	for i, r := range s.Spec.Repos {
+		field := fmt.Sprintf("spec.repos[%d]", i)
 		if r.URL == "" {
-			return fmt.Errorf("spec.repos[%d]: %w", i, ErrMissingRepoURL)
+			add(field, ErrMissingRepoURL)
+		}
+		switch {
+		case r.Branch == "" && r.Ref == "":
+			add(field, ErrMissingRepoBranch)
+		case r.Branch != "" && r.Ref != "":
+			add(field+".ref", ErrBranchAndRef)
+		case r.Ref != "":
+			if !validBranchName(r.Ref) {
+				add(field+".ref", fmt.Errorf("%w %q", ErrInvalidRef, r.Ref))
+			}
+			if r.Base != "" {
+				add(field+".base", ErrBaseWithRef)
+			}
+		case !validBranchName(r.Branch):
+			add(field+".branch", fmt.Errorf("%w %q", ErrInvalidBranchName, r.Branch))
+		}
+		if r.Commit != "" && !isCommitHash(r.Commit) {
+			add(field+".commit", fmt.Errorf("%w, got %q", ErrInvalidCommit, r.Commit))
+		}
+		if r.Base != "" && !validBranchName(r.Base) {
+			add(field+".base", fmt.Errorf("%w %q", ErrInvalidBranchName, r.Base))
 		}
-		if r.Branch == "" {
-			return fmt.Errorf("spec.repos[%d]: %w", i, ErrMissingRepoBranch)
+		if r.Branch != "" && r.Branch == r.Base {
+			add(field+".branch", fmt.Errorf("%w: render would reset %s to origin/%s", ErrBranchIsBase, r.Branch, r.Base))
 		}
+		if r.Clone != nil && r.Clone.Depth < 0 {
+			add(field, ErrInvalidDepth)
+		}
+
+		if r.URL != "" && r.Branch != "" {
+			key := giturl.Normalize(r.URL) + "@" + r.Branch
+			if j, ok := repos[key]; ok {
+				add(field, fmt.Errorf("%w: %s on %s is also spec.repos[%d]", ErrDuplicateRepo, r.URL, r.Branch, j))
+			} else {
+				repos[key] = i
+			}
+		}
+
+		if r.URL == "" && r.Path == "" {
+			continue
+		}
+		at := field
+		if r.Path != "" {
+			at += ".path"
+		}
+		p := filepath.Clean(RepoPath(r))
+		if filepath.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+			add(at, fmt.Errorf("%w: %q", ErrPathEscapes, RepoPath(r)))
+			continue
+		}
+		top, _, _ := strings.Cut(filepath.ToSlash(p), "/")
+		for _, reserved := range reservedPaths {
+			if strings.EqualFold(top, reserved) {
+				add(at, fmt.Errorf("%w: %q", ErrReservedPath, RepoPath(r)))
+				break
+			}
+		}
+		if j, ok := paths[p]; ok {
+			add(at, fmt.Errorf("%w %q, also used by spec.repos[%d]", ErrDuplicatePath, p, j))
+			continue
+		}
+		paths[p] = i
 	}
 
-	return nil
+	return problems
 }
 
 // RepoPath returns the repo's configured path, or derives one from the URL
@@ -75,7 +228,5 @@ func RepoPath(r Repo) string {
 	if r.Path != "" {
 		return r.Path
 	}
-	// Take the last segment of the URL and strip .git suffix
-	base := path.Base(r.URL)
-	return strings.TrimSuffix(base, ".git")
+	return giturl.Name(r.URL)
 }
@@ -1,10 +1,13 @@
 package state
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/milldr/flow/internal/fsutil"
 )
 
 func TestRoundTrip(t *testing.T) {
@@ -54,6 +57,46 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSaveConcurrentModification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := Save(path, NewState("ws", "", []Repo{{URL: "github.com/org/repo", Branch: "main"}})); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process edits the file after it was loaded.
+	edited := NewState("edited", "", []Repo{{URL: "github.com/org/repo", Branch: "feat"}})
+	if err := Save(path, edited); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded.Metadata.Archived = true
+	if err := Save(path, loaded); !errors.Is(err, fsutil.ErrConcurrentModification) {
+		t.Fatalf("Save error = %v, want ErrConcurrentModification", err)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Metadata.Name != "edited" || reloaded.Metadata.Archived {
+		t.Errorf("state = %+v, want the concurrent edit kept", reloaded.Metadata)
+	}
+
+	// Saving what was just loaded, twice, is fine.
+	reloaded.Metadata.Archived = true
+	if err := Save(path, reloaded); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	reloaded.Metadata.Archived = false
+	if err := Save(path, reloaded); err != nil {
+		t.Fatalf("second Save: %v", err)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -129,6 +172,16 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative clone depth",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Clone: &CloneOptions{Depth: -1}}}},
+			},
+			wantErr: true,
+		},
 		{
 			name: "repo missing path is valid",
 			state: &State{
@@ -152,6 +205,176 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "path escapes the workspace",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Path: "../p"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "absolute path",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Path: "/tmp/p"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "path is the workspace itself",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Path: "./"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "path is a generated file",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Path: "CLAUDE.md"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "path inside a generated directory",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Path: ".claude/repo"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "derived path is reserved",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "github.com/org/archive", Branch: "b"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "nested path is valid",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Path: "libs/p"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "duplicate paths",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Path: "./p"}, {URL: "v", Branch: "b", Path: "p"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "branch equals base",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "main", Base: "main"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate url and branch",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "git@github.com:org/repo.git", Branch: "b", Path: "a"}, {URL: "github.com/org/repo", Branch: "b", Path: "c"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "same repo on two branches is valid",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "github.com/org/repo", Branch: "a", Path: "a"}, {URL: "github.com/org/repo", Branch: "b", Path: "c"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "pinned to a ref is valid",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Ref: "v1.2.0", Commit: "0123456789abcdef0123456789abcdef01234567"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "branch and ref",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Branch: "b", Ref: "v1.2.0"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid ref",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Ref: "v1..2"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pinned repo with a base",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Ref: "v1.2.0", Base: "main"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "abbreviated commit",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Ref: "v1.2.0", Commit: "0123456"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "same repo pinned twice is valid",
+			state: &State{
+				APIVersion: "flow/v1",
+				Kind:       "State",
+				Metadata:   Metadata{Name: "ws"},
+				Spec:       Spec{Repos: []Repo{{URL: "u", Ref: "v1", Path: "a"}, {URL: "u", Ref: "v1", Path: "b"}}},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -164,6 +387,21 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateReportsAllProblems(t *testing.T) {
+	s := NewState("ws", "", []Repo{
+		{URL: "github.com/org/repo", Branch: "main", Base: "main"},
+		{URL: "github.com/org/other", Branch: "b", Path: "../other"},
+		{URL: "github.com/org/repo", Branch: "main", Path: "state.yaml"},
+	})
+
+	err := Validate(s)
+	for _, want := range []error{ErrBranchIsBase, ErrPathEscapes, ErrDuplicateRepo, ErrReservedPath} {
+		if !errors.Is(err, want) {
+			t.Errorf("Validate() = %v, want it to include %v", err, want)
+		}
+	}
+}
+
 func TestRepoPath(t *testing.T) {
 	tests := []struct {
 		name string
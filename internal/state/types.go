@@ -1,6 +1,11 @@
 package state
 
-import "time"
+import (
+	"time"
+
+	"github.com/milldr/flow/internal/fsutil"
+	"github.com/milldr/flow/internal/migrate"
+)
 
 // State represents a workspace state file.
 type State struct {
@@ -8,6 +13,10 @@ type State struct {
 	Kind       string   `yaml:"kind"`
 	Metadata   Metadata `yaml:"metadata"`
 	Spec       Spec     `yaml:"spec"`
+
+	version      fsutil.Version // file content at Load, for Save
+	doc          *document      // parsed file at Load, patched by Save
+	migratedFrom string         // apiVersion before Load upgraded it
 }
 
 // Metadata contains workspace identification.
@@ -16,6 +25,20 @@ type Metadata struct {
 	Description string `yaml:"description,omitempty"`
 	Created     string `yaml:"created"`
 	Archived    bool   `yaml:"archived,omitempty"`
+	// Rescued lists work saved from worktrees removed by archive, waiting to
+	// be reapplied when the workspace is restored.
+	Rescued []Rescued `yaml:"rescued,omitempty"`
+}
+
+// Rescued records uncommitted changes and local-only commits saved from a
+// worktree before it was removed. File paths are relative to the workspace
+// directory.
+type Rescued struct {
+	Path    string `yaml:"path"`
+	Branch  string `yaml:"branch"`
+	Patch   string `yaml:"patch,omitempty"`
+	Bundle  string `yaml:"bundle,omitempty"`
+	Commits int    `yaml:"commits,omitempty"`
 }
 
 // Spec defines the workspace contents.
@@ -25,16 +48,63 @@ type Spec struct {
 
 // Repo defines a single repository in the workspace.
 type Repo struct {
-	URL    string `yaml:"url"`
-	Branch string `yaml:"branch"`
-	Base   string `yaml:"base,omitempty"`
-	Path   string `yaml:"path,omitempty"`
+	URL string `yaml:"url"`
+	// Branch is the branch checked out in the worktree. Ref pins the
+	// worktree to a tag or commit instead, checked out detached. Exactly one
+	// of the two is set.
+	Branch string `yaml:"branch,omitempty"`
+	Ref    string `yaml:"ref,omitempty"`
+	// Commit is the commit Ref resolved to when the workspace was last
+	// rendered, and CommitRef the Ref it was resolved from. flow records
+	// them so a pinned checkout can be reproduced even if the tag is later
+	// moved; once Ref is changed, the record no longer applies.
+	Commit    string `yaml:"commit,omitempty"`
+	CommitRef string `yaml:"commitRef,omitempty"`
+	Base      string `yaml:"base,omitempty"`
+	Path      string `yaml:"path,omitempty"`
+	// Clone overrides the global clone options for this repo's bare clone.
+	Clone *CloneOptions `yaml:"clone,omitempty"`
+}
+
+// Pinned reports whether the repo is pinned to a tag or commit rather than
+// on a branch.
+func (r Repo) Pinned() bool {
+	return r.Ref != ""
+}
+
+// RecordedCommit returns the commit recorded for the repo's ref, or "" if
+// none was recorded or it was recorded for another ref.
+func (r Repo) RecordedCommit() string {
+	if r.Commit == "" || r.CommitRef != r.Ref {
+		return ""
+	}
+	return r.Commit
+}
+
+// Target returns the branch the repo is on, or the ref it is pinned to.
+func (r Repo) Target() string {
+	if r.Pinned() {
+		return r.Ref
+	}
+	return r.Branch
+}
+
+// CloneOptions limit what the bare clone of a repo downloads. They only take
+// effect when the clone is created.
+type CloneOptions struct {
+	// Filter is a partial clone filter, e.g. blob:none.
+	Filter string `yaml:"filter,omitempty"`
+	// Depth truncates history to this many commits. Zero clones everything.
+	Depth int `yaml:"depth,omitempty"`
+	// SingleBranch clones only the default branch; other branches are
+	// fetched when a workspace needs them.
+	SingleBranch *bool `yaml:"singleBranch,omitempty"`
 }
 
 // NewState creates a State with defaults filled in.
 func NewState(name, description string, repos []Repo) *State {
 	return &State{
-		APIVersion: "flow/v1",
+		APIVersion: migrate.CurrentVersion,
 		Kind:       "State",
 		Metadata: Metadata{
 			Name:        name,
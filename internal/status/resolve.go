@@ -7,10 +7,17 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/milldr/flow/internal/giturl"
 )
 
 const checkTimeout = 10 * time.Second
 
+// StatusPinned is the status of a repo pinned to a tag or commit. With no
+// branch to check it has no place in the workflow, so it is left out of the
+// workspace status.
+const StatusPinned = "pinned"
+
 // CheckRunner executes a status check command and returns whether it matched.
 type CheckRunner interface {
 	RunCheck(ctx context.Context, command string, env []string) bool
@@ -32,23 +39,15 @@ func (r *ShellRunner) RunCheck(ctx context.Context, command string, env []string
 // Resolver resolves workspace statuses using a CheckRunner.
 type Resolver struct {
 	Runner CheckRunner
+	// Offline sets FLOW_OFFLINE=1 for checks so they can skip network calls.
+	Offline bool
 }
 
 // RepoSlug converts a repo URL to owner/repo format for gh CLI.
-// Handles SSH (git@github.com:org/repo.git) and HTTPS (github.com/org/repo) URLs.
+// Handles SSH (git@github.com:org/repo.git), HTTPS and scheme-less
+// (github.com/org/repo) URLs.
 func RepoSlug(url string) string {
-	s := url
-	// SSH: git@github.com:org/repo.git -> org/repo.git
-	if i := strings.Index(s, ":"); strings.Contains(s, "@") && i > 0 {
-		s = s[i+1:]
-	} else {
-		// HTTPS: github.com/org/repo -> org/repo
-		parts := strings.SplitN(s, "/", 2)
-		if len(parts) == 2 {
-			s = parts[1]
-		}
-	}
-	return strings.TrimSuffix(s, ".git")
+	return giturl.Slug(url)
 }
 
 // buildEnv creates the environment variables for a status check.
@@ -63,6 +62,15 @@ func buildEnv(repo RepoInfo, wsID, wsName string) []string {
 	}
 }
 
+// env returns the environment variables for a status check.
+func (r *Resolver) env(repo RepoInfo, wsID, wsName string) []string {
+	env := buildEnv(repo, wsID, wsName)
+	if r.Offline {
+		env = append(env, "FLOW_OFFLINE=1")
+	}
+	return env
+}
+
 // lastCommitTime returns the committer timestamp of the most recent commit in the repo.
 func lastCommitTime(ctx context.Context, repoPath string) time.Time {
 	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
@@ -79,9 +87,12 @@ func lastCommitTime(ctx context.Context, repoPath string) time.Time {
 
 // ResolveRepo determines the status of a single repo by evaluating checks
 // in order. Returns the name of the first matching status, the default,
-// or empty string if the repo is skipped.
+// or empty string if the repo is skipped. A pinned repo is StatusPinned.
 func (r *Resolver) ResolveRepo(ctx context.Context, spec *Spec, repo RepoInfo, wsID, wsName string) string {
-	env := buildEnv(repo, wsID, wsName)
+	if repo.Ref != "" {
+		return StatusPinned
+	}
+	env := r.env(repo, wsID, wsName)
 
 	// If a skip check is defined and passes, exclude this repo from aggregation.
 	if spec.Spec.Skip != "" && r.Runner.RunCheck(ctx, spec.Spec.Skip, env) {
@@ -138,11 +149,11 @@ func (r *Resolver) ResolveWorkspace(ctx context.Context, spec *Spec, repos []Rep
 		go func(idx int, rp RepoInfo) {
 			defer wg.Done()
 			repoStart := time.Now()
-			env := buildEnv(rp, wsID, wsName)
+			env := r.env(rp, wsID, wsName)
 
-			// Run skip check if configured.
-			skip := false
-			if spec.Spec.Skip != "" {
+			// Run skip check if configured. Pinned repos have nothing to check.
+			skip := rp.Ref != ""
+			if !skip && spec.Spec.Skip != "" {
 				skip = r.Runner.RunCheck(ctx, spec.Spec.Skip, env)
 			}
 
@@ -153,6 +164,7 @@ func (r *Resolver) ResolveWorkspace(ctx context.Context, spec *Spec, repos []Rep
 			result.Repos[idx] = RepoResult{
 				URL:        rp.URL,
 				Branch:     rp.Branch,
+				Ref:        rp.Ref,
 				Status:     st,
 				Duration:   time.Since(repoStart),
 				LastCommit: lc,
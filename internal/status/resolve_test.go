@@ -259,6 +259,31 @@ func TestResolveWorkspaceSkippedRepoExcluded(t *testing.T) {
 	}
 }
 
+func TestResolveWorkspacePinnedRepoExcluded(t *testing.T) {
+	// repo-b is pinned to a tag; its checks would say closed, but they
+	// aren't run, and it doesn't count toward the workspace status.
+	perRepoMock := &perRepoRunner{
+		results: map[string]map[string]bool{
+			"github.com/org/repo-a": {"check-progress": true},
+			"github.com/org/repo-b": {"check-closed": true},
+		},
+	}
+	resolver := &Resolver{Runner: perRepoMock}
+
+	repos := []RepoInfo{
+		{URL: "github.com/org/repo-a", Branch: "feat/x", Path: "./repo-a"},
+		{URL: "github.com/org/repo-b", Ref: "v1.2.0", Path: "./repo-b"},
+	}
+	result := resolver.ResolveWorkspace(context.Background(), testSpec(), repos, "ws-1", "my-ws")
+
+	if result.Status != "in-progress" {
+		t.Errorf("workspace status = %q, want in-progress (pinned repo excluded)", result.Status)
+	}
+	if got := result.Repos[1]; got.Status != StatusPinned || got.Ref != "v1.2.0" {
+		t.Errorf("pinned repo = %+v, want status %s and ref v1.2.0", got, StatusPinned)
+	}
+}
+
 func TestResolveWorkspaceDefaultStatusPassive(t *testing.T) {
 	// Two feature repos: one closed, one at default (open).
 	// Default status is passive — should not drag workspace to open.
@@ -359,3 +384,29 @@ func (m *perRepoRunner) RunCheck(_ context.Context, command string, env []string
 	}
 	return false
 }
+
+// envRunner matches checks only when FLOW_OFFLINE is set.
+type envRunner struct{}
+
+func (envRunner) RunCheck(_ context.Context, _ string, env []string) bool {
+	for _, e := range env {
+		if e == "FLOW_OFFLINE=1" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestResolveRepoOffline(t *testing.T) {
+	repo := RepoInfo{URL: "github.com/org/repo", Branch: "feat/x", Path: "./repo"}
+
+	online := &Resolver{Runner: envRunner{}}
+	if got := online.ResolveRepo(context.Background(), testSpec(), repo, "ws-1", "my-ws"); got != "open" {
+		t.Errorf("online status = %q, want open", got)
+	}
+
+	offline := &Resolver{Runner: envRunner{}, Offline: true}
+	if got := offline.ResolveRepo(context.Background(), testSpec(), repo, "ws-1", "my-ws"); got != "closed" {
+		t.Errorf("offline status = %q, want closed (FLOW_OFFLINE passed to checks)", got)
+	}
+}
@@ -0,0 +1,19 @@
+package status
+
+import (
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/schema"
+)
+
+// Schema returns the JSON Schema for status spec files, generated from Spec.
+func Schema() *schema.Schema {
+	s := schema.Generate(Spec{})
+	s.Schema = schema.Draft
+	s.Title = "flow status spec"
+	s.Required = append(s.Required, "spec")
+	s.At("apiVersion").Const = migrate.CurrentVersion
+	s.At("kind").Const = "Status"
+	s.At("spec").Required = []string{"statuses"}
+	s.At("spec", "statuses").MinItems = 1
+	return s
+}
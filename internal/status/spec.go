@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/milldr/flow/internal/fsutil"
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/schema"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,32 +26,70 @@ var (
 
 // Load reads and parses a status spec file from disk.
 func Load(path string) (*Spec, error) {
-	data, err := os.ReadFile(path)
+	data, version, err := fsutil.ReadVersioned(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var s Spec
-	if err := yaml.Unmarshal(data, &s); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("parsing status spec: %w", err)
 	}
+	from, err := migrate.Documents.Upgrade("Status", &root)
+	if err != nil && !errors.Is(err, migrate.ErrNoMigration) {
+		return nil, err
+	}
+
+	var s Spec
+	if root.Kind != 0 {
+		if err := root.Decode(&s); err != nil {
+			return nil, fmt.Errorf("parsing status spec: %w", err)
+		}
+	}
+	s.version = version
+	s.migratedFrom = from
 
 	return &s, nil
 }
 
-// Save writes a Spec to disk as YAML.
+// MigratedFrom returns the apiVersion the file had when Load upgraded it to
+// the current one, or "" if it was already current.
+func (s *Spec) MigratedFrom() string {
+	return s.migratedFrom
+}
+
+// Save atomically writes a Spec to disk as YAML. If s was loaded from path
+// and the file has changed since, it is left alone and
+// fsutil.ErrConcurrentModification is returned.
 func Save(path string, s *Spec) error {
 	data, err := yaml.Marshal(s)
 	if err != nil {
 		return fmt.Errorf("marshaling status spec: %w", err)
 	}
 
-	return os.WriteFile(path, data, 0o644)
+	s.version, err = fsutil.WriteVersioned(path, data, 0o644, s.version)
+	return err
+}
+
+// Create writes s as a new status spec at path, replacing any file there.
+// A non-empty schemaRef adds a comment pointing YAML editors at the status
+// schema, at schemaRef relative to the file.
+func Create(path string, s *Spec, schemaRef string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshaling status spec: %w", err)
+	}
+	if schemaRef != "" {
+		data = append([]byte(schema.Header(schemaRef)), data...)
+	}
+
+	s.version, err = fsutil.WriteVersioned(path, data, 0o644, fsutil.Version{})
+	return err
 }
 
 // Validate checks that a Spec has all required fields and is well-formed.
 func Validate(s *Spec) error {
-	if s.APIVersion != "flow/v1" {
+	if s.APIVersion != migrate.CurrentVersion {
 		return ErrInvalidAPIVersion
 	}
 	if s.Kind != "Status" {
@@ -109,11 +150,18 @@ const defaultBranchFragment = `_default=$(git -C "$FLOW_REPO_PATH" symbolic-ref
 // Used to distinguish "branch was pushed and PR merged" from "stale PR with reused name".
 const branchWasTrackedFragment = `git -C "$FLOW_REPO_PATH" config "branch.$FLOW_REPO_BRANCH.remote" > /dev/null 2>&1`
 
+// onlineFragment succeeds unless checks run offline (flow status --offline).
+// Network commands in the default spec are guarded by it.
+const onlineFragment = `[ -z "$FLOW_OFFLINE" ]`
+
 // DefaultSpec returns a starter status spec with a basic PR workflow.
 //
 // Status checks are evaluated top-to-bottom per repo; first match wins.
 // The workspace-level status is the least-advanced (highest index) across repos.
 //
+// Checks that need the network (gh, ls-remote) don't match when
+// FLOW_OFFLINE is set.
+//
 // Default statuses:
 //
 //	closed       — a merged PR exists and no open PRs remain (requires gh)
@@ -123,18 +171,18 @@ const branchWasTrackedFragment = `git -C "$FLOW_REPO_PATH" config "branch.$FLOW_
 //	open         — default; none of the above matched
 func DefaultSpec() *Spec {
 	return &Spec{
-		APIVersion: "flow/v1",
+		APIVersion: migrate.CurrentVersion,
 		Kind:       "Status",
 		Spec: SpecBody{
 			Skip: `_default=$(git -C "$FLOW_REPO_PATH" symbolic-ref refs/remotes/origin/HEAD 2>/dev/null | sed 's|refs/remotes/origin/||')
-[ -z "$_default" ] && _default=$(git -C "$FLOW_REPO_PATH" ls-remote --symref origin HEAD 2>/dev/null | awk '/^ref:/ { sub("refs/heads/", "", $2); print $2 }')
+[ -z "$_default" ] && ` + onlineFragment + ` && _default=$(git -C "$FLOW_REPO_PATH" ls-remote --symref origin HEAD 2>/dev/null | awk '/^ref:/ { sub("refs/heads/", "", $2); print $2 }')
 [ -n "$_default" ] && [ "$FLOW_REPO_BRANCH" = "$_default" ]`,
 			Statuses: []Entry{
 				{
 					Name:        "closed",
 					Description: "Merged PR, no open PRs, branch was pushed or no local divergence",
 					Color:       "131",
-					Check: `gh pr list --repo "$FLOW_REPO_SLUG" --head "$FLOW_REPO_BRANCH" --state merged --json number | jq -e 'length > 0' > /dev/null 2>&1` +
+					Check: onlineFragment + ` && gh pr list --repo "$FLOW_REPO_SLUG" --head "$FLOW_REPO_BRANCH" --state merged --json number | jq -e 'length > 0' > /dev/null 2>&1` +
 						` && gh pr list --repo "$FLOW_REPO_SLUG" --head "$FLOW_REPO_BRANCH" --state open --json number | jq -e 'length == 0' > /dev/null 2>&1` +
 						` && { ` + branchWasTrackedFragment + ` || ! { ` + defaultBranchFragment + `; git -C "$FLOW_REPO_PATH" log --oneline "origin/$_default..HEAD" 2>/dev/null | grep -q .; }; }`,
 				},
@@ -148,7 +196,7 @@ func DefaultSpec() *Spec {
 					Name:        "in-review",
 					Description: "Non-draft open PR on branch",
 					Color:       "purple",
-					Check:       `gh pr list --repo "$FLOW_REPO_SLUG" --head "$FLOW_REPO_BRANCH" --state open --json isDraft | jq -e 'map(select(.isDraft == false)) | length > 0' > /dev/null 2>&1`,
+					Check:       onlineFragment + ` && gh pr list --repo "$FLOW_REPO_SLUG" --head "$FLOW_REPO_BRANCH" --state open --json isDraft | jq -e 'map(select(.isDraft == false)) | length > 0' > /dev/null 2>&1`,
 				},
 				{
 					Name:        "in-progress",
@@ -156,7 +204,7 @@ func DefaultSpec() *Spec {
 					Check: `git -C "$FLOW_REPO_PATH" status --porcelain 2>/dev/null | grep -q .` +
 						` || git -C "$FLOW_REPO_PATH" log --oneline "origin/$FLOW_REPO_BRANCH..HEAD" 2>/dev/null | grep -q .` +
 						` || { ` + defaultBranchFragment + `; git -C "$FLOW_REPO_PATH" log --oneline "origin/$_default..HEAD" 2>/dev/null | grep -q .; }` +
-						` || gh pr list --repo "$FLOW_REPO_SLUG" --head "$FLOW_REPO_BRANCH" --state open --json isDraft | jq -e 'map(select(.isDraft)) | length > 0' > /dev/null 2>&1`,
+						` || { ` + onlineFragment + ` && gh pr list --repo "$FLOW_REPO_SLUG" --head "$FLOW_REPO_BRANCH" --state open --json isDraft | jq -e 'map(select(.isDraft)) | length > 0' > /dev/null 2>&1; }`,
 					Color: "yellow",
 				},
 				{
@@ -1,7 +1,11 @@
 // Package status handles status spec loading, validation, and resolution.
 package status
 
-import "time"
+import (
+	"time"
+
+	"github.com/milldr/flow/internal/fsutil"
+)
 
 // SpecBody holds the statuses list nested under spec.
 type SpecBody struct {
@@ -14,6 +18,9 @@ type Spec struct {
 	APIVersion string   `yaml:"apiVersion"`
 	Kind       string   `yaml:"kind"`
 	Spec       SpecBody `yaml:"spec,omitempty"`
+
+	version      fsutil.Version // file content at Load, for Save
+	migratedFrom string         // apiVersion before Load upgraded it
 }
 
 // Entry defines a single status in the spec.
@@ -78,13 +85,17 @@ func (s *Spec) ColorMap() map[string]string {
 type RepoInfo struct {
 	URL    string
 	Branch string
-	Path   string
+	// Ref is set for a repo pinned to a tag or commit. Its checks aren't
+	// run; it is reported as StatusPinned.
+	Ref  string
+	Path string
 }
 
 // RepoResult holds the resolved status for a single repo.
 type RepoResult struct {
 	URL        string
 	Branch     string
+	Ref        string
 	Status     string
 	Duration   time.Duration
 	LastCommit time.Time
@@ -0,0 +1,198 @@
+// Package template handles reusable workspace templates.
+//
+// A template is a state skeleton stored under ~/.flow/templates/<name>.yaml.
+// String fields may contain ${name} and ${branch} placeholders that are
+// substituted when a workspace is created from the template.
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/milldr/flow/internal/state"
+	"gopkg.in/yaml.v3"
+)
+
+// Placeholders recognized in template fields.
+const (
+	NameVar   = "${name}"
+	BranchVar = "${branch}"
+)
+
+// Validation errors for template files.
+var (
+	ErrInvalidAPIVersion = errors.New("apiVersion must be flow/v1")
+	ErrInvalidKind       = errors.New("kind must be Template")
+	ErrMissingRepos      = errors.New("spec.repos must not be empty")
+	ErrMissingRepoURL    = errors.New("url is required")
+	ErrMissingRepoBranch = errors.New("branch or ref is required")
+	ErrBranchRequired    = errors.New("template uses ${branch}; a branch is required")
+	ErrInvalidName       = errors.New("template name must not be empty or contain path separators")
+)
+
+// Template represents a workspace template file.
+type Template struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   Metadata   `yaml:"metadata"`
+	Spec       state.Spec `yaml:"spec"`
+}
+
+// Metadata contains template identification.
+type Metadata struct {
+	Name        string `yaml:"name,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// Vars holds the values substituted for template placeholders.
+type Vars struct {
+	Name   string // replaces ${name}
+	Branch string // replaces ${branch}
+}
+
+// Load reads and parses a template file from disk.
+func Load(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	return &t, nil
+}
+
+// Save writes a template to disk as YAML, creating the parent directory if needed.
+func Save(path string, t *Template) error {
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("marshaling template: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Validate checks that a Template has all required fields.
+func Validate(t *Template) error {
+	if t.APIVersion != "flow/v1" {
+		return ErrInvalidAPIVersion
+	}
+	if t.Kind != "Template" {
+		return ErrInvalidKind
+	}
+	if len(t.Spec.Repos) == 0 {
+		return ErrMissingRepos
+	}
+
+	for i, r := range t.Spec.Repos {
+		if r.URL == "" {
+			return fmt.Errorf("spec.repos[%d]: %w", i, ErrMissingRepoURL)
+		}
+		if r.Branch == "" && r.Ref == "" {
+			return fmt.Errorf("spec.repos[%d]: %w", i, ErrMissingRepoBranch)
+		}
+	}
+
+	return nil
+}
+
+// ValidateName checks that a template name is usable as a file name.
+func ValidateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// FromState builds a template from a workspace state. Every repo's branch is
+// replaced with the ${branch} placeholder so new workspaces get a fresh
+// feature branch; URLs, bases, and paths are kept as-is. Pinned repos stay
+// pinned to their ref, without the commit it last resolved to.
+func FromState(name string, st *state.State) *Template {
+	repos := make([]state.Repo, len(st.Spec.Repos))
+	for i, r := range st.Spec.Repos {
+		repos[i] = r
+		if r.Pinned() {
+			repos[i].Commit, repos[i].CommitRef = "", ""
+			continue
+		}
+		repos[i].Branch = BranchVar
+	}
+
+	return &Template{
+		APIVersion: "flow/v1",
+		Kind:       "Template",
+		Metadata: Metadata{
+			Name:        name,
+			Description: st.Metadata.Description,
+		},
+		Spec: state.Spec{Repos: repos},
+	}
+}
+
+// UsesBranch reports whether any repo field references the ${branch} placeholder.
+func (t *Template) UsesBranch() bool {
+	for _, r := range t.Spec.Repos {
+		for _, f := range []string{r.URL, r.Branch, r.Ref, r.Base, r.Path} {
+			if strings.Contains(f, BranchVar) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewState instantiates the template as a workspace state named name.
+// Placeholders in the description and repo fields are replaced with vars.
+func (t *Template) NewState(name string, vars Vars) (*state.State, error) {
+	if vars.Branch == "" && t.UsesBranch() {
+		return nil, ErrBranchRequired
+	}
+
+	r := strings.NewReplacer(NameVar, vars.Name, BranchVar, vars.Branch)
+
+	repos := make([]state.Repo, len(t.Spec.Repos))
+	for i, repo := range t.Spec.Repos {
+		repo.URL = r.Replace(repo.URL)
+		repo.Branch = r.Replace(repo.Branch)
+		repo.Ref = r.Replace(repo.Ref)
+		repo.Base = r.Replace(repo.Base)
+		repo.Path = r.Replace(repo.Path)
+		repos[i] = repo
+	}
+
+	return state.NewState(name, r.Replace(t.Metadata.Description), repos), nil
+}
+
+// List returns the names of all templates in dir, sorted alphabetically.
+// A missing directory yields an empty list.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".yaml" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,201 @@
+package template
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestFromStateRoundTrip(t *testing.T) {
+	st := state.NewState("vpc-ipv6", "IPv6 support", []state.Repo{
+		{URL: "github.com/acme/api", Branch: "feature/ipv6", Base: "staging"},
+		{URL: "github.com/acme/web", Branch: "feature/ipv6", Path: "frontend"},
+	})
+
+	tmpl := FromState("platform", st)
+	path := filepath.Join(t.TempDir(), "templates", "platform.yaml")
+	if err := Save(path, tmpl); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := Validate(loaded); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if loaded.Metadata.Name != "platform" {
+		t.Errorf("Name = %q, want platform", loaded.Metadata.Name)
+	}
+	if len(loaded.Spec.Repos) != 2 {
+		t.Fatalf("Repos count = %d, want 2", len(loaded.Spec.Repos))
+	}
+	for i, r := range loaded.Spec.Repos {
+		if r.Branch != BranchVar {
+			t.Errorf("Repos[%d].Branch = %q, want %s", i, r.Branch, BranchVar)
+		}
+	}
+	if loaded.Spec.Repos[0].Base != "staging" {
+		t.Errorf("Repos[0].Base = %q, want staging", loaded.Spec.Repos[0].Base)
+	}
+	if loaded.Spec.Repos[1].Path != "frontend" {
+		t.Errorf("Repos[1].Path = %q, want frontend", loaded.Spec.Repos[1].Path)
+	}
+}
+
+func TestFromStateKeepsPinnedRepos(t *testing.T) {
+	st := state.NewState("release", "", []state.Repo{
+		{URL: "github.com/acme/api", Branch: "feature/x"},
+		{URL: "github.com/acme/sdk", Ref: "v1.2.0", Commit: "0123456789abcdef0123456789abcdef01234567"},
+	})
+
+	tmpl := FromState("release", st)
+	if err := Validate(tmpl); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	sdk := tmpl.Spec.Repos[1]
+	if sdk.Branch != "" || sdk.Ref != "v1.2.0" || sdk.Commit != "" {
+		t.Errorf("pinned repo = %+v, want ref v1.2.0 without branch or commit", sdk)
+	}
+}
+
+func TestNewState(t *testing.T) {
+	tmpl := &Template{
+		APIVersion: "flow/v1",
+		Kind:       "Template",
+		Metadata:   Metadata{Description: "Work on ${name}"},
+		Spec: state.Spec{Repos: []state.Repo{
+			{URL: "github.com/acme/api", Branch: "${branch}", Base: "main"},
+			{URL: "github.com/acme/docs", Branch: "docs/${name}"},
+		}},
+	}
+
+	st, err := tmpl.NewState("my-feature", Vars{Name: "my-feature", Branch: "feat/x"})
+	if err != nil {
+		t.Fatalf("NewState: %v", err)
+	}
+	if err := state.Validate(st); err != nil {
+		t.Fatalf("state.Validate: %v", err)
+	}
+	if st.Metadata.Name != "my-feature" {
+		t.Errorf("Name = %q, want my-feature", st.Metadata.Name)
+	}
+	if st.Metadata.Description != "Work on my-feature" {
+		t.Errorf("Description = %q", st.Metadata.Description)
+	}
+	if st.Metadata.Created == "" {
+		t.Error("Created should be set")
+	}
+	if st.Spec.Repos[0].Branch != "feat/x" {
+		t.Errorf("Repos[0].Branch = %q, want feat/x", st.Spec.Repos[0].Branch)
+	}
+	if st.Spec.Repos[0].Base != "main" {
+		t.Errorf("Repos[0].Base = %q, want main", st.Spec.Repos[0].Base)
+	}
+	if st.Spec.Repos[1].Branch != "docs/my-feature" {
+		t.Errorf("Repos[1].Branch = %q, want docs/my-feature", st.Spec.Repos[1].Branch)
+	}
+
+	// Template must not be mutated by instantiation.
+	if tmpl.Spec.Repos[0].Branch != BranchVar {
+		t.Errorf("template mutated: Branch = %q", tmpl.Spec.Repos[0].Branch)
+	}
+}
+
+func TestNewStateBranchRequired(t *testing.T) {
+	tmpl := &Template{
+		Spec: state.Spec{Repos: []state.Repo{
+			{URL: "github.com/acme/api", Branch: BranchVar},
+		}},
+	}
+
+	if _, err := tmpl.NewState("ws", Vars{Name: "ws"}); !errors.Is(err, ErrBranchRequired) {
+		t.Errorf("err = %v, want ErrBranchRequired", err)
+	}
+
+	// Templates with fixed branches don't need one.
+	tmpl.Spec.Repos[0].Branch = "main"
+	if _, err := tmpl.NewState("ws", Vars{Name: "ws"}); err != nil {
+		t.Errorf("NewState with fixed branch: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Template {
+		return &Template{
+			APIVersion: "flow/v1",
+			Kind:       "Template",
+			Spec:       state.Spec{Repos: []state.Repo{{URL: "u", Branch: BranchVar}}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*Template)
+		wantErr error
+	}{
+		{"valid", func(*Template) {}, nil},
+		{"bad api version", func(t *Template) { t.APIVersion = "flow/v2" }, ErrInvalidAPIVersion},
+		{"bad kind", func(t *Template) { t.Kind = "State" }, ErrInvalidKind},
+		{"no repos", func(t *Template) { t.Spec.Repos = nil }, ErrMissingRepos},
+		{"missing url", func(t *Template) { t.Spec.Repos[0].URL = "" }, ErrMissingRepoURL},
+		{"missing branch", func(t *Template) { t.Spec.Repos[0].Branch = "" }, ErrMissingRepoBranch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := valid()
+			tt.mutate(tmpl)
+			err := Validate(tmpl)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"platform", "my-team.v2"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		if err := ValidateName(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("ValidateName(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "templates")
+
+	names, err := List(dir)
+	if err != nil {
+		t.Fatalf("List missing dir: %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("List missing dir = %v, want empty", names)
+	}
+
+	for _, n := range []string{"web", "platform"} {
+		if err := Save(filepath.Join(dir, n+".yaml"), &Template{APIVersion: "flow/v1", Kind: "Template"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err = List(dir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(names) != 2 || names[0] != "platform" || names[1] != "web" {
+		t.Errorf("List = %v, want [platform web]", names)
+	}
+}
@@ -98,3 +98,17 @@ func RelativeTime(t time.Time) string {
 		return fmt.Sprintf("%dd ago", days)
 	}
 }
+
+// FormatBytes returns a human-friendly size such as "1.5 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -78,6 +78,9 @@ func Confirm(msg string) (bool, error) {
 type DeleteRepo struct {
 	Path   string
 	Branch string
+	// Risk describes local work that deleting would lose, e.g.
+	// "uncommitted changes, 2 unpushed commit(s)". Empty when safe.
+	Risk string
 }
 
 // ConfirmReset prompts the user to confirm resetting a file to its default value.
@@ -105,14 +108,24 @@ func ConfirmDelete(name, id string, repos []DeleteRepo) (bool, error) {
 	title += "?"
 
 	Warning(title)
+	risky := 0
 	if len(repos) > 0 {
 		Print("")
 		Print("  Repos:")
 		for _, r := range repos {
-			Printf("    %s (%s)\n", r.Path, r.Branch)
+			if r.Risk == "" {
+				Printf("    %s (%s)\n", r.Path, r.Branch)
+				continue
+			}
+			risky++
+			Printf("    %s (%s) %s %s\n", r.Path, r.Branch, warningPrefix, r.Risk)
 		}
 	}
 	Print("")
+	if risky > 0 {
+		Warning(fmt.Sprintf("%d repo(s) have local work that will be lost", risky))
+		Print("")
+	}
 
 	var confirm bool
 	err := huh.NewForm(
@@ -0,0 +1,314 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheMarker, in the repo cache, records that bare repos have been moved to
+// their normalized paths.
+const cacheMarker = ".normalized"
+
+// ErrCacheConflict is returned when two clones of the same repo have a
+// branch at different commits and a worktree has it checked out.
+var ErrCacheConflict = errors.New("clones disagree on a checked-out branch")
+
+// worktreeAdmin is a linked worktree's administrative directory in a bare
+// repo (<bare>/worktrees/<id>).
+type worktreeAdmin struct {
+	ID       string
+	Dir      string
+	Worktree string
+	Head     string // contents of HEAD: "ref: refs/heads/<b>" or a commit
+}
+
+// MigrateRepoCache moves bare repos cloned before URL normalization to their
+// normalized cache paths, merging clones of the same repo made through
+// different URLs (e.g. SSH and HTTPS). Worktrees are relinked to the new
+// location. It runs once: the marker is only written when every repo was
+// migrated, so failures, reported through warn, are retried next time.
+func (s *Service) MigrateRepoCache(ctx context.Context, warn func(msg string)) error {
+	marker := filepath.Join(s.Config.ReposDir, cacheMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+
+	complete, err := s.relocateRepos(ctx, warn)
+	if err != nil || !complete {
+		return err
+	}
+	return os.WriteFile(marker, nil, 0o644)
+}
+
+// relocateRepos moves every bare repo that isn't at the cache path of its
+// origin URL there, merging it into a clone already at that path. It
+// reports whether every repo was moved; failures are reported through warn.
+func (s *Service) relocateRepos(ctx context.Context, warn func(msg string)) (bool, error) {
+	paths, err := findBareRepos(s.Config.ReposDir)
+	if err != nil {
+		return false, fmt.Errorf("scanning repo cache: %w", err)
+	}
+
+	complete := true
+	for _, p := range paths {
+		url, err := s.Git.GetConfig(ctx, p, "remote.origin.url")
+		if err != nil || url == "" {
+			s.log().Debug("skipping repo without origin", "path", p, "err", err)
+			continue
+		}
+		target := s.Config.BareRepoPath(url)
+		if target == p {
+			continue
+		}
+
+		s.log().Debug("migrating cached repo", "from", p, "to", target)
+		if err := s.relocateRepo(ctx, p, target); err != nil {
+			rel, _ := filepath.Rel(s.Config.ReposDir, p)
+			warn(fmt.Sprintf("could not migrate cached repo %s: %v", rel, err))
+			complete = false
+			continue
+		}
+		removeEmptyParents(filepath.Dir(p), s.Config.ReposDir)
+	}
+	return complete, nil
+}
+
+// relocateRepo moves the bare repo at from to to, merging it into a clone
+// already there. Both repos are locked, in path order so two processes
+// migrating the cache can't deadlock, and a repo another process has
+// already moved is left alone.
+func (s *Service) relocateRepo(ctx context.Context, from, to string) error {
+	first, second := from, to
+	if second < first {
+		first, second = second, first
+	}
+	return s.withRepoLock(ctx, first, func() error {
+		return s.withRepoLock(ctx, second, func() error {
+			if _, err := os.Stat(from); os.IsNotExist(err) {
+				return nil
+			}
+			if _, err := os.Stat(to); os.IsNotExist(err) {
+				return moveBareRepo(from, to)
+			}
+			return s.mergeBareRepo(ctx, from, to)
+		})
+	})
+}
+
+// moveBareRepo renames a bare repo and points its worktrees at the new path.
+func moveBareRepo(from, to string) error {
+	admins, err := worktreeAdmins(from)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	for _, a := range admins {
+		if err := relinkWorktree(filepath.Join(to, "worktrees", a.ID), a.Worktree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeBareRepo folds a duplicate clone into the canonical one: objects are
+// moved over, missing branches and flow refs are created, branches that
+// differ are kept under BackupRefPrefix, and worktrees are re-registered.
+// The duplicate is removed afterwards.
+func (s *Service) mergeBareRepo(ctx context.Context, from, to string) error {
+	admins, err := worktreeAdmins(from)
+	if err != nil {
+		return err
+	}
+	checkedOut := make(map[string]bool)
+	for _, a := range admins {
+		if ref, ok := strings.CutPrefix(a.Head, "ref: "); ok {
+			checkedOut[ref] = true
+		}
+	}
+
+	fromHeads, err := s.Git.ListRefs(ctx, from, "refs/heads/")
+	if err != nil {
+		return err
+	}
+	toHeads, err := s.Git.ListRefs(ctx, to, "refs/heads/")
+	if err != nil {
+		return err
+	}
+	fromFlow, err := s.Git.ListRefs(ctx, from, "refs/flow/")
+	if err != nil {
+		return err
+	}
+	toFlow, err := s.Git.ListRefs(ctx, to, "refs/flow/")
+	if err != nil {
+		return err
+	}
+
+	// ref to create in the canonical repo → commit
+	updates := make(map[string]string)
+	now := time.Now()
+	for ref, sha := range fromHeads {
+		toSHA, ok := toHeads[ref]
+		switch {
+		case !ok:
+			updates[ref] = sha
+		case toSHA == sha:
+		case checkedOut[ref]:
+			return fmt.Errorf("%w: %s", ErrCacheConflict, strings.TrimPrefix(ref, "refs/heads/"))
+		default:
+			updates[backupRef(strings.TrimPrefix(ref, "refs/heads/"), now)] = sha
+		}
+	}
+	for ref, sha := range fromFlow {
+		if _, ok := toFlow[ref]; !ok {
+			updates[ref] = sha
+		}
+	}
+
+	// Moving objects (rather than fetching) also keeps blobs that are only
+	// staged in a worktree's index and commits only a detached HEAD reaches.
+	if err := moveObjects(filepath.Join(from, "objects"), filepath.Join(to, "objects")); err != nil {
+		return fmt.Errorf("moving objects: %w", err)
+	}
+
+	refs := make([]string, 0, len(updates))
+	for ref := range updates {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		if err := s.Git.UpdateRef(ctx, to, ref, updates[ref]); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range admins {
+		dir, err := uniqueAdminDir(to, a.ID)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(a.Dir, dir); err != nil {
+			return err
+		}
+		if err := relinkWorktree(dir, a.Worktree); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(from)
+}
+
+// worktreeAdmins lists the worktrees registered in a bare repo whose
+// directories still exist.
+func worktreeAdmins(bare string) ([]worktreeAdmin, error) {
+	entries, err := os.ReadDir(filepath.Join(bare, "worktrees"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var admins []worktreeAdmin
+	for _, e := range entries {
+		dir := filepath.Join(bare, "worktrees", e.Name())
+		gitdir, err := os.ReadFile(filepath.Join(dir, "gitdir"))
+		if err != nil {
+			continue
+		}
+		dotGit := strings.TrimSpace(string(gitdir))
+		if _, err := os.Stat(dotGit); err != nil {
+			continue // stale entry; git worktree prune would drop it
+		}
+		head, err := os.ReadFile(filepath.Join(dir, "HEAD"))
+		if err != nil {
+			return nil, err
+		}
+		admins = append(admins, worktreeAdmin{
+			ID:       e.Name(),
+			Dir:      dir,
+			Worktree: filepath.Dir(dotGit),
+			Head:     strings.TrimSpace(string(head)),
+		})
+	}
+	return admins, nil
+}
+
+// relinkWorktree points a worktree's .git file at its administrative
+// directory.
+func relinkWorktree(adminDir, worktree string) error {
+	return os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+adminDir+"\n"), 0o644)
+}
+
+// uniqueAdminDir returns an unused <bare>/worktrees/<id> path, suffixing id
+// with a number if it is taken.
+func uniqueAdminDir(bare, id string) (string, error) {
+	parent := filepath.Join(bare, "worktrees")
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(parent, id)
+	for n := 1; ; n++ {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return dir, nil
+		}
+		dir = filepath.Join(parent, id+strconv.Itoa(n))
+	}
+}
+
+// moveObjects moves loose objects and packs from one object directory to
+// another, skipping files that already exist there. Object files are named
+// by content, so an existing file holds the same data. Auxiliary indexes
+// covering the source's packs only (info/, multi-pack-index, bitmaps) are
+// left behind.
+func moveObjects(from, to string) error {
+	return filepath.WalkDir(from, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == "info" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), "multi-pack-index") || strings.HasSuffix(d.Name(), ".bitmap") {
+			return nil
+		}
+		dest := filepath.Join(to, rel)
+		if _, err := os.Stat(dest); err == nil {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		return os.Rename(p, dest)
+	})
+}
+
+// removeEmptyParents removes dir and its parents up to (not including) root
+// while they are empty.
+func removeEmptyParents(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
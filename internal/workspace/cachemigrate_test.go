@@ -0,0 +1,163 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milldr/flow/internal/git"
+)
+
+// gitCmd runs git in dir with a fixed identity and returns trimmed stdout.
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// cloneInto makes a bare clone of src at dest whose origin is url, as if it
+// had been cloned from url before cache paths were normalized.
+func cloneInto(t *testing.T, src, dest, url string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "clone", "--quiet", "--bare", src, dest)
+	gitCmd(t, dest, "config", "remote.origin.url", url)
+}
+
+func TestMigrateRepoCache(t *testing.T) {
+	svc, _ := testService(t)
+	svc.Git = &git.RealRunner{}
+	ctx := context.Background()
+
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "init", "--quiet", "-b", "main")
+	if err := os.WriteFile(filepath.Join(src, "README.md"), []byte("# test"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "add", ".")
+	gitCmd(t, src, "commit", "--quiet", "-m", "initial")
+
+	repos := svc.Config.ReposDir
+	canonical := svc.Config.BareRepoPath("github.com/org/repo")
+	sshClone := filepath.Join(repos, "git@github.com:org", "repo.git")
+	httpsClone := filepath.Join(repos, "https:", "gitlab.com", "org", "other.git")
+	cloneInto(t, src, canonical, "https://github.com/org/repo")
+	cloneInto(t, src, sshClone, "git@github.com:org/repo.git")
+	cloneInto(t, src, httpsClone, "https://gitlab.com/org/other.git")
+
+	// A worktree of the SSH clone with a local commit and a staged file.
+	wsDir := t.TempDir()
+	wt := filepath.Join(wsDir, "repo")
+	gitCmd(t, sshClone, "worktree", "add", "--quiet", "-b", "feat/ssh", wt, "main")
+	if err := os.WriteFile(filepath.Join(wt, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, wt, "add", "a.txt")
+	gitCmd(t, wt, "commit", "--quiet", "-m", "local")
+	if err := os.WriteFile(filepath.Join(wt, "staged.txt"), []byte("staged"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, wt, "add", "staged.txt")
+	head := gitCmd(t, wt, "rev-parse", "HEAD")
+
+	otherWt := filepath.Join(wsDir, "other")
+	gitCmd(t, httpsClone, "worktree", "add", "--quiet", otherWt, "main")
+
+	var warnings []string
+	if err := svc.MigrateRepoCache(ctx, func(msg string) { warnings = append(warnings, msg) }); err != nil {
+		t.Fatalf("MigrateRepoCache: %v", err)
+	}
+	if len(warnings) > 0 {
+		t.Fatalf("warnings = %v", warnings)
+	}
+
+	for _, old := range []string{sshClone, httpsClone, filepath.Join(repos, "https:")} {
+		if _, err := os.Stat(old); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", old)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repos, cacheMarker)); err != nil {
+		t.Errorf("marker not written: %v", err)
+	}
+
+	// The merged worktree now lives off the canonical clone, with its branch,
+	// commit and staged changes intact.
+	if got := gitCmd(t, wt, "rev-parse", "--path-format=absolute", "--git-common-dir"); got != canonical {
+		t.Errorf("common dir = %q, want %q", got, canonical)
+	}
+	if got := gitCmd(t, canonical, "rev-parse", "refs/heads/feat/ssh"); got != head {
+		t.Errorf("feat/ssh = %q, want %q", got, head)
+	}
+	if got := gitCmd(t, wt, "status", "--porcelain"); got != "A  staged.txt" {
+		t.Errorf("status = %q, want staged.txt added", got)
+	}
+
+	// The HTTPS clone had no canonical twin and was moved.
+	moved := svc.Config.BareRepoPath("https://gitlab.com/org/other.git")
+	if got := gitCmd(t, otherWt, "rev-parse", "--path-format=absolute", "--git-common-dir"); got != moved {
+		t.Errorf("moved common dir = %q, want %q", got, moved)
+	}
+}
+
+func TestMigrateRepoCacheWaitsForLocks(t *testing.T) {
+	svc, _ := testService(t)
+	svc.Git = &git.RealRunner{}
+	svc.LockTimeout = time.Millisecond
+	ctx := context.Background()
+
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "init", "--quiet", "-b", "main")
+	gitCmd(t, src, "commit", "--quiet", "--allow-empty", "-m", "initial")
+
+	canonical := svc.Config.BareRepoPath("github.com/org/repo")
+	sshClone := filepath.Join(svc.Config.ReposDir, "git@github.com:org", "repo.git")
+	cloneInto(t, src, canonical, "https://github.com/org/repo")
+	cloneInto(t, src, sshClone, "git@github.com:org/repo.git")
+
+	// Another process is fetching into the canonical clone.
+	l, err := svc.lockRepo(ctx, canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	if err := svc.MigrateRepoCache(ctx, func(msg string) { warnings = append(warnings, msg) }); err != nil {
+		t.Fatalf("MigrateRepoCache: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "busy") {
+		t.Errorf("warnings = %v, want the busy repo", warnings)
+	}
+	if _, err := os.Stat(sshClone); err != nil {
+		t.Errorf("locked merge went ahead: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.Config.ReposDir, cacheMarker)); !os.IsNotExist(err) {
+		t.Error("marker written with a repo left to migrate")
+	}
+
+	l.Release()
+	if err := svc.MigrateRepoCache(ctx, func(msg string) { t.Errorf("warning: %s", msg) }); err != nil {
+		t.Fatalf("MigrateRepoCache: %v", err)
+	}
+	if _, err := os.Stat(sshClone); !os.IsNotExist(err) {
+		t.Error("duplicate clone not merged after the lock was released")
+	}
+}
@@ -0,0 +1,267 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// DriftKind classifies how a repo's worktree differs from state.
+type DriftKind string
+
+// Drift kinds reported by Service.Drift.
+const (
+	// DriftNone means the worktree matches state.
+	DriftNone DriftKind = ""
+	// DriftMissing means the repo is declared but has no worktree.
+	DriftMissing DriftKind = "missing"
+	// DriftBranch means a different branch is checked out.
+	DriftBranch DriftKind = "branch"
+	// DriftDetached means HEAD is detached instead of on the declared branch.
+	DriftDetached DriftKind = "detached"
+	// DriftBase means the branch was last rendered against a different base.
+	DriftBase DriftKind = "base"
+	// DriftPin means a pinned repo's worktree isn't at the commit its ref
+	// resolves to.
+	DriftPin DriftKind = "pin"
+)
+
+// DriftFix selects how Service.FixDrift resolves branch and base drift.
+type DriftFix string
+
+// Drift fix modes.
+const (
+	// DriftFixState rewrites state.yaml to match the worktrees.
+	DriftFixState DriftFix = "state"
+	// DriftFixCheckout checks out the declared branches in the worktrees.
+	DriftFixCheckout DriftFix = "checkout"
+)
+
+// ErrInvalidDriftFix is returned for an unknown --fix mode.
+var ErrInvalidDriftFix = errors.New("invalid drift fix mode")
+
+// RepoDrift compares one declared repo with its worktree.
+type RepoDrift struct {
+	Path   string
+	URL    string
+	Branch string // declared branch
+	Ref    string // declared tag or commit, for a pinned repo
+	// Pinned is the commit Ref resolves to, or the commit recorded in state
+	// if the bare repo doesn't have Ref.
+	Pinned string
+	Base   string // declared base, resolved to the default branch if unset
+	// Current is the checked-out branch, or "HEAD" when detached.
+	Current string
+	// Head is the commit the worktree is on.
+	Head string
+	// RecordedBase is the base the branch was last rendered against.
+	RecordedBase string
+	Kind         DriftKind
+}
+
+// Drifted reports whether the worktree differs from state.
+func (d RepoDrift) Drifted() bool {
+	return d.Kind != DriftNone
+}
+
+// Summary is a short description of the drift for tables.
+func (d RepoDrift) Summary() string {
+	switch d.Kind {
+	case DriftMissing:
+		return "missing worktree"
+	case DriftBranch:
+		return "on " + d.Current
+	case DriftDetached:
+		return "detached at " + ShortSHA(d.Head)
+	case DriftBase:
+		return fmt.Sprintf("base %s, state says %s", d.RecordedBase, d.Base)
+	case DriftPin:
+		return fmt.Sprintf("at %s, %s is %s", ShortSHA(d.Head), d.Ref, ShortSHA(d.Pinned))
+	}
+	return "-"
+}
+
+// DriftReport lists every difference between a workspace and its state.
+type DriftReport struct {
+	Repos []RepoDrift
+	// Extra lists worktree directories not declared in state, relative to
+	// the workspace directory.
+	Extra []string
+}
+
+// Drifted reports whether anything in the workspace differs from state.
+func (r *DriftReport) Drifted() bool {
+	if len(r.Extra) > 0 {
+		return true
+	}
+	for _, d := range r.Repos {
+		if d.Drifted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Drift compares each declared repo's worktree — checked-out branch, HEAD
+// and recorded base — with state, and lists undeclared worktrees. It only
+// reads local git state.
+func (s *Service) Drift(ctx context.Context, id string) (*DriftReport, error) {
+	st, err := s.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	wsDir := s.Config.WorkspacePath(id)
+	report := &DriftReport{}
+	for _, rc := range s.renderContexts(wsDir, st) {
+		d, err := s.repoDrift(ctx, &rc)
+		if err != nil {
+			return nil, err
+		}
+		report.Repos = append(report.Repos, d)
+	}
+
+	extra, err := findStrayPaths(wsDir, st)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("scanning for extra worktrees: %w", err)
+	}
+	report.Extra = extra
+
+	return report, nil
+}
+
+// repoDrift inspects a single declared repo.
+func (s *Service) repoDrift(ctx context.Context, rc *repoRenderContext) (RepoDrift, error) {
+	d := RepoDrift{
+		Path:   rc.repoPath,
+		URL:    rc.repo.URL,
+		Branch: rc.repo.Branch,
+		Ref:    rc.repo.Ref,
+		Base:   rc.repo.Base,
+	}
+
+	if _, err := os.Stat(rc.worktreePath); err != nil {
+		d.Kind = DriftMissing
+		return d, nil
+	}
+	if rc.repo.Pinned() {
+		return s.pinnedDrift(ctx, rc, d)
+	}
+
+	current, err := s.Git.CurrentBranch(ctx, rc.worktreePath)
+	if err != nil {
+		return d, fmt.Errorf("getting branch for %s: %w", rc.repoPath, err)
+	}
+	d.Current = current
+
+	head, err := s.Git.ResolveRef(ctx, rc.worktreePath, "HEAD")
+	if err != nil {
+		return d, fmt.Errorf("resolving HEAD for %s: %w", rc.repoPath, err)
+	}
+	d.Head = head
+
+	base, err := s.resolveBaseBranch(ctx, rc)
+	if err != nil {
+		return d, err
+	}
+	d.Base = base
+
+	recorded, err := s.Git.GetWorktreeConfig(ctx, rc.worktreePath, baseConfigKey(rc.repo.Branch))
+	if err != nil {
+		return d, fmt.Errorf("reading recorded base for %s: %w", rc.repoPath, err)
+	}
+	d.RecordedBase = recorded
+
+	switch {
+	case current == "HEAD":
+		d.Kind = DriftDetached
+	case current != rc.repo.Branch:
+		d.Kind = DriftBranch
+	case recorded != "" && recorded != base:
+		d.Kind = DriftBase
+	}
+	return d, nil
+}
+
+// FixDrift resolves branch and base drift. DriftFixState updates state.yaml
+// to the checked-out branches and recorded bases; DriftFixCheckout renders
+// the workspace so clean worktrees are switched to their declared branches
+// and missing worktrees are created from their existing branches. No branch
+// is ever reset. The remaining drift is returned.
+func (s *Service) FixDrift(ctx context.Context, id string, mode DriftFix, progress func(msg string)) (*DriftReport, error) {
+	l, err := s.lockWorkspace(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	switch mode {
+	case DriftFixState:
+		if err := s.fixDriftState(ctx, id, progress); err != nil {
+			return nil, err
+		}
+	case DriftFixCheckout:
+		err := s.render(ctx, id, progress, &RenderOptions{OnBranchConflict: BranchConflictUseExisting})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q (use %s or %s)", ErrInvalidDriftFix, mode, DriftFixState, DriftFixCheckout)
+	}
+	return s.Drift(ctx, id)
+}
+
+// fixDriftState rewrites declared branches and bases to match the worktrees.
+// A pinned repo moved to another commit is pinned to that commit instead.
+// Detached and missing worktrees can't be expressed in state and are left
+// for the caller to report.
+func (s *Service) fixDriftState(ctx context.Context, id string, progress func(msg string)) error {
+	report, err := s.Drift(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	st, err := s.Find(id)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for i, d := range report.Repos {
+		repo := &st.Spec.Repos[i]
+		switch d.Kind {
+		case DriftBranch:
+			progress(fmt.Sprintf("      └── %s branch %s → %s", d.Path, repo.Target(), d.Current))
+			repo.Branch = d.Current
+			repo.Ref, repo.Commit, repo.CommitRef = "", "", ""
+			changed = true
+		case DriftPin:
+			progress(fmt.Sprintf("      └── %s ref %s → %s", d.Path, repo.Ref, d.Head))
+			repo.Ref, repo.Commit, repo.CommitRef = d.Head, "", ""
+			changed = true
+		case DriftBase:
+			progress(fmt.Sprintf("      └── %s base %s → %s", d.Path, d.Base, d.RecordedBase))
+			repo.Base = d.RecordedBase
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	if err := state.Validate(st); err != nil {
+		return fmt.Errorf("invalid state: %w", err)
+	}
+	return state.Save(s.Config.StatePath(id), st)
+}
+
+// ShortSHA abbreviates a commit hash for display.
+func ShortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
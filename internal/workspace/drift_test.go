@@ -0,0 +1,155 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestDrift(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("drift", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "main"},
+		{URL: "github.com/org/web", Branch: "main"},
+	})
+	if err := svc.Create("drift-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "drift-ws", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.Drift(ctx, "drift-ws")
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if report.Drifted() {
+		t.Errorf("fresh render drifted: %+v", report)
+	}
+
+	// Someone ran git checkout in the worktrees, and a repo was dropped from state.
+	mock.currentBranch = "side"
+	makeStrayWorktree(t, filepath.Join(svc.Config.WorkspacePath("drift-ws"), "old"))
+	st.Spec.Repos = append(st.Spec.Repos, state.Repo{URL: "github.com/org/docs", Branch: "main"})
+	if err := state.Save(svc.Config.StatePath("drift-ws"), st); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = svc.Drift(ctx, "drift-ws")
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	wantKinds := []DriftKind{DriftBranch, DriftBranch, DriftMissing}
+	for i, want := range wantKinds {
+		if got := report.Repos[i].Kind; got != want {
+			t.Errorf("Repos[%d].Kind = %q, want %q", i, got, want)
+		}
+	}
+	if len(report.Extra) != 1 || report.Extra[0] != "old" {
+		t.Errorf("Extra = %v, want [old]", report.Extra)
+	}
+
+	mock.currentBranch = "HEAD"
+	report, err = svc.Drift(ctx, "drift-ws")
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if d := report.Repos[0]; d.Kind != DriftDetached || d.Summary() != "detached at 0123456" {
+		t.Errorf("detached drift = %q %q", d.Kind, d.Summary())
+	}
+}
+
+func TestDriftBase(t *testing.T) {
+	svc, _ := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("drift", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "main"},
+	})
+	if err := svc.Create("drift-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "drift-ws", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	st.Spec.Repos[0].Base = "develop"
+	if err := state.Save(svc.Config.StatePath("drift-ws"), st); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.Drift(ctx, "drift-ws")
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if d := report.Repos[0]; d.Kind != DriftBase || d.RecordedBase != "main" || d.Base != "develop" {
+		t.Errorf("drift = %+v, want base main vs develop", d)
+	}
+}
+
+func TestFixDriftState(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("drift", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "main"},
+	})
+	if err := svc.Create("drift-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "drift-ws", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.currentBranch = "feat/local"
+	report, err := svc.FixDrift(ctx, "drift-ws", DriftFixState, noop)
+	if err != nil {
+		t.Fatalf("FixDrift: %v", err)
+	}
+	if report.Drifted() {
+		t.Errorf("drift remains after fix: %+v", report)
+	}
+
+	loaded, err := svc.Find("drift-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Spec.Repos[0].Branch != "feat/local" {
+		t.Errorf("Branch = %q, want feat/local", loaded.Spec.Repos[0].Branch)
+	}
+
+	if _, err := svc.FixDrift(ctx, "drift-ws", "bogus", noop); !errors.Is(err, ErrInvalidDriftFix) {
+		t.Errorf("FixDrift(bogus) = %v, want ErrInvalidDriftFix", err)
+	}
+}
+
+func TestFixDriftCheckoutNeverResets(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+
+	st := state.NewState("drift", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "feat/x"},
+	})
+	if err := svc.Create("drift-ws", st); err != nil {
+		t.Fatal(err)
+	}
+
+	// The worktree is missing but its branch exists with work on it.
+	if _, err := svc.FixDrift(ctx, "drift-ws", DriftFixCheckout, noop); err != nil {
+		t.Fatalf("FixDrift: %v", err)
+	}
+	if len(mock.worktrees) != 1 || len(mock.startPoints) != 0 {
+		t.Errorf("worktrees=%v startPoints=%v, want the existing branch checked out", mock.worktrees, mock.startPoints)
+	}
+	for _, ref := range mock.resets {
+		if ref != "origin/feat/x" {
+			t.Errorf("resets = %v, want no reset to the base", mock.resets)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+)
+
+// WorkspaceUsage is the disk space used by a workspace directory. Worktree
+// objects live in the shared bare repos and are not included.
+type WorkspaceUsage struct {
+	ID       string
+	Name     string
+	Archived bool
+	Repos    int
+	Size     int64
+}
+
+// DiskUsage reports the space used by each workspace and cached bare repo.
+type DiskUsage struct {
+	Workspaces []WorkspaceUsage
+	Repos      []CacheRepo
+}
+
+// Total returns the combined size of all workspaces and repos.
+func (u *DiskUsage) Total() int64 {
+	var total int64
+	for _, w := range u.Workspaces {
+		total += w.Size
+	}
+	for _, r := range u.Repos {
+		total += r.Size
+	}
+	return total
+}
+
+// DiskUsage measures every workspace directory and bare repo in the cache.
+func (s *Service) DiskUsage(ctx context.Context) (*DiskUsage, error) {
+	infos, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &DiskUsage{}
+	for _, info := range infos {
+		size, err := dirSize(s.Config.WorkspacePath(info.ID))
+		if err != nil {
+			return nil, fmt.Errorf("measuring %s: %w", info.ID, err)
+		}
+		usage.Workspaces = append(usage.Workspaces, WorkspaceUsage{
+			ID:       info.ID,
+			Name:     info.Name,
+			Archived: info.Archived,
+			Repos:    info.RepoCount,
+			Size:     size,
+		})
+	}
+
+	usage.Repos, err = s.CacheRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
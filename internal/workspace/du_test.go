@@ -0,0 +1,50 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestDiskUsage(t *testing.T) {
+	svc, _ := testService(t)
+
+	for _, id := range []string{"ws-a", "ws-b"} {
+		st := state.NewState(id, "", []state.Repo{
+			{URL: "github.com/org/shared", Branch: id},
+		})
+		if err := svc.Create(id, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(svc.Config.WorkspacePath("ws-a"), "big"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	makeBareRepo(t, svc, "github.com/org/shared", 100)
+	makeBareRepo(t, svc, "github.com/org/orphan", 10)
+
+	usage, err := svc.DiskUsage(context.Background())
+	if err != nil {
+		t.Fatalf("DiskUsage: %v", err)
+	}
+	if len(usage.Workspaces) != 2 {
+		t.Fatalf("workspaces = %d, want 2", len(usage.Workspaces))
+	}
+	if a, b := usage.Workspaces[0], usage.Workspaces[1]; a.Size < b.Size+4096 {
+		t.Errorf("ws-a size %d not larger than ws-b %d by the extra file", a.Size, b.Size)
+	}
+
+	refs := make(map[string]int)
+	for _, r := range usage.Repos {
+		refs[r.Name] = r.Workspaces
+	}
+	if refs["github.com/org/shared"] != 2 || refs["github.com/org/orphan"] != 0 {
+		t.Errorf("repo references = %v, want shared:2 orphan:0", refs)
+	}
+	if usage.Total() < 4096+110 {
+		t.Errorf("Total() = %d, too small", usage.Total())
+	}
+}
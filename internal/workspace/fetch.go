@@ -0,0 +1,93 @@
+package workspace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/milldr/flow/internal/cache"
+	"github.com/milldr/flow/internal/config"
+	"github.com/milldr/flow/internal/lock"
+)
+
+// fetchGroup collapses concurrent fetches of the same bare repo into one.
+type fetchGroup struct {
+	mu       sync.Mutex
+	inflight map[string]*fetchCall
+	cacheMu  sync.Mutex // serializes this process's fetch cache updates
+}
+
+// fetchCall is a fetch in progress; err is set before done is closed.
+type fetchCall struct {
+	done chan struct{}
+	err  error
+}
+
+// fetch brings a bare repo up to date. It is skipped when the repo was
+// fetched within the configured TTL, and callers fetching the same repo at
+// the same time share one fetch.
+func (s *Service) fetch(ctx context.Context, barePath string) error {
+	g := &s.fetches
+	g.mu.Lock()
+	if call, ok := g.inflight[barePath]; ok {
+		g.mu.Unlock()
+		s.log().Debug("waiting for fetch in progress", "path", barePath)
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &fetchCall{done: make(chan struct{})}
+	if g.inflight == nil {
+		g.inflight = make(map[string]*fetchCall)
+	}
+	g.inflight[barePath] = call
+	g.mu.Unlock()
+
+	call.err = s.fetchIfStale(ctx, barePath)
+
+	g.mu.Lock()
+	delete(g.inflight, barePath)
+	g.mu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// fetchIfStale fetches a bare repo unless the fetch cache says it is fresh,
+// and records successful fetches.
+func (s *Service) fetchIfStale(ctx context.Context, barePath string) error {
+	ttl := config.DefaultFetchTTL
+	if s.Config.FlowConfig != nil {
+		ttl = s.Config.FlowConfig.Spec.Git.FetchFreshness()
+	}
+	path := s.Config.FetchCacheFile()
+
+	if ttl > 0 && cache.LoadFetch(path).Fresh(barePath, ttl, time.Now()) {
+		s.log().Debug("skipping fetch, fetched recently", "path", barePath, "ttl", ttl)
+		return nil
+	}
+
+	if err := s.Git.Fetch(ctx, barePath); err != nil {
+		return err
+	}
+
+	// Reload before saving, under the cache's file lock, so entries written
+	// by other fetches and other flow processes survive.
+	g := &s.fetches
+	g.cacheMu.Lock()
+	defer g.cacheMu.Unlock()
+	l, err := lock.Acquire(ctx, s.Config.FetchCacheLockPath(), "fetch cache", s.lockTimeout())
+	if err != nil {
+		s.log().Debug("could not lock fetch cache", "err", err)
+		return nil
+	}
+	defer l.Release()
+	c := cache.LoadFetch(path)
+	c[barePath] = cache.FetchEntry{FetchedAt: time.Now()}
+	if err := cache.SaveFetch(path, c); err != nil {
+		s.log().Debug("could not save fetch cache", "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package workspace
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/milldr/flow/internal/cache"
+	"github.com/milldr/flow/internal/lock"
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestRenderSkipsFreshFetch(t *testing.T) {
+	svc, mock := testService(t)
+	svc.Config.FlowConfig.Spec.Git.FetchTTL = "1m"
+	ctx := context.Background()
+
+	repo := state.Repo{URL: "github.com/org/shared", Branch: "feat/a"}
+	for _, id := range []string{"ws-a", "ws-b"} {
+		if err := svc.Create(id, state.NewState(id, "", []state.Repo{repo})); err != nil {
+			t.Fatal(err)
+		}
+		repo.Branch = "feat/b"
+	}
+
+	if err := svc.Render(ctx, "ws-a", noop, nil); err != nil {
+		t.Fatalf("Render ws-a: %v", err)
+	}
+	if err := svc.Render(ctx, "ws-b", noop, nil); err != nil {
+		t.Fatalf("Render ws-b: %v", err)
+	}
+	if len(mock.fetches) != 1 {
+		t.Errorf("fetches = %d, want 1 (second render within TTL)", len(mock.fetches))
+	}
+
+	bare := svc.Config.BareRepoPath(repo.URL)
+	entry, ok := cache.LoadFetch(svc.Config.FetchCacheFile())[bare]
+	if !ok || time.Since(entry.FetchedAt) > time.Minute {
+		t.Errorf("fetch cache entry for %s = %+v, %v", bare, entry, ok)
+	}
+
+	// An expired entry is fetched again.
+	c := cache.FetchCache{bare: {FetchedAt: time.Now().Add(-2 * time.Minute)}}
+	if err := cache.SaveFetch(svc.Config.FetchCacheFile(), c); err != nil {
+		t.Fatal(err)
+	}
+	mock.isClean = true
+	if err := svc.Sync(ctx, "ws-a", noop); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(mock.fetches) != 2 {
+		t.Errorf("fetches = %d, want 2 after the entry expired", len(mock.fetches))
+	}
+}
+
+// blockingFetchRunner holds fetches until release is closed.
+type blockingFetchRunner struct {
+	*mockRunner
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingFetchRunner) Fetch(ctx context.Context, repoPath string) error {
+	b.started <- struct{}{}
+	<-b.release
+	return b.mockRunner.Fetch(ctx, repoPath)
+}
+
+func TestFetchCollapsesConcurrentCalls(t *testing.T) {
+	svc, mock := testService(t)
+	runner := &blockingFetchRunner{mockRunner: mock, started: make(chan struct{}, 4), release: make(chan struct{})}
+	svc.Git = runner
+	// Callers that start after the fetch finished find it in the cache.
+	svc.Config.FlowConfig.Spec.Git.FetchTTL = "1m"
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = svc.fetch(ctx, "/repos/shared.git")
+		}(i)
+	}
+
+	<-runner.started
+	// Let the other callers reach the in-flight check before releasing.
+	time.Sleep(20 * time.Millisecond)
+	close(runner.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("fetch %d: %v", i, err)
+		}
+	}
+	if len(mock.fetches) != 1 {
+		t.Errorf("fetches = %d, want 1", len(mock.fetches))
+	}
+}
+
+func TestFetchWaitsForCacheLock(t *testing.T) {
+	svc, _ := testService(t)
+	ctx := context.Background()
+	path := svc.Config.FetchCacheFile()
+
+	// Another process holds the cache lock mid reload-and-save.
+	l, err := lock.Acquire(ctx, svc.Config.FetchCacheLockPath(), "fetch cache", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := cache.LoadFetch(path)
+
+	done := make(chan error, 1)
+	go func() { done <- svc.fetch(ctx, "/repos/a.git") }()
+	time.Sleep(50 * time.Millisecond)
+
+	other["/repos/b.git"] = cache.FetchEntry{FetchedAt: time.Now()}
+	if err := cache.SaveFetch(path, other); err != nil {
+		t.Fatal(err)
+	}
+	l.Release()
+	if err := <-done; err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	c := cache.LoadFetch(path)
+	for _, bare := range []string{"/repos/a.git", "/repos/b.git"} {
+		if _, ok := c[bare]; !ok {
+			t.Errorf("fetch cache is missing %s: %v", bare, c)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/milldr/flow/internal/lock"
+	"github.com/milldr/flow/internal/state"
+)
+
+// CacheRepo is a bare clone in the repo cache.
+type CacheRepo struct {
+	Path string // absolute path of the bare repo
+	Name string // path relative to the cache, without the .git suffix
+	Size int64  // bytes on disk
+	// Referenced reports whether a workspace or trash entry declares the repo.
+	Referenced bool
+	// Workspaces counts the workspaces declaring the repo, including ones in
+	// the trash.
+	Workspaces int
+	// Worktrees lists live worktrees checked out from the repo.
+	Worktrees []string
+	// Backups lists the refs under BackupRefPrefix that forced resets saved
+	// in the repo, sorted.
+	Backups []string
+}
+
+// Unused reports whether no workspace or worktree uses the repo.
+func (r CacheRepo) Unused() bool {
+	return !r.Referenced && len(r.Worktrees) == 0
+}
+
+// GCOptions configures Service.GC.
+type GCOptions struct {
+	// DryRun reports what would be removed without changing anything.
+	DryRun bool
+	// GitGC runs git gc in every kept repo.
+	GitGC bool
+	// Force removes unused repos even if they hold backup refs.
+	Force bool
+}
+
+// GCReport summarizes a cache collection.
+type GCReport struct {
+	Removed []CacheRepo // unused repos (would be) removed
+	Kept    []CacheRepo
+	// Freed is the bytes reclaimed, or that would be reclaimed by removal
+	// on a dry run.
+	Freed int64
+}
+
+// CacheRepos lists the bare repos in the cache, marking the ones declared by
+// a workspace — active, archived, or in the trash — and the ones with live
+// worktrees.
+func (s *Service) CacheRepos(ctx context.Context) ([]CacheRepo, error) {
+	paths, err := findBareRepos(s.Config.ReposDir)
+	if err != nil {
+		return nil, fmt.Errorf("scanning repo cache: %w", err)
+	}
+
+	referenced, err := s.referencedRepos()
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]CacheRepo, 0, len(paths))
+	for _, p := range paths {
+		repo, err := s.cacheRepo(ctx, p, referenced[p])
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// cacheRepo inspects the bare repo at p, which workspaces declare.
+func (s *Service) cacheRepo(ctx context.Context, p string, workspaces int) (CacheRepo, error) {
+	rel, err := filepath.Rel(s.Config.ReposDir, p)
+	if err != nil {
+		return CacheRepo{}, err
+	}
+	repo := CacheRepo{
+		Path:       p,
+		Name:       strings.TrimSuffix(rel, ".git"),
+		Referenced: workspaces > 0,
+		Workspaces: workspaces,
+	}
+	if repo.Size, err = dirSize(p); err != nil {
+		return repo, fmt.Errorf("measuring %s: %w", repo.Name, err)
+	}
+	worktrees, err := s.Git.ListWorktrees(ctx, p)
+	if err != nil {
+		return repo, fmt.Errorf("listing worktrees for %s: %w", repo.Name, err)
+	}
+	for _, wt := range worktrees {
+		if _, err := os.Stat(wt); err == nil {
+			repo.Worktrees = append(repo.Worktrees, wt)
+		}
+	}
+	backups, err := s.Git.ListRefs(ctx, p, BackupRefPrefix)
+	if err != nil {
+		return repo, fmt.Errorf("listing backup refs for %s: %w", repo.Name, err)
+	}
+	for ref := range backups {
+		repo.Backups = append(repo.Backups, ref)
+	}
+	sort.Strings(repo.Backups)
+	return repo, nil
+}
+
+// GC removes unused bare repos from the cache and prunes stale worktree
+// entries from the rest. Unused repos holding backup refs are kept unless
+// Force is set. With GitGC, kept repos are also repacked.
+func (s *Service) GC(ctx context.Context, opts GCOptions, progress func(msg string)) (*GCReport, error) {
+	repos, err := s.CacheRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{}
+	for _, repo := range repos {
+		if opts.DryRun {
+			if err := s.collectRepo(ctx, report, repo, opts, progress); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		// Don't wait on repos another flow process is using; they are
+		// left for the next run.
+		l, err := s.lockRepoWithin(ctx, repo.Path, 0)
+		if errors.Is(err, lock.ErrBusy) {
+			progress(fmt.Sprintf("      └── %s skipped (in use)", repo.Name))
+			report.Kept = append(report.Kept, repo)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		// A workspace may have started using the repo since the scan.
+		if repo.Unused() {
+			repo, err = s.rescanRepo(ctx, repo)
+		}
+		if err == nil {
+			err = s.collectRepo(ctx, report, repo, opts, progress)
+		}
+		l.Release()
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// rescanRepo inspects a repo again, with its lock held, so gc doesn't remove
+// it on the strength of an earlier scan.
+func (s *Service) rescanRepo(ctx context.Context, repo CacheRepo) (CacheRepo, error) {
+	referenced, err := s.referencedRepos()
+	if err != nil {
+		return repo, err
+	}
+	return s.cacheRepo(ctx, repo.Path, referenced[repo.Path])
+}
+
+// collectRepo removes one repo if unused, or prunes and optionally repacks
+// it, recording the outcome in report.
+func (s *Service) collectRepo(ctx context.Context, report *GCReport, repo CacheRepo, opts GCOptions, progress func(msg string)) error {
+	if repo.Unused() && (len(repo.Backups) == 0 || opts.Force) {
+		report.Removed = append(report.Removed, repo)
+		report.Freed += repo.Size
+		if opts.DryRun {
+			return nil
+		}
+		s.log().Debug("removing unused repo", "path", repo.Path)
+		if err := os.RemoveAll(repo.Path); err != nil {
+			return fmt.Errorf("removing %s: %w", repo.Name, err)
+		}
+		progress(fmt.Sprintf("      └── %s removed ✓", repo.Name))
+		return nil
+	}
+
+	report.Kept = append(report.Kept, repo)
+	if opts.DryRun {
+		return nil
+	}
+	if err := s.Git.PruneWorktrees(ctx, repo.Path); err != nil {
+		return fmt.Errorf("pruning worktrees for %s: %w", repo.Name, err)
+	}
+	if !opts.GitGC {
+		return nil
+	}
+	progress(fmt.Sprintf("      └── %s collecting garbage...", repo.Name))
+	if err := s.Git.GarbageCollect(ctx, repo.Path); err != nil {
+		return fmt.Errorf("collecting garbage in %s: %w", repo.Name, err)
+	}
+	if size, err := dirSize(repo.Path); err == nil && size < repo.Size {
+		report.Freed += repo.Size - size
+		report.Kept[len(report.Kept)-1].Size = size
+	}
+	return nil
+}
+
+// referencedRepos counts, per bare repo path, the workspaces and trash
+// entries that declare it. Trash entries count so restoring them can reuse
+// local branches.
+func (s *Service) referencedRepos() (map[string]int, error) {
+	var stateFiles []string
+	for _, dir := range []string{s.Config.WorkspacesDir, s.Config.TrashDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				stateFiles = append(stateFiles, filepath.Join(dir, e.Name(), "state.yaml"))
+			}
+		}
+	}
+
+	referenced := make(map[string]int)
+	for _, path := range stateFiles {
+		st, err := state.Load(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			// An unreadable state file may reference any repo; removing
+			// anything could break it.
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		seen := make(map[string]bool)
+		for _, r := range st.Spec.Repos {
+			p := s.Config.BareRepoPath(r.URL)
+			if !seen[p] {
+				seen[p] = true
+				referenced[p]++
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// findBareRepos returns the *.git directories under the repo cache, sorted.
+func findBareRepos(root string) ([]string, error) {
+	var repos []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() && p != root && strings.HasSuffix(d.Name(), ".git") {
+			repos = append(repos, p)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(repos)
+	return repos, nil
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
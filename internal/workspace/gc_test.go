@@ -0,0 +1,143 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// makeBareRepo creates a directory in the repo cache holding size bytes.
+func makeBareRepo(t *testing.T, svc *Service, url string, size int) string {
+	t.Helper()
+	path := svc.Config.BareRepoPath(url)
+	if err := os.MkdirAll(filepath.Join(path, "objects"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "objects", "pack"), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGC(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("gc", "", []state.Repo{
+		{URL: "github.com/org/used", Branch: "main"},
+	})
+	if err := svc.Create("gc-ws", st); err != nil {
+		t.Fatal(err)
+	}
+
+	used := makeBareRepo(t, svc, "github.com/org/used", 10)
+	unused := makeBareRepo(t, svc, "github.com/org/unused", 100)
+	busy := makeBareRepo(t, svc, "github.com/org/busy", 1000)
+
+	// A deleted workspace in the trash still references its repo.
+	trashed := makeBareRepo(t, svc, "github.com/org/trashed", 1)
+	trashDir := svc.Config.TrashPath(trashName("old-ws", time.Now(), 1))
+	if err := os.MkdirAll(trashDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Save(filepath.Join(trashDir, "state.yaml"), state.NewState("old", "", []state.Repo{
+		{URL: "github.com/org/trashed", Branch: "main"},
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	// An undeclared worktree still uses busy.
+	mock.linked = map[string][]string{busy: {t.TempDir()}}
+
+	report, err := svc.GC(ctx, GCOptions{DryRun: true}, noop)
+	if err != nil {
+		t.Fatalf("GC dry run: %v", err)
+	}
+	if len(report.Removed) != 1 || report.Removed[0].Path != unused || report.Freed != 100 {
+		t.Errorf("dry run removed = %+v, freed %d; want only unused, 100", report.Removed, report.Freed)
+	}
+	if _, err := os.Stat(unused); err != nil {
+		t.Error("dry run removed a repo")
+	}
+	if len(mock.pruned) != 0 {
+		t.Errorf("dry run pruned = %v", mock.pruned)
+	}
+
+	if _, err := svc.GC(ctx, GCOptions{GitGC: true}, noop); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if _, err := os.Stat(unused); !os.IsNotExist(err) {
+		t.Error("unused repo not removed")
+	}
+	for _, p := range []string{used, busy, trashed} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s removed: %v", p, err)
+		}
+	}
+	if len(mock.pruned) != 3 || len(mock.collected) != 3 {
+		t.Errorf("pruned = %v, collected = %v, want the 3 kept repos", mock.pruned, mock.collected)
+	}
+}
+
+func TestGCKeepsBackupRefs(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	backedUp := makeBareRepo(t, svc, "github.com/org/old", 10)
+	backup := BackupRefPrefix + "20260101-000000/feat/x"
+	mock.refs = map[string]map[string]string{backedUp: {backup: testCommit, "refs/heads/main": testCommit}}
+
+	report, err := svc.GC(ctx, GCOptions{}, noop)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if len(report.Removed) != 0 || len(report.Kept) != 1 {
+		t.Fatalf("removed = %+v, kept = %+v; want the repo kept", report.Removed, report.Kept)
+	}
+	if kept := report.Kept[0]; len(kept.Backups) != 1 || kept.Backups[0] != backup {
+		t.Errorf("Backups = %v, want [%s]", kept.Backups, backup)
+	}
+
+	if _, err := svc.GC(ctx, GCOptions{Force: true}, noop); err != nil {
+		t.Fatalf("GC --force: %v", err)
+	}
+	if _, err := os.Stat(backedUp); !os.IsNotExist(err) {
+		t.Error("repo with backup refs not removed with Force")
+	}
+}
+
+func TestGCRechecksRepoUnderLock(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	repo := makeBareRepo(t, svc, "github.com/org/new", 10)
+
+	// A workspace declaring the repo is created once gc has read the state
+	// files, before it takes the repo's lock.
+	scans := 0
+	mock.onListWorktrees = func(string) {
+		scans++
+		if scans > 1 {
+			return
+		}
+		st := state.NewState("new", "", []state.Repo{{URL: "github.com/org/new", Branch: "feat/x"}})
+		if err := svc.Create("new-ws", st); err != nil {
+			t.Error(err)
+		}
+	}
+
+	report, err := svc.GC(ctx, GCOptions{}, noop)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if len(report.Removed) != 0 {
+		t.Errorf("removed = %+v, want the newly used repo kept", report.Removed)
+	}
+	if _, err := os.Stat(repo); err != nil {
+		t.Errorf("repo removed: %v", err)
+	}
+}
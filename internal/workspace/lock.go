@@ -0,0 +1,51 @@
+package workspace
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/milldr/flow/internal/lock"
+)
+
+// DefaultLockTimeout is how long an operation waits for another flow process
+// to release a workspace or bare repo before failing with lock.ErrBusy.
+const DefaultLockTimeout = 30 * time.Second
+
+func (s *Service) lockTimeout() time.Duration {
+	if s.LockTimeout > 0 {
+		return s.LockTimeout
+	}
+	return DefaultLockTimeout
+}
+
+// lockWorkspace takes the workspace's lock. Callers that also need a bare
+// repo lock take it after this one, never before.
+func (s *Service) lockWorkspace(ctx context.Context, id string) (*lock.Lock, error) {
+	return lock.Acquire(ctx, s.Config.WorkspaceLockPath(id), "workspace "+id, s.lockTimeout())
+}
+
+// lockRepo takes a bare repo's lock, waiting up to the lock timeout.
+func (s *Service) lockRepo(ctx context.Context, barePath string) (*lock.Lock, error) {
+	return s.lockRepoWithin(ctx, barePath, s.lockTimeout())
+}
+
+// lockRepoWithin takes a bare repo's lock, waiting up to timeout.
+func (s *Service) lockRepoWithin(ctx context.Context, barePath string, timeout time.Duration) (*lock.Lock, error) {
+	name := filepath.Base(barePath)
+	if rel, err := filepath.Rel(s.Config.ReposDir, barePath); err == nil && !strings.HasPrefix(rel, "..") {
+		name = filepath.ToSlash(rel)
+	}
+	return lock.Acquire(ctx, s.Config.RepoLockPath(barePath), "repo "+strings.TrimSuffix(name, ".git"), timeout)
+}
+
+// withRepoLock runs fn while holding a bare repo's lock.
+func (s *Service) withRepoLock(ctx context.Context, barePath string, fn func() error) error {
+	l, err := s.lockRepo(ctx, barePath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	return fn()
+}
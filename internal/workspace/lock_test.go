@@ -0,0 +1,102 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milldr/flow/internal/lock"
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestRenderBusyWorkspace(t *testing.T) {
+	svc, _ := testService(t)
+	svc.LockTimeout = time.Millisecond
+	ctx := context.Background()
+
+	st := state.NewState("busy", "", []state.Repo{{URL: "github.com/org/repo", Branch: "main"}})
+	if err := svc.Create("busy-ws", st); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := lock.Acquire(ctx, svc.Config.WorkspaceLockPath("busy-ws"), "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Render(ctx, "busy-ws", noop, nil)
+	if !errors.Is(err, lock.ErrBusy) {
+		t.Fatalf("Render error = %v, want ErrBusy", err)
+	}
+	if !strings.HasPrefix(err.Error(), "workspace busy-ws is busy (pid ") {
+		t.Errorf("error = %q, want the holder's pid", err)
+	}
+	if err := svc.Sync(ctx, "busy-ws", noop); !errors.Is(err, lock.ErrBusy) {
+		t.Errorf("Sync error = %v, want ErrBusy", err)
+	}
+	if _, err := svc.Delete(ctx, "busy-ws"); !errors.Is(err, lock.ErrBusy) {
+		t.Errorf("Delete error = %v, want ErrBusy", err)
+	}
+	if _, err := os.Stat(svc.Config.WorkspacePath("busy-ws")); err != nil {
+		t.Errorf("workspace removed while locked: %v", err)
+	}
+
+	held.Release()
+	if err := svc.Render(ctx, "busy-ws", noop, nil); err != nil {
+		t.Fatalf("Render after release: %v", err)
+	}
+}
+
+func TestRenderBusyRepo(t *testing.T) {
+	svc, mock := testService(t)
+	svc.LockTimeout = time.Millisecond
+	ctx := context.Background()
+
+	repo := state.Repo{URL: "github.com/org/repo", Branch: "main"}
+	if err := svc.Create("ws", state.NewState("ws", "", []state.Repo{repo})); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := lock.Acquire(ctx, svc.Config.RepoLockPath(svc.Config.BareRepoPath(repo.URL)), "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	err = svc.Render(ctx, "ws", noop, nil)
+	if !errors.Is(err, lock.ErrBusy) {
+		t.Fatalf("Render error = %v, want ErrBusy", err)
+	}
+	if !strings.Contains(err.Error(), "repo github.com/org/repo is busy") {
+		t.Errorf("error = %q, want the repo named", err)
+	}
+	if len(mock.fetches) != 0 {
+		t.Errorf("fetches = %d, want none while the repo is locked", len(mock.fetches))
+	}
+}
+
+func TestGCSkipsBusyRepo(t *testing.T) {
+	svc, _ := testService(t)
+	ctx := context.Background()
+
+	unused := makeBareRepo(t, svc, "github.com/org/unused", 100)
+	held, err := lock.Acquire(ctx, svc.Config.RepoLockPath(unused), "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	report, err := svc.GC(ctx, GCOptions{}, noop)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if len(report.Removed) != 0 || len(report.Kept) != 1 {
+		t.Errorf("removed %d, kept %d; want the busy repo kept", len(report.Removed), len(report.Kept))
+	}
+	if _, err := os.Stat(unused); err != nil {
+		t.Errorf("busy repo removed: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/milldr/flow/internal/config"
+	"github.com/milldr/flow/internal/fsutil"
+	"github.com/milldr/flow/internal/migrate"
+	"github.com/milldr/flow/internal/state"
+	"github.com/milldr/flow/internal/status"
+)
+
+// HomeLayoutVersion is the layout of $FLOW_HOME this version of flow
+// expects. When the directory structure changes, bump it and add a
+// layoutMigration.
+const HomeLayoutVersion = 2
+
+// ErrHomeTooNew is returned when $FLOW_HOME was migrated by a newer flow.
+var ErrHomeTooNew = errors.New("flow home uses a newer layout")
+
+// layoutMigration moves $FLOW_HOME to a layout version from the one before.
+type layoutMigration struct {
+	version int
+	summary string
+	// run reports whether the migration finished. An unfinished one is
+	// retried on the next run, and later ones wait for it.
+	run func(s *Service, ctx context.Context, warn func(msg string)) (bool, error)
+}
+
+var layoutMigrations = []layoutMigration{
+	{version: 1, summary: "move bare repos to their normalized URL paths", run: (*Service).migrateRepoCacheLayout},
+	{version: 2, summary: "move bare repos of servers on non-default ports to their own paths", run: (*Service).relocateRepos},
+}
+
+// LayoutVersion returns the layout version recorded in $FLOW_HOME, or zero
+// for a home that predates recording it.
+func (s *Service) LayoutVersion() (int, error) {
+	data, err := os.ReadFile(s.Config.LayoutFile)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", s.Config.LayoutFile, err)
+	}
+	return v, nil
+}
+
+// PendingLayoutMigrations describes the layout migrations MigrateHome would
+// run, in order.
+func (s *Service) PendingLayoutMigrations() ([]string, error) {
+	current, err := s.checkLayoutVersion()
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, m := range layoutMigrations {
+		if m.version > current {
+			pending = append(pending, m.summary)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateHome brings $FLOW_HOME's directory layout up to HomeLayoutVersion,
+// recording the version after each step so an interrupted migration resumes
+// where it stopped. It returns what was done. Problems that don't stop a
+// step are reported through warn.
+func (s *Service) MigrateHome(ctx context.Context, warn func(msg string)) ([]string, error) {
+	current, err := s.checkLayoutVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []string
+	for _, m := range layoutMigrations {
+		if m.version <= current {
+			continue
+		}
+		s.log().Debug("migrating flow home", "version", m.version, "step", m.summary)
+		finished, err := m.run(s, ctx, warn)
+		if err != nil {
+			return done, fmt.Errorf("migrating flow home to layout %d: %w", m.version, err)
+		}
+		if !finished {
+			return done, nil
+		}
+		if err := fsutil.WriteFileAtomic(s.Config.LayoutFile, []byte(strconv.Itoa(m.version)+"\n"), 0o644); err != nil {
+			return done, err
+		}
+		done = append(done, m.summary)
+	}
+	return done, nil
+}
+
+func (s *Service) checkLayoutVersion() (int, error) {
+	current, err := s.LayoutVersion()
+	if err != nil {
+		return 0, err
+	}
+	if current > HomeLayoutVersion {
+		return 0, fmt.Errorf("%w (%d, this flow knows up to %d); upgrade flow", ErrHomeTooNew, current, HomeLayoutVersion)
+	}
+	return current, nil
+}
+
+// migrateRepoCacheLayout is layout migration 1.
+func (s *Service) migrateRepoCacheLayout(ctx context.Context, warn func(msg string)) (bool, error) {
+	if err := s.MigrateRepoCache(ctx, warn); err != nil {
+		return false, err
+	}
+	_, err := os.Stat(filepath.Join(s.Config.ReposDir, cacheMarker))
+	return err == nil, nil
+}
+
+// FileMigration is a file upgraded, or that would be upgraded, to the
+// current apiVersion.
+type FileMigration struct {
+	Path string
+	From string // apiVersion before the upgrade
+}
+
+// MigrateConfig upgrades the global config file and status spec to the
+// current apiVersion. With dryRun, it only reports what would change.
+func (s *Service) MigrateConfig(dryRun bool) ([]FileMigration, error) {
+	var migrated []FileMigration
+
+	fc, err := config.LoadFlowConfig(s.Config.ConfigFile)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := unmigratable(s.Config.ConfigFile, fc.APIVersion); err != nil {
+			return nil, err
+		}
+		if from := fc.MigratedFrom(); from != "" {
+			if !dryRun {
+				if err := config.SaveFlowConfig(s.Config.ConfigFile, fc); err != nil {
+					return nil, err
+				}
+			}
+			migrated = append(migrated, FileMigration{Path: s.Config.ConfigFile, From: from})
+		}
+	}
+
+	m, err := migrateStatusSpec(s.Config.StatusSpecFile, dryRun)
+	if m != nil {
+		migrated = append(migrated, *m)
+	}
+	return migrated, err
+}
+
+// MigrateWorkspace upgrades a workspace's state file and status spec to the
+// current apiVersion. With dryRun, it only reports what would change.
+func (s *Service) MigrateWorkspace(ctx context.Context, id string, dryRun bool) ([]FileMigration, error) {
+	l, err := s.lockWorkspace(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	var migrated []FileMigration
+
+	path := s.Config.StatePath(id)
+	st, err := state.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := unmigratable(path, st.APIVersion); err != nil {
+		return nil, err
+	}
+	if from := st.MigratedFrom(); from != "" {
+		if !dryRun {
+			if err := state.Save(path, st); err != nil {
+				return nil, err
+			}
+		}
+		migrated = append(migrated, FileMigration{Path: path, From: from})
+	}
+
+	m, err := migrateStatusSpec(s.Config.WorkspaceStatusSpecPath(id), dryRun)
+	if m != nil {
+		migrated = append(migrated, *m)
+	}
+	return migrated, err
+}
+
+// migrateStatusSpec upgrades a status spec, if it exists.
+func migrateStatusSpec(path string, dryRun bool) (*FileMigration, error) {
+	spec, err := status.Load(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := unmigratable(path, spec.APIVersion); err != nil {
+		return nil, err
+	}
+	if spec.MigratedFrom() == "" {
+		return nil, nil
+	}
+	if !dryRun {
+		if err := status.Save(path, spec); err != nil {
+			return nil, err
+		}
+	}
+	return &FileMigration{Path: path, From: spec.MigratedFrom()}, nil
+}
+
+// unmigratable reports a file left at an apiVersion no migration upgrades
+// from. Files without one are left for validation to reject.
+func unmigratable(path, version string) error {
+	if version == "" || version == migrate.CurrentVersion {
+		return nil
+	}
+	return fmt.Errorf("%w from %s in %s", migrate.ErrNoMigration, version, filepath.Base(path))
+}
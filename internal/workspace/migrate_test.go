@@ -0,0 +1,149 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/milldr/flow/internal/migrate"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateHome(t *testing.T) {
+	svc, _ := testService(t)
+	ctx := context.Background()
+
+	pending, err := svc.PendingLayoutMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != HomeLayoutVersion {
+		t.Fatalf("pending = %v, want %d steps", pending, HomeLayoutVersion)
+	}
+
+	done, err := svc.MigrateHome(ctx, func(msg string) { t.Errorf("warning: %s", msg) })
+	if err != nil {
+		t.Fatalf("MigrateHome: %v", err)
+	}
+	if len(done) != len(pending) {
+		t.Errorf("done = %v, want %v", done, pending)
+	}
+	if v, err := svc.LayoutVersion(); err != nil || v != HomeLayoutVersion {
+		t.Errorf("LayoutVersion = %d, %v; want %d", v, err, HomeLayoutVersion)
+	}
+
+	done, err = svc.MigrateHome(ctx, nil)
+	if err != nil || len(done) != 0 {
+		t.Errorf("second MigrateHome = %v, %v; want nothing to do", done, err)
+	}
+}
+
+func TestMigrateHomeTooNew(t *testing.T) {
+	svc, _ := testService(t)
+	if err := os.WriteFile(svc.Config.LayoutFile, []byte("99\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.MigrateHome(context.Background(), nil); !errors.Is(err, ErrHomeTooNew) {
+		t.Errorf("err = %v, want ErrHomeTooNew", err)
+	}
+}
+
+// withStateMigration registers a migration from flow/v0 state files, which
+// called spec.repos spec.repositories.
+func withStateMigration(t *testing.T) {
+	t.Helper()
+	saved := migrate.Documents
+	t.Cleanup(func() { migrate.Documents = saved })
+	migrate.Documents = migrate.Registry{{
+		Kind: "State",
+		From: "flow/v0",
+		To:   migrate.CurrentVersion,
+		Apply: func(doc *yaml.Node) error {
+			for i := 0; i+1 < len(doc.Content); i += 2 {
+				if doc.Content[i].Value != "spec" {
+					continue
+				}
+				spec := doc.Content[i+1]
+				for j := 0; j+1 < len(spec.Content); j += 2 {
+					if spec.Content[j].Value == "repositories" {
+						spec.Content[j].Value = "repos"
+					}
+				}
+			}
+			return nil
+		},
+	}}
+}
+
+func TestMigrateWorkspace(t *testing.T) {
+	withStateMigration(t)
+	svc, _ := testService(t)
+	ctx := context.Background()
+
+	old := `# Written by an old flow.
+apiVersion: flow/v0
+kind: State
+metadata:
+  name: ws
+spec:
+  repositories:
+    - url: github.com/org/repo # the service
+      branch: feat
+`
+	path := svc.Config.StatePath("ws")
+	if err := os.MkdirAll(svc.Config.WorkspacePath("ws"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loading upgrades in memory without touching the file.
+	st, err := svc.Find("ws")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(st.Spec.Repos) != 1 || st.MigratedFrom() != "flow/v0" {
+		t.Fatalf("loaded %+v from %q, want the upgraded state", st.Spec, st.MigratedFrom())
+	}
+
+	migrated, err := svc.MigrateWorkspace(ctx, "ws", true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(migrated) != 1 || migrated[0].Path != path || migrated[0].From != "flow/v0" {
+		t.Errorf("dry run = %+v, want the state file from flow/v0", migrated)
+	}
+	if data, _ := os.ReadFile(path); string(data) != old {
+		t.Errorf("dry run changed the file:\n%s", data)
+	}
+
+	if _, err := svc.MigrateWorkspace(ctx, "ws", false); err != nil {
+		t.Fatalf("MigrateWorkspace: %v", err)
+	}
+	want := strings.NewReplacer("flow/v0", "flow/v1", "repositories", "repos").Replace(old)
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("migrated file:\n%s\nwant:\n%s", data, want)
+	}
+
+	migrated, err = svc.MigrateWorkspace(ctx, "ws", false)
+	if err != nil || len(migrated) != 0 {
+		t.Errorf("second run = %+v, %v; want nothing to do", migrated, err)
+	}
+}
+
+func TestMigrateWorkspaceUnknownVersion(t *testing.T) {
+	svc, _ := testService(t)
+	if err := os.MkdirAll(svc.Config.WorkspacePath("ws"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data := "apiVersion: flow/v7\nkind: State\nspec:\n  repos: []\n"
+	if err := os.WriteFile(svc.Config.StatePath("ws"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.MigrateWorkspace(context.Background(), "ws", false); !errors.Is(err, migrate.ErrNoMigration) {
+		t.Errorf("err = %v, want ErrNoMigration", err)
+	}
+}
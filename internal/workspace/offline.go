@@ -0,0 +1,41 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotCached is returned in offline mode when a repo or ref that render or
+// sync needs is not in the bare cache.
+var ErrNotCached = errors.New("not in the local cache (offline)")
+
+// remoteRef returns the ref to use for origin/<branch> as a start point.
+// Online, origin/<branch> is fetched first. Offline, the existing
+// remote-tracking ref is used, falling back to the bare clone's branch.
+func (s *Service) remoteRef(ctx context.Context, barePath, branch string) (string, error) {
+	if !s.Offline {
+		if err := s.Git.EnsureRemoteRef(ctx, barePath, branch); err != nil {
+			return "", err
+		}
+		return "origin/" + branch, nil
+	}
+
+	ref, err := s.firstExistingRef(ctx, barePath, "refs/remotes/origin/"+branch, "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	if ref == "" {
+		return "", fmt.Errorf("%w: branch %s", ErrNotCached, branch)
+	}
+	return ref, nil
+}
+
+// refreshRemoteRef updates origin/<branch> on a best-effort basis; it does
+// nothing offline.
+func (s *Service) refreshRemoteRef(ctx context.Context, barePath, branch string) {
+	if s.Offline {
+		return
+	}
+	_ = s.Git.EnsureRemoteRef(ctx, barePath, branch)
+}
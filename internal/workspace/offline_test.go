@@ -0,0 +1,92 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestRenderOffline(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	// Populate the cache online first.
+	cached := state.Repo{URL: "github.com/org/cached", Branch: "main", Path: "./cached"}
+	if err := svc.Create("online", state.NewState("online", "", []state.Repo{cached})); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "online", noop, nil); err != nil {
+		t.Fatalf("Render (online): %v", err)
+	}
+
+	svc.Offline = true
+	mock.clones, mock.fetches, mock.remoteRefs, mock.startPoints = nil, nil, nil, nil
+
+	feature := state.Repo{URL: "github.com/org/cached", Branch: "feat/x", Path: "./cached"}
+	if err := svc.Create("offline", state.NewState("offline", "", []state.Repo{feature})); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "offline", noop, nil); err != nil {
+		t.Fatalf("Render (offline): %v", err)
+	}
+	if len(mock.clones)+len(mock.fetches)+len(mock.remoteRefs) != 0 {
+		t.Errorf("network calls offline: clones=%v fetches=%v remoteRefs=%v", mock.clones, mock.fetches, mock.remoteRefs)
+	}
+	if len(mock.startPoints) != 1 || mock.startPoints[0] != "refs/remotes/origin/main" {
+		t.Errorf("startPoints = %v, want [refs/remotes/origin/main]", mock.startPoints)
+	}
+
+	// A repo that was never cloned can't be rendered offline.
+	uncached := state.Repo{URL: "github.com/org/uncached", Branch: "main"}
+	if err := svc.Create("uncached", state.NewState("uncached", "", []state.Repo{uncached})); err != nil {
+		t.Fatal(err)
+	}
+	err := svc.Render(ctx, "uncached", noop, nil)
+	if !errors.Is(err, ErrNotCached) {
+		t.Errorf("Render (uncached) err = %v, want ErrNotCached", err)
+	}
+	if len(mock.clones) != 0 {
+		t.Errorf("clones = %v, want none", mock.clones)
+	}
+}
+
+func TestSyncOffline(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("sync-offline", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "feat/x", Path: "./repo"},
+	})
+	if err := svc.Create("sync-offline", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "sync-offline", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	svc.Offline = true
+	mock.fetches, mock.remoteRefs = nil, nil
+	mock.isClean = true
+	if err := svc.Sync(ctx, "sync-offline", noop); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(mock.fetches)+len(mock.remoteRefs) != 0 {
+		t.Errorf("network calls offline: fetches=%v remoteRefs=%v", mock.fetches, mock.remoteRefs)
+	}
+	if len(mock.rebases) != 1 || mock.rebases[0] != "refs/remotes/origin/main" {
+		t.Errorf("rebases = %v, want [refs/remotes/origin/main]", mock.rebases)
+	}
+
+	// The base branch is missing from the cache entirely.
+	mock.noRefs = true
+	mock.rebases = nil
+	err := svc.Sync(ctx, "sync-offline", noop)
+	if !errors.Is(err, ErrNotCached) {
+		t.Errorf("Sync err = %v, want ErrNotCached", err)
+	}
+	if len(mock.rebases) != 0 {
+		t.Errorf("rebases = %v, want none", mock.rebases)
+	}
+}
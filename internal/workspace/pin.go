@@ -0,0 +1,212 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// planPinned decides the render action for a repo pinned to a tag or
+// commit. It is never reset or rebased: the worktree is only created, or
+// moved to the commit it is pinned at (see pinTarget) when that changed and
+// the worktree is clean. A tag that moved away from the recorded commit is
+// reported, not followed, unless UpdatePins is set.
+func (s *Service) planPinned(ctx context.Context, rc *repoRenderContext, opts *RenderOptions, rp *RepoPlan) (*RepoPlan, error) {
+	_, statErr := os.Stat(rc.worktreePath)
+	exists := statErr == nil
+
+	if _, err := os.Stat(rc.barePath); os.IsNotExist(err) {
+		if s.Offline {
+			rp.Err = fmt.Errorf("%w: %s has never been cloned", ErrNotCached, rc.repo.URL)
+			return rp, nil
+		}
+		rp.Clone = true
+		rp.Fetch = true
+		rp.Action = ActionPin
+		return rp, nil
+	}
+
+	commit, resolved, err := s.pinTarget(ctx, rc, opts.UpdatePins)
+	if err != nil {
+		return nil, err
+	}
+	if resolved != "" && resolved != commit {
+		rp.Warnings = append(rp.Warnings, fmt.Sprintf("%s now resolves to %s; kept at recorded commit %s (render --update-pins to move)", rc.repo.Ref, ShortSHA(resolved), ShortSHA(commit)))
+	}
+	found := commit != ""
+	if found && commit != resolved {
+		if found, err = s.Git.RefExists(ctx, rc.barePath, commit); err != nil {
+			return nil, fmt.Errorf("checking %s for %s: %w", ShortSHA(commit), rc.repo.URL, err)
+		}
+	}
+	if found {
+		rp.Commit = commit
+	}
+
+	if !exists {
+		rp.Action = ActionPin
+		rp.Fetch = !s.Offline
+	} else {
+		rp.Action = ActionSkip
+		if rp.Current, err = s.Git.ResolveRef(ctx, rc.worktreePath, "HEAD"); err != nil {
+			return nil, fmt.Errorf("resolving HEAD for %s: %w", rc.repoPath, err)
+		}
+		if found && rp.Current == rp.Commit {
+			return rp, nil
+		}
+		clean, err := s.Git.IsClean(ctx, rc.worktreePath)
+		if err != nil {
+			return nil, fmt.Errorf("checking worktree status for %s: %w", rc.repo.URL, err)
+		}
+		if !clean {
+			rp.Warnings = append(rp.Warnings, fmt.Sprintf("at %s but state pins %s; uncommitted changes, not moved", ShortSHA(rp.Current), rc.repo.Ref))
+			return rp, nil
+		}
+		rp.Action = ActionPin
+		rp.Fetch = !found && !s.Offline
+	}
+
+	if !found && s.Offline {
+		rp.Err = fmt.Errorf("%w: %s is not in the local cache of %s", ErrNotCached, rc.repo.Ref, rc.repo.URL)
+	}
+	return rp, nil
+}
+
+// fetchPinnedRef fetches the tag or commit a pinned repo is checked out at,
+// and the commit recorded in state if the bare repo lacks it. Fetching an
+// existing tag picks up a move on origin. If origin can't provide the ref
+// but the bare repo already has it, the local copy is used.
+func (s *Service) fetchPinnedRef(ctx context.Context, rc *repoRenderContext) error {
+	if commit := rc.repo.RecordedCommit(); commit != "" {
+		if found, err := s.Git.RefExists(ctx, rc.barePath, commit); err == nil && !found {
+			if err := s.Git.FetchRef(ctx, rc.barePath, commit); err != nil {
+				s.log().Debug("recorded commit not fetched", "url", rc.repo.URL, "commit", commit, "error", err)
+			}
+		}
+	}
+
+	err := s.Git.FetchRef(ctx, rc.barePath, rc.repo.Ref)
+	if err == nil {
+		return nil
+	}
+	found, ferr := s.Git.RefExists(ctx, rc.barePath, rc.repo.Ref)
+	if ferr != nil || !found {
+		return fmt.Errorf("fetching %s: %w", rc.repo.Ref, err)
+	}
+	s.log().Debug("ref not fetched, using cached copy", "url", rc.repo.URL, "ref", rc.repo.Ref, "error", err)
+	return nil
+}
+
+// pin checks out the commit a pinned repo is pinned at with HEAD detached,
+// creating the worktree if it doesn't exist.
+func (s *Service) pin(ctx context.Context, rc *repoRenderContext, opts *RenderOptions, progress func(msg string)) error {
+	commit, _, err := s.pinTarget(ctx, rc, opts.UpdatePins)
+	if err != nil {
+		return err
+	}
+	if commit == "" {
+		return fmt.Errorf("%w: %s is not in the local cache of %s", ErrNotCached, rc.repo.Ref, rc.repo.URL)
+	}
+
+	if _, err := os.Stat(rc.worktreePath); err == nil {
+		if err := s.Git.CheckoutDetached(ctx, rc.worktreePath, commit); err != nil {
+			return fmt.Errorf("moving %s to %s: %w", rc.repoPath, rc.repo.Ref, err)
+		}
+	} else {
+		s.log().Debug("creating detached worktree", "path", rc.worktreePath, "ref", rc.repo.Ref, "commit", commit)
+		if err := s.Git.AddWorktreeDetached(ctx, rc.barePath, rc.worktreePath, commit); err != nil {
+			return fmt.Errorf("creating worktree for %s: %w", rc.repo.URL, err)
+		}
+	}
+	rc.commit = commit
+
+	progress(fmt.Sprintf("      └── %s (%s at %s) pinned ✓", rc.repoPath, rc.repo.Ref, ShortSHA(commit)))
+	return nil
+}
+
+// recordCommits writes the commits pinned repos were rendered at into
+// state, with the ref each was resolved from, so the workspace can be
+// reproduced even if a tag moves. A recorded commit only changes with
+// UpdatePins or a new ref, since render otherwise keeps repos at it. Repos
+// pinned to a full commit hash need no record.
+func (s *Service) recordCommits(id string, st *state.State, repos []repoRenderContext) error {
+	changed := false
+	for _, rc := range repos {
+		if rc.commit == "" {
+			continue
+		}
+		repo := &st.Spec.Repos[rc.index]
+		commit, ref := rc.commit, repo.Ref
+		if commit == repo.Ref {
+			commit, ref = "", ""
+		}
+		if commit == repo.Commit && ref == repo.CommitRef {
+			continue
+		}
+		repo.Commit, repo.CommitRef = commit, ref
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return state.Save(s.Config.StatePath(id), st)
+}
+
+// pinnedCommit returns the commit a pinned repo should be checked out at,
+// as render leaves it.
+func (s *Service) pinnedCommit(ctx context.Context, rc *repoRenderContext) (string, error) {
+	commit, _, err := s.pinTarget(ctx, rc, false)
+	return commit, err
+}
+
+// pinTarget returns the commit a pinned repo is pinned at, and the commit
+// its ref resolves to in the bare repo ("" if the bare repo lacks the ref).
+// The pinned commit is the one recorded in state for the ref, so a moved
+// tag doesn't move the worktree; with update, or when nothing is recorded
+// for the ref, it is what the ref resolves to.
+func (s *Service) pinTarget(ctx context.Context, rc *repoRenderContext, update bool) (commit, resolved string, err error) {
+	found, err := s.Git.RefExists(ctx, rc.barePath, rc.repo.Ref)
+	if err != nil {
+		return "", "", fmt.Errorf("checking %s for %s: %w", rc.repo.Ref, rc.repo.URL, err)
+	}
+	if found {
+		if resolved, err = s.Git.ResolveRef(ctx, rc.barePath, rc.repo.Ref); err != nil {
+			return "", "", fmt.Errorf("resolving %s for %s: %w", rc.repo.Ref, rc.repo.URL, err)
+		}
+	}
+	if recorded := rc.repo.RecordedCommit(); recorded != "" && !update {
+		return recorded, resolved, nil
+	}
+	return resolved, resolved, nil
+}
+
+// pinnedDrift inspects a repo pinned to a tag or commit, which should be
+// detached at the commit it is pinned at (see pinTarget). When that can't be worked
+// out locally, only a worktree on a branch is reported.
+func (s *Service) pinnedDrift(ctx context.Context, rc *repoRenderContext, d RepoDrift) (RepoDrift, error) {
+	current, err := s.Git.CurrentBranch(ctx, rc.worktreePath)
+	if err != nil {
+		return d, fmt.Errorf("getting branch for %s: %w", rc.repoPath, err)
+	}
+	d.Current = current
+
+	head, err := s.Git.ResolveRef(ctx, rc.worktreePath, "HEAD")
+	if err != nil {
+		return d, fmt.Errorf("resolving HEAD for %s: %w", rc.repoPath, err)
+	}
+	d.Head = head
+
+	if d.Pinned, err = s.pinnedCommit(ctx, rc); err != nil {
+		return d, err
+	}
+
+	switch {
+	case current != "HEAD":
+		d.Kind = DriftBranch
+	case d.Pinned != "" && head != d.Pinned:
+		d.Kind = DriftPin
+	}
+	return d, nil
+}
@@ -0,0 +1,296 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+const (
+	tagCommit   = "1111111111111111111111111111111111111111"
+	movedCommit = "2222222222222222222222222222222222222222"
+)
+
+func createPinned(t *testing.T, svc *Service, id string, repos ...state.Repo) {
+	t.Helper()
+	if err := svc.Create(id, state.NewState(id, "", repos)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderPinned(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.commits = map[string]string{"v1.2.0": tagCommit}
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: "v1.2.0"})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	if len(mock.detached) != 1 || mock.detached[0] != tagCommit {
+		t.Errorf("detached = %v, want [%s]", mock.detached, tagCommit)
+	}
+	if len(mock.fetchedRefs) != 1 || mock.fetchedRefs[0] != "v1.2.0" {
+		t.Errorf("fetchedRefs = %v, want [v1.2.0]", mock.fetchedRefs)
+	}
+	if len(mock.resets)+len(mock.rebases)+len(mock.startPoints)+len(mock.fetched) != 0 {
+		t.Errorf("pinned repo was reset, rebased or branched: resets=%v rebases=%v startPoints=%v fetched=%v",
+			mock.resets, mock.rebases, mock.startPoints, mock.fetched)
+	}
+	st, err := svc.Find("pinned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := st.Spec.Repos[0].Commit; got != tagCommit {
+		t.Errorf("recorded commit = %q, want %s", got, tagCommit)
+	}
+
+	// Already at the tag: nothing to do, nothing fetched.
+	mock.commits["HEAD"] = tagCommit
+	mock.fetches, mock.fetchedRefs, mock.detached = nil, nil, nil
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(mock.detached)+len(mock.fetches)+len(mock.fetchedRefs) != 0 {
+		t.Errorf("re-render touched a pinned worktree: detached=%v fetches=%v fetchedRefs=%v", mock.detached, mock.fetches, mock.fetchedRefs)
+	}
+
+	// The tag moved: the worktree stays at the recorded commit, with a warning.
+	mock.commits["v1.2.0"] = movedCommit
+	mock.isClean = true
+	var warnings []string
+	opts := &RenderOptions{Warn: func(msg string) { warnings = append(warnings, msg) }}
+	if err := svc.Render(ctx, "pinned", noop, opts); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(mock.detached) != 0 {
+		t.Errorf("detached = %v, want the worktree left at the recorded commit", mock.detached)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "now resolves to 2222222") {
+		t.Errorf("warnings = %v, want one about the moved tag", warnings)
+	}
+	if st, _ = svc.Find("pinned"); st.Spec.Repos[0].Commit != tagCommit {
+		t.Errorf("recorded commit = %q, want it left at %s", st.Spec.Repos[0].Commit, tagCommit)
+	}
+
+	// UpdatePins fetches the tag, follows it and records the new commit.
+	mock.fetchedRefs = nil
+	if err := svc.Render(ctx, "pinned", noop, &RenderOptions{UpdatePins: true}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(mock.fetchedRefs) != 1 || mock.fetchedRefs[0] != "v1.2.0" {
+		t.Errorf("fetchedRefs = %v, want [v1.2.0]", mock.fetchedRefs)
+	}
+	if len(mock.detached) != 1 || mock.detached[0] != movedCommit {
+		t.Errorf("detached = %v, want [%s]", mock.detached, movedCommit)
+	}
+	if st, _ = svc.Find("pinned"); st.Spec.Repos[0].Commit != movedCommit {
+		t.Errorf("recorded commit = %q, want %s", st.Spec.Repos[0].Commit, movedCommit)
+	}
+}
+
+func TestRenderPinnedDirtyNotMoved(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.commits = map[string]string{"v1.2.0": tagCommit, "HEAD": tagCommit}
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: "v1.2.0"})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.commits["v1.2.0"] = movedCommit
+	mock.detached = nil
+	var warnings []string
+	opts := &RenderOptions{UpdatePins: true, Warn: func(msg string) { warnings = append(warnings, msg) }}
+	if err := svc.Render(ctx, "pinned", noop, opts); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(mock.detached) != 0 {
+		t.Errorf("dirty worktree was moved to %v", mock.detached)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "not moved") {
+		t.Errorf("warnings = %v, want one about the worktree not being moved", warnings)
+	}
+	if st, _ := svc.Find("pinned"); st.Spec.Repos[0].Commit != tagCommit {
+		t.Errorf("recorded commit = %q, want it left at %s", st.Spec.Repos[0].Commit, tagCommit)
+	}
+}
+
+func TestRenderPinnedRefChanged(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.commits = map[string]string{"v1.0": tagCommit, "v2.0": movedCommit, "HEAD": tagCommit}
+	mock.currentBranch = "HEAD"
+	mock.isClean = true
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: "v1.0"})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := svc.Find("pinned")
+	if r := st.Spec.Repos[0]; r.Commit != tagCommit || r.CommitRef != "v1.0" {
+		t.Fatalf("recorded = %s from %q, want %s from v1.0", r.Commit, r.CommitRef, tagCommit)
+	}
+
+	// Editing ref makes the recorded commit stale: drift reports it and
+	// render moves the worktree to the new ref without --update-pins.
+	st.Spec.Repos[0].Ref = "v2.0"
+	if err := state.Save(svc.Config.StatePath("pinned"), st); err != nil {
+		t.Fatal(err)
+	}
+	report, err := svc.Drift(ctx, "pinned")
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if d := report.Repos[0]; d.Kind != DriftPin || d.Pinned != movedCommit {
+		t.Errorf("drift = %q pinned at %s, want %s at %s", d.Kind, d.Pinned, DriftPin, movedCommit)
+	}
+
+	mock.detached = nil
+	var warnings []string
+	opts := &RenderOptions{Warn: func(msg string) { warnings = append(warnings, msg) }}
+	if err := svc.Render(ctx, "pinned", noop, opts); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(mock.detached) != 1 || mock.detached[0] != movedCommit {
+		t.Errorf("detached = %v, want [%s]", mock.detached, movedCommit)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v, want none", warnings)
+	}
+	st, _ = svc.Find("pinned")
+	if r := st.Spec.Repos[0]; r.Commit != movedCommit || r.CommitRef != "v2.0" {
+		t.Errorf("recorded = %s from %q, want %s from v2.0", r.Commit, r.CommitRef, movedCommit)
+	}
+}
+
+func TestRenderPinnedToCommit(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: testCommit})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(mock.detached) != 1 || mock.detached[0] != testCommit {
+		t.Errorf("detached = %v, want [%s]", mock.detached, testCommit)
+	}
+	if st, _ := svc.Find("pinned"); st.Spec.Repos[0].Commit != "" {
+		t.Errorf("recorded commit = %q, want none for a ref that is a commit", st.Spec.Repos[0].Commit)
+	}
+}
+
+func TestPlanPinnedOfflineNotCached(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: "v1.2.0"})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	svc.Offline = true
+	mock.noRefs = true
+	mock.isClean = true
+	plan, err := svc.Plan(ctx, "pinned", nil)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	rp := plan.Repos[0]
+	if rp.Action != ActionPin || !errors.Is(rp.Err, ErrNotCached) {
+		t.Errorf("plan = %s, %v; want %s with ErrNotCached", rp.Action, rp.Err, ActionPin)
+	}
+}
+
+func TestSyncSkipsPinned(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: "v1.2.0"})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.fetches = nil
+	mock.isClean = true
+	var msgs []string
+	if err := svc.Sync(ctx, "pinned", func(msg string) { msgs = append(msgs, msg) }); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(mock.fetches)+len(mock.rebases) != 0 {
+		t.Errorf("sync fetched or rebased a pinned repo: fetches=%v rebases=%v", mock.fetches, mock.rebases)
+	}
+	if !strings.Contains(strings.Join(msgs, "\n"), "skipped (pinned to v1.2.0)") {
+		t.Errorf("progress = %v, want a pinned skip", msgs)
+	}
+}
+
+func TestDriftPinned(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.commits = map[string]string{"v1.2.0": tagCommit, "HEAD": tagCommit}
+	mock.currentBranch = "HEAD"
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: "v1.2.0"})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.Drift(ctx, "pinned")
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if report.Drifted() {
+		t.Errorf("worktree at its pinned commit drifted: %+v", report.Repos[0])
+	}
+
+	mock.commits["HEAD"] = movedCommit
+	report, err = svc.Drift(ctx, "pinned")
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if d := report.Repos[0]; d.Kind != DriftPin || d.Summary() != "at 2222222, v1.2.0 is 1111111" {
+		t.Errorf("pin drift = %q %q", d.Kind, d.Summary())
+	}
+
+	if _, err := svc.FixDrift(ctx, "pinned", DriftFixState, noop); err != nil {
+		t.Fatalf("FixDrift: %v", err)
+	}
+	st, _ := svc.Find("pinned")
+	if r := st.Spec.Repos[0]; r.Ref != movedCommit || r.Commit != "" {
+		t.Errorf("repo after --fix=state = %+v, want ref %s", r, movedCommit)
+	}
+}
+
+func TestDeleteRisksPinned(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	createPinned(t, svc, "pinned", state.Repo{URL: "github.com/org/sdk", Ref: "v1.2.0"})
+	if err := svc.Render(ctx, "pinned", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tagged commit is on no remote branch; only commits on top of it
+	// are local work.
+	mock.isClean = true
+	mock.unpushed = 12
+	risks, err := svc.DeleteRisks(ctx, "pinned")
+	if err != nil {
+		t.Fatalf("DeleteRisks: %v", err)
+	}
+	if len(risks) != 1 || risks[0].Risky() {
+		t.Errorf("risks = %+v, want a safe pinned worktree", risks)
+	}
+
+	mock.ahead = 2
+	risks, _ = svc.DeleteRisks(ctx, "pinned")
+	if risks[0].Unpushed != 2 {
+		t.Errorf("Unpushed = %d, want 2 commits on top of the pin", risks[0].Unpushed)
+	}
+}
@@ -0,0 +1,369 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// RepoAction is the worktree step render takes for a single repo.
+type RepoAction string
+
+// Render actions, in the order render evaluates them.
+const (
+	// ActionSkip leaves an already-rendered worktree untouched.
+	ActionSkip RepoAction = "skip"
+	// ActionCreateBranch creates a new branch from origin/<base>.
+	ActionCreateBranch RepoAction = "create"
+	// ActionResetBranch checks out an existing branch and hard-resets it to origin/<base>.
+	ActionResetBranch RepoAction = "reset"
+	// ActionUseExisting checks out an existing branch as-is.
+	ActionUseExisting RepoAction = "checkout"
+	// ActionSwitchBranch switches a rendered worktree to the branch in state.
+	ActionSwitchBranch RepoAction = "switch"
+	// ActionRebase rebases a rendered worktree onto its changed base.
+	ActionRebase RepoAction = "rebase"
+	// ActionPin checks out the commit a pinned repo's ref resolves to,
+	// detached, creating the worktree if it is missing.
+	ActionPin RepoAction = "pin"
+)
+
+// RepoPlan describes what render will do for a single repo.
+type RepoPlan struct {
+	Path   string
+	URL    string
+	Branch string
+	Ref    string // tag or commit a pinned repo is checked out at
+	// Commit is the commit Ref resolves to; empty until the bare repo has it.
+	Commit string
+	Base   string // resolved base branch; empty until the bare clone exists
+	Clone  bool   // bare clone is missing and will be created
+	Fetch  bool   // bare repo will be fetched before the worktree step
+	Action RepoAction
+	// Current is the branch checked out in an already-rendered worktree, or
+	// the commit it is on for a pinned repo.
+	Current string
+	// NewBranch is set for ActionSwitchBranch when the branch is created from origin/<base>.
+	NewBranch bool
+	// PrevBase is the base recorded at the last render, when it differs from Base.
+	PrevBase string
+	// Ahead counts commits on the existing branch that are not on its base.
+	// Only set for ActionResetBranch; these commits are discarded by the reset.
+	Ahead int
+	// Unpushed counts commits on the existing branch that no remote ref
+	// contains. Only set for ActionResetBranch.
+	Unpushed int
+	// Err is set when render would fail for this repo.
+	Err      error
+	Warnings []string
+
+	recordBase bool // no base recorded yet for an existing worktree
+}
+
+// RenderPlan describes everything render will do for a workspace.
+type RenderPlan struct {
+	Repos []RepoPlan
+	// Prune lists stray worktrees considered for removal (only with RenderOptions.Prune).
+	Prune []StrayWorktree
+}
+
+// Plan computes what Render would do without creating worktrees or fetching
+// repos. Unpushed commits are counted against the remote-tracking refs
+// already in the bare cache, so commits pushed since the last fetch may be
+// reported. Render makes its decisions with the same planRepo logic after
+// fetching, so the plan reflects the local bare cache as of now.
+func (s *Service) Plan(ctx context.Context, id string, opts *RenderOptions) (*RenderPlan, error) {
+	if opts == nil {
+		opts = &RenderOptions{}
+	}
+	planOpts := *opts
+	planOpts.planOnly = true
+	opts = &planOpts
+
+	st, err := s.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := state.Validate(st, s.defaultBranchRule(ctx)); err != nil {
+		return nil, fmt.Errorf("invalid state: %w", err)
+	}
+
+	wsDir := s.Config.WorkspacePath(id)
+	plan := &RenderPlan{}
+	for i, rc := range s.renderContexts(wsDir, st) {
+		rp, err := s.planRepo(ctx, &rc, opts)
+		if err != nil {
+			return nil, err
+		}
+		if rp.Clone {
+			rp.Warnings = append(rp.Warnings, "not cloned yet; branch state is checked after cloning")
+		}
+		s.log().Debug("planned repo", "index", i, "path", rp.Path, "action", rp.Action)
+		plan.Repos = append(plan.Repos, *rp)
+	}
+
+	if opts.Prune {
+		paths, err := findStrayPaths(wsDir, st)
+		if err != nil {
+			return nil, fmt.Errorf("scanning for stray worktrees: %w", err)
+		}
+		for _, rel := range paths {
+			wt, err := s.inspectStray(ctx, wsDir, rel, false)
+			if err != nil {
+				return nil, err
+			}
+			plan.Prune = append(plan.Prune, wt)
+		}
+	}
+
+	return plan, nil
+}
+
+// renderContexts builds the per-repo render contexts for a workspace.
+func (s *Service) renderContexts(wsDir string, st *state.State) []repoRenderContext {
+	repos := make([]repoRenderContext, len(st.Spec.Repos))
+	for i, repo := range st.Spec.Repos {
+		repos[i] = repoRenderContext{
+			index:        i,
+			repo:         repo,
+			repoPath:     state.RepoPath(repo),
+			barePath:     s.Config.BareRepoPath(repo.URL),
+			worktreePath: filepath.Join(wsDir, state.RepoPath(repo)),
+		}
+	}
+	return repos
+}
+
+// planRepo decides the render action for a single repo from the current
+// state of the workspace directory and the bare cache. It only reads.
+func (s *Service) planRepo(ctx context.Context, rc *repoRenderContext, opts *RenderOptions) (*RepoPlan, error) {
+	rp := &RepoPlan{
+		Path:   rc.repoPath,
+		URL:    rc.repo.URL,
+		Branch: rc.repo.Branch,
+		Ref:    rc.repo.Ref,
+		Base:   rc.repo.Base,
+	}
+
+	if rc.repo.Pinned() {
+		return s.planPinned(ctx, rc, opts, rp)
+	}
+	if _, err := os.Stat(rc.worktreePath); err == nil {
+		return s.planExistingWorktree(ctx, rc, opts, rp)
+	}
+
+	rp.Fetch = !s.Offline
+	if _, err := os.Stat(rc.barePath); os.IsNotExist(err) {
+		if s.Offline {
+			rp.Err = fmt.Errorf("%w: %s has never been cloned", ErrNotCached, rc.repo.URL)
+			return rp, nil
+		}
+		// Nothing to inspect until the clone exists.
+		rp.Clone = true
+		if s.shouldResetBranch(opts) {
+			rp.Action = ActionCreateBranch
+		} else {
+			rp.Action = ActionUseExisting
+		}
+		return rp, nil
+	}
+
+	exists, err := s.Git.BranchExists(ctx, rc.barePath, rc.repo.Branch)
+	if err != nil {
+		return nil, fmt.Errorf("checking branch for %s: %w", rc.repo.URL, err)
+	}
+
+	if !s.shouldResetBranch(opts) {
+		rp.Action = ActionUseExisting
+		if !exists {
+			rp.Err = fmt.Errorf("%w: branch %q does not exist in remote for %s\n  Hint: remove --reset=false to create a new branch, or push the branch to the remote first",
+				ErrBranchNotFound, rc.repo.Branch, rc.repoPath)
+		}
+		return rp, nil
+	}
+
+	baseBranch, err := s.resolveBaseBranch(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+	rp.Base = baseBranch
+
+	if !exists {
+		rp.Action = ActionCreateBranch
+		return rp, nil
+	}
+
+	rp.Action = ActionResetBranch
+	ahead, err := s.commitsAheadOfBase(ctx, rc.barePath, baseBranch, rc.repo.Branch)
+	if err != nil {
+		return nil, err
+	}
+	rp.Ahead = ahead
+	if ahead > 0 {
+		rp.Warnings = append(rp.Warnings, fmt.Sprintf("reset discards %d commit(s) on %s that are not on %s", ahead, rc.repo.Branch, baseBranch))
+	}
+
+	unpushed, err := s.unpushedOnBranch(ctx, rc.barePath, rc.repo.Branch, !opts.planOnly)
+	if err != nil {
+		return nil, fmt.Errorf("checking unpushed commits for %s: %w", rc.repo.URL, err)
+	}
+	rp.Unpushed = unpushed
+	if unpushed > 0 {
+		if opts.ForceReset {
+			rp.Warnings = append(rp.Warnings, fmt.Sprintf("%d unpushed commit(s) on %s will be saved under %s", unpushed, rc.repo.Branch, BackupRefPrefix))
+		} else {
+			rp.Err = fmt.Errorf("%w: %d commit(s) on %s for %s are not on any remote\n  Hint: push the branch, use --reset=false to keep it, or re-run with --force-reset to back it up under %s and reset",
+				ErrUnpushedCommits, unpushed, rc.repo.Branch, rc.repoPath, BackupRefPrefix)
+		}
+	}
+	return rp, nil
+}
+
+// unpushedOnBranch counts commits on the bare repo's local branch that no
+// remote-tracking ref contains. When there are any and refresh is set,
+// origin/<branch> is refreshed (best effort) and the count repeated, so
+// commits pushed from elsewhere aren't reported.
+func (s *Service) unpushedOnBranch(ctx context.Context, barePath, branch string, refresh bool) (int, error) {
+	ref := "refs/heads/" + branch
+	n, err := s.Git.UnpushedCommits(ctx, barePath, ref)
+	if err != nil || n == 0 || !refresh {
+		return n, err
+	}
+	s.refreshRemoteRef(ctx, barePath, branch)
+	return s.Git.UnpushedCommits(ctx, barePath, ref)
+}
+
+// planExistingWorktree compares an already-rendered worktree with state.
+// A changed branch is switched and a changed base rebased (with
+// RenderOptions.Rebase), but only when the worktree is clean; otherwise the
+// worktree is left alone and a warning is added.
+func (s *Service) planExistingWorktree(ctx context.Context, rc *repoRenderContext, opts *RenderOptions, rp *RepoPlan) (*RepoPlan, error) {
+	rp.Action = ActionSkip
+
+	current, err := s.Git.CurrentBranch(ctx, rc.worktreePath)
+	if err != nil {
+		return nil, fmt.Errorf("getting branch for %s: %w", rc.repo.URL, err)
+	}
+	rp.Current = current
+
+	baseBranch, err := s.resolveBaseBranch(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+	rp.Base = baseBranch
+
+	if current != rc.repo.Branch {
+		clean, err := s.Git.IsClean(ctx, rc.worktreePath)
+		if err != nil {
+			return nil, fmt.Errorf("checking worktree status for %s: %w", rc.repo.URL, err)
+		}
+		if !clean {
+			rp.Warnings = append(rp.Warnings, fmt.Sprintf("on %s but state says %s; uncommitted changes, not switched", current, rc.repo.Branch))
+			return rp, nil
+		}
+
+		exists, err := s.Git.BranchExists(ctx, rc.barePath, rc.repo.Branch)
+		if err != nil {
+			return nil, fmt.Errorf("checking branch for %s: %w", rc.repo.URL, err)
+		}
+		rp.Action = ActionSwitchBranch
+		rp.Fetch = !s.Offline
+		rp.NewBranch = !exists
+		return rp, nil
+	}
+
+	recorded, err := s.Git.GetWorktreeConfig(ctx, rc.worktreePath, baseConfigKey(rc.repo.Branch))
+	if err != nil {
+		return nil, fmt.Errorf("reading recorded base for %s: %w", rc.repo.URL, err)
+	}
+	if recorded == "" {
+		rp.recordBase = true
+		return rp, nil
+	}
+	if recorded == baseBranch {
+		return rp, nil
+	}
+
+	rp.PrevBase = recorded
+	if !opts.Rebase {
+		rp.Warnings = append(rp.Warnings, fmt.Sprintf("base changed from %s to %s; re-run with --rebase to rebase onto origin/%s", recorded, baseBranch, baseBranch))
+		return rp, nil
+	}
+
+	clean, err := s.Git.IsClean(ctx, rc.worktreePath)
+	if err != nil {
+		return nil, fmt.Errorf("checking worktree status for %s: %w", rc.repo.URL, err)
+	}
+	if !clean {
+		rp.Warnings = append(rp.Warnings, fmt.Sprintf("base changed from %s to %s; uncommitted changes, not rebased", recorded, baseBranch))
+		return rp, nil
+	}
+	rp.Action = ActionRebase
+	rp.Fetch = !s.Offline
+	return rp, nil
+}
+
+// needsFetch reports whether render must clone or fetch a repo before its
+// worktree step: the worktree is missing, it will be switched or rebased, or
+// it is pinned and UpdatePins is set.
+// Offline, only missing worktrees need their bare clone checked.
+func (s *Service) needsFetch(ctx context.Context, rc *repoRenderContext, opts *RenderOptions) (bool, error) {
+	if _, err := os.Stat(rc.worktreePath); err != nil {
+		return true, nil
+	}
+	if rc.repo.Pinned() {
+		if opts.UpdatePins && !s.Offline {
+			return true, nil // to pick up tags moved on the remote
+		}
+		rp, err := s.planPinned(ctx, rc, opts, &RepoPlan{})
+		if err != nil {
+			return false, err
+		}
+		return rp.Fetch, nil
+	}
+	rp, err := s.planExistingWorktree(ctx, rc, opts, &RepoPlan{})
+	if err != nil {
+		return false, err
+	}
+	return rp.Fetch, nil
+}
+
+// commitsAheadOfBase counts commits on branch that are not on base. The
+// remote-tracking ref for base is preferred; the bare clone's local ref is
+// used when origin/<base> hasn't been created yet. Returns 0 when either
+// side isn't in the bare cache.
+func (s *Service) commitsAheadOfBase(ctx context.Context, barePath, base, branch string) (int, error) {
+	baseRef, err := s.firstExistingRef(ctx, barePath, "refs/remotes/origin/"+base, "refs/heads/"+base)
+	if err != nil || baseRef == "" {
+		return 0, err
+	}
+	branchRef, err := s.firstExistingRef(ctx, barePath, "refs/heads/"+branch, "refs/remotes/origin/"+branch)
+	if err != nil || branchRef == "" {
+		return 0, err
+	}
+
+	ahead, err := s.Git.CommitsAhead(ctx, barePath, baseRef, branchRef)
+	if err != nil {
+		return 0, fmt.Errorf("comparing %s with %s: %w", branch, base, err)
+	}
+	return ahead, nil
+}
+
+// firstExistingRef returns the first of refs that exists in the repository,
+// or an empty string if none do.
+func (s *Service) firstExistingRef(ctx context.Context, repoPath string, refs ...string) (string, error) {
+	for _, ref := range refs {
+		ok, err := s.Git.RefExists(ctx, repoPath, ref)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return ref, nil
+		}
+	}
+	return "", nil
+}
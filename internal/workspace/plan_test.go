@@ -0,0 +1,195 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestPlanDoesNotModify(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("plan", "", []state.Repo{
+		{URL: "github.com/org/new", Branch: "feat/x"},
+		{URL: "github.com/org/done", Branch: "feat/x"},
+	})
+	if err := svc.Create("plan-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(svc.Config.WorkspacePath("plan-ws"), "done"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := svc.Plan(ctx, "plan-ws", nil)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan.Repos) != 2 {
+		t.Fatalf("Repos = %d, want 2", len(plan.Repos))
+	}
+
+	fresh := plan.Repos[0]
+	if !fresh.Clone || !fresh.Fetch || fresh.Action != ActionCreateBranch {
+		t.Errorf("new repo plan = %+v, want clone+fetch+create", fresh)
+	}
+	if len(fresh.Warnings) == 0 {
+		t.Error("expected a warning for an uncloned repo")
+	}
+	if done := plan.Repos[1]; done.Fetch || done.Action != ActionSkip {
+		t.Errorf("existing repo plan = %+v, want skip without fetch", done)
+	}
+
+	if len(mock.clones) != 0 || len(mock.fetches) != 0 || len(mock.worktrees) != 0 {
+		t.Errorf("Plan touched git: clones=%v fetches=%v worktrees=%v", mock.clones, mock.fetches, mock.worktrees)
+	}
+}
+
+func TestPlanResetWarnsAboutDiscardedCommits(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+	mock.ahead = 3
+
+	st := state.NewState("plan", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "feat/x", Base: "staging"},
+	})
+	if err := svc.Create("plan-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(svc.Config.BareRepoPath("github.com/org/repo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := svc.Plan(ctx, "plan-ws", &RenderOptions{OnBranchConflict: BranchConflictReset})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	rp := plan.Repos[0]
+	if rp.Clone || !rp.Fetch {
+		t.Errorf("Clone=%v Fetch=%v, want fetch only", rp.Clone, rp.Fetch)
+	}
+	if rp.Action != ActionResetBranch {
+		t.Errorf("Action = %q, want reset", rp.Action)
+	}
+	if rp.Base != "staging" {
+		t.Errorf("Base = %q, want staging", rp.Base)
+	}
+	if rp.Ahead != 3 {
+		t.Errorf("Ahead = %d, want 3", rp.Ahead)
+	}
+	if len(rp.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want one discard warning", rp.Warnings)
+	}
+}
+
+func TestPlanUseExistingMissingBranch(t *testing.T) {
+	svc, _ := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("plan", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "feat/missing"},
+	})
+	if err := svc.Create("plan-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(svc.Config.BareRepoPath("github.com/org/repo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := svc.Plan(ctx, "plan-ws", &RenderOptions{OnBranchConflict: BranchConflictUseExisting})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if rp := plan.Repos[0]; rp.Action != ActionUseExisting || !errors.Is(rp.Err, ErrBranchNotFound) {
+		t.Errorf("plan = %+v, want checkout with ErrBranchNotFound", rp)
+	}
+}
+
+func TestRenderRefusesResetWithUnpushedCommits(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+	mock.unpushed = 2
+
+	st := state.NewState("unpushed", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "feat/x"},
+	})
+	if err := svc.Create("unpushed-ws", st); err != nil {
+		t.Fatal(err)
+	}
+
+	err := svc.Render(ctx, "unpushed-ws", noop, nil)
+	if !errors.Is(err, ErrUnpushedCommits) {
+		t.Fatalf("Render err = %v, want ErrUnpushedCommits", err)
+	}
+	if len(mock.worktrees) != 0 || len(mock.resets) != 0 {
+		t.Errorf("worktrees=%v resets=%v, want none", mock.worktrees, mock.resets)
+	}
+	if len(mock.backups) != 0 {
+		t.Errorf("backups = %v, want none", mock.backups)
+	}
+}
+
+func TestRenderForceResetBacksUpBranch(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+	mock.unpushed = 2
+
+	st := state.NewState("unpushed", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "feat/x"},
+	})
+	if err := svc.Create("unpushed-ws", st); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Render(ctx, "unpushed-ws", noop, &RenderOptions{ForceReset: true}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(mock.backups) != 1 || !strings.HasPrefix(mock.backups[0], BackupRefPrefix) || !strings.HasSuffix(mock.backups[0], "/feat/x") {
+		t.Errorf("backups = %v, want one %s<timestamp>/feat/x ref", mock.backups, BackupRefPrefix)
+	}
+	if len(mock.resets) != 1 || mock.resets[0] != "origin/main" {
+		t.Errorf("resets = %v, want [origin/main]", mock.resets)
+	}
+}
+
+func TestPlanDoesNotRefreshRemoteRefs(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+	mock.unpushed = 2
+	mock.currentBranch = "feat/old"
+
+	st := state.NewState("plan", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "feat/x"},
+	})
+	if err := svc.Create("plan-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(svc.Config.BareRepoPath("github.com/org/repo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	makeStrayWorktree(t, filepath.Join(svc.Config.WorkspacePath("plan-ws"), "old"))
+
+	plan, err := svc.Plan(ctx, "plan-ws", &RenderOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if rp := plan.Repos[0]; rp.Unpushed != 2 || !errors.Is(rp.Err, ErrUnpushedCommits) {
+		t.Errorf("plan = %+v, want 2 unpushed commits", rp)
+	}
+	if len(plan.Prune) != 1 || plan.Prune[0].Unpushed != 2 {
+		t.Errorf("prune = %+v, want one stray with 2 unpushed commits", plan.Prune)
+	}
+	if len(mock.remoteRefs)+len(mock.fetches) != 0 {
+		t.Errorf("Plan fetched: remoteRefs=%v fetches=%v", mock.remoteRefs, mock.fetches)
+	}
+}
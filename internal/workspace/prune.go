@@ -0,0 +1,187 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// ErrPruneRefused is returned when render --prune leaves behind stray
+// worktrees that still hold local work.
+var ErrPruneRefused = errors.New("refusing to prune worktrees with local work")
+
+// StrayWorktree is a worktree directory in a workspace that is no longer
+// declared in state.yaml.
+type StrayWorktree struct {
+	Path     string // relative to the workspace directory
+	BarePath string // repository that owns the worktree
+	Branch   string
+	Dirty    bool
+	Unpushed int // commits not reachable from any remote ref
+}
+
+// Safe reports whether the worktree can be removed without losing work.
+func (w StrayWorktree) Safe() bool {
+	return !w.Dirty && w.Unpushed == 0
+}
+
+// Reason describes why an unsafe worktree is kept.
+func (w StrayWorktree) Reason() string {
+	var reasons []string
+	if w.Dirty {
+		reasons = append(reasons, "uncommitted changes")
+	}
+	if w.Unpushed > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d unpushed commit(s)", w.Unpushed))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// findStrayPaths walks the workspace directory for git worktrees that are not
+// declared in state. Hidden directories (e.g. .claude) and declared repo paths
+// are not descended into. Returned paths are relative to wsDir.
+func findStrayPaths(wsDir string, st *state.State) ([]string, error) {
+	declared := make(map[string]bool, len(st.Spec.Repos))
+	for _, r := range st.Spec.Repos {
+		declared[filepath.Clean(state.RepoPath(r))] = true
+	}
+
+	var strays []string
+	err := filepath.WalkDir(wsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == wsDir || !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(wsDir, p)
+		if err != nil {
+			return err
+		}
+		if declared[rel] || strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		// Linked worktrees have a .git file (not directory) pointing at the bare repo.
+		if info, err := os.Lstat(filepath.Join(p, ".git")); err == nil && info.Mode().IsRegular() {
+			strays = append(strays, rel)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return strays, nil
+}
+
+// strayContexts returns render contexts for the stray worktrees in a
+// workspace, so they can be rescued and removed like declared repos. A
+// worktree whose repository can't be located has no entry to clean up and
+// is skipped.
+func (s *Service) strayContexts(ctx context.Context, wsDir string, st *state.State) ([]repoRenderContext, error) {
+	paths, err := findStrayPaths(wsDir, st)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("scanning for stray worktrees: %w", err)
+	}
+	var strays []repoRenderContext
+	for _, rel := range paths {
+		path := filepath.Join(wsDir, rel)
+		barePath, err := s.Git.CommonDir(ctx, path)
+		if err != nil {
+			s.log().Debug("skipping stray worktree", "path", rel, "err", err)
+			continue
+		}
+		strays = append(strays, repoRenderContext{
+			index:        -1,
+			repoPath:     rel,
+			barePath:     barePath,
+			worktreePath: path,
+		})
+	}
+	return strays, nil
+}
+
+// inspectStray gathers the branch and local-work state of a stray worktree.
+// With refresh, origin/<branch> is fetched first.
+func (s *Service) inspectStray(ctx context.Context, wsDir, rel string, refresh bool) (StrayWorktree, error) {
+	wt := StrayWorktree{Path: rel}
+	path := filepath.Join(wsDir, rel)
+
+	barePath, err := s.Git.CommonDir(ctx, path)
+	if err != nil {
+		return wt, fmt.Errorf("locating repository for %s: %w", rel, err)
+	}
+	wt.BarePath = barePath
+
+	branch, err := s.Git.CurrentBranch(ctx, path)
+	if err != nil {
+		return wt, fmt.Errorf("getting branch for %s: %w", rel, err)
+	}
+	wt.Branch = branch
+
+	clean, err := s.Git.IsClean(ctx, path)
+	if err != nil {
+		return wt, fmt.Errorf("checking worktree status for %s: %w", rel, err)
+	}
+	wt.Dirty = !clean
+
+	unpushed, err := s.localWork(ctx, path, barePath, branch, "", refresh)
+	if err != nil {
+		return wt, fmt.Errorf("checking unpushed commits for %s: %w", rel, err)
+	}
+	wt.Unpushed = unpushed
+
+	return wt, nil
+}
+
+// pruneWorktrees removes stray worktrees that hold no local work. Worktrees
+// with uncommitted changes or unpushed commits are kept and reported via
+// ErrPruneRefused once every stray has been processed.
+func (s *Service) pruneWorktrees(ctx context.Context, wsDir string, st *state.State, progress func(msg string)) error {
+	paths, err := findStrayPaths(wsDir, st)
+	if err != nil {
+		return fmt.Errorf("scanning for stray worktrees: %w", err)
+	}
+
+	if len(paths) == 0 {
+		progress("No stray worktrees to prune")
+		return nil
+	}
+
+	progress(fmt.Sprintf("Pruning %d stray worktree(s)", len(paths)))
+
+	var kept []string
+	for _, rel := range paths {
+		wt, err := s.inspectStray(ctx, wsDir, rel, true)
+		if err != nil {
+			return err
+		}
+
+		if !wt.Safe() {
+			progress(fmt.Sprintf("      └── %s (%s) kept: %s", wt.Path, wt.Branch, wt.Reason()))
+			kept = append(kept, fmt.Sprintf("%s (%s)", wt.Path, wt.Reason()))
+			continue
+		}
+
+		s.log().Debug("pruning worktree", "path", rel, "bare", wt.BarePath)
+		err = s.withRepoLock(ctx, wt.BarePath, func() error {
+			return s.Git.RemoveWorktree(ctx, wt.BarePath, filepath.Join(wsDir, rel))
+		})
+		if err != nil {
+			return fmt.Errorf("removing worktree %s: %w", rel, err)
+		}
+		progress(fmt.Sprintf("      └── %s (%s) removed ✓", wt.Path, wt.Branch))
+	}
+
+	if len(kept) > 0 {
+		return fmt.Errorf("%w: %s\n  Hint: commit and push or discard the changes, then re-run with --prune",
+			ErrPruneRefused, strings.Join(kept, "; "))
+	}
+	return nil
+}
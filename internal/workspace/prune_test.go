@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// makeStrayWorktree creates a directory that looks like a linked worktree.
+func makeStrayWorktree(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: /bare/worktrees/x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindStrayPaths(t *testing.T) {
+	wsDir := t.TempDir()
+	st := state.NewState("ws", "", []state.Repo{
+		{URL: "github.com/org/keep", Branch: "main"},
+		{URL: "github.com/org/nested", Branch: "main", Path: "libs/nested"},
+	})
+
+	makeStrayWorktree(t, filepath.Join(wsDir, "keep"))
+	makeStrayWorktree(t, filepath.Join(wsDir, "libs", "nested"))
+	makeStrayWorktree(t, filepath.Join(wsDir, "old"))
+	makeStrayWorktree(t, filepath.Join(wsDir, "libs", "gone"))
+	makeStrayWorktree(t, filepath.Join(wsDir, ".claude", "hidden"))
+	if err := os.MkdirAll(filepath.Join(wsDir, "notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := findStrayPaths(wsDir, st)
+	if err != nil {
+		t.Fatalf("findStrayPaths: %v", err)
+	}
+	want := []string{filepath.Join("libs", "gone"), "old"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("findStrayPaths = %v, want %v", got, want)
+	}
+}
+
+func TestRenderPruneRemovesStray(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.isClean = true
+
+	st := state.NewState("prune", "", []state.Repo{
+		{URL: "github.com/org/repo-a", Branch: "main"},
+	})
+	if err := svc.Create("prune-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	stray := filepath.Join(svc.Config.WorkspacePath("prune-ws"), "repo-b")
+	makeStrayWorktree(t, stray)
+
+	var messages []string
+	err := svc.Render(ctx, "prune-ws", func(msg string) { messages = append(messages, msg) }, &RenderOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Error("stray worktree should be removed")
+	}
+	if len(mock.removed) != 1 || mock.removed[0] != stray {
+		t.Errorf("removed = %v, want [%s]", mock.removed, stray)
+	}
+	if !strings.Contains(strings.Join(messages, "\n"), "repo-b (main) removed") {
+		t.Errorf("expected removal message, got: %v", messages)
+	}
+}
+
+func TestRenderPruneRefusesDirty(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.isClean = false
+
+	st := state.NewState("prune", "", []state.Repo{
+		{URL: "github.com/org/repo-a", Branch: "main"},
+	})
+	if err := svc.Create("prune-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	stray := filepath.Join(svc.Config.WorkspacePath("prune-ws"), "repo-b")
+	makeStrayWorktree(t, stray)
+
+	err := svc.Render(ctx, "prune-ws", noop, &RenderOptions{Prune: true})
+	if !errors.Is(err, ErrPruneRefused) {
+		t.Fatalf("Render err = %v, want ErrPruneRefused", err)
+	}
+	if !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Errorf("error should explain why: %v", err)
+	}
+	if _, err := os.Stat(stray); err != nil {
+		t.Error("dirty stray worktree should be kept")
+	}
+	if len(mock.removed) != 0 {
+		t.Errorf("removed = %v, want none", mock.removed)
+	}
+}
+
+func TestRenderPruneRefusesUnpushed(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.isClean = true
+	mock.unpushed = 3
+
+	st := state.NewState("prune", "", []state.Repo{
+		{URL: "github.com/org/repo-a", Branch: "main"},
+	})
+	if err := svc.Create("prune-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	makeStrayWorktree(t, filepath.Join(svc.Config.WorkspacePath("prune-ws"), "repo-b"))
+
+	err := svc.Render(ctx, "prune-ws", noop, &RenderOptions{Prune: true})
+	if !errors.Is(err, ErrPruneRefused) {
+		t.Fatalf("Render err = %v, want ErrPruneRefused", err)
+	}
+	if !strings.Contains(err.Error(), "3 unpushed commit(s)") {
+		t.Errorf("error should mention unpushed commits: %v", err)
+	}
+}
+
+func TestRenderWithoutPruneKeepsStray(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.isClean = true
+
+	st := state.NewState("prune", "", []state.Repo{
+		{URL: "github.com/org/repo-a", Branch: "main"},
+	})
+	if err := svc.Create("prune-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	stray := filepath.Join(svc.Config.WorkspacePath("prune-ws"), "repo-b")
+	makeStrayWorktree(t, stray)
+
+	if err := svc.Render(ctx, "prune-ws", noop, nil); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if _, err := os.Stat(stray); err != nil {
+		t.Error("stray worktree should be untouched without --prune")
+	}
+}
@@ -0,0 +1,221 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// rescueDir is the workspace subdirectory holding work saved from removed
+// worktrees.
+const rescueDir = "archive"
+
+// ErrRescueDirConflict is returned when a repo path overlaps the directory
+// rescued work is saved to.
+var ErrRescueDirConflict = errors.New("repo path conflicts with the rescue directory")
+
+// checkRescueDir fails if a declared repo lives in the rescue directory,
+// where saved work would be removed along with the worktree.
+func checkRescueDir(st *state.State) error {
+	for _, r := range st.Spec.Repos {
+		p := filepath.Clean(state.RepoPath(r))
+		if p == rescueDir || strings.HasPrefix(p, rescueDir+string(filepath.Separator)) {
+			return fmt.Errorf("%w: %s\n  Hint: set a different path for this repo in state.yaml", ErrRescueDirConflict, p)
+		}
+	}
+	return nil
+}
+
+// rescueWork saves a worktree's uncommitted changes as a patch and its
+// local-only commits as a bundle under the workspace's archive directory,
+// so the worktree can be removed without losing work. Returns nil when
+// there is nothing to save.
+func (s *Service) rescueWork(ctx context.Context, wsDir string, rc *repoRenderContext) (*state.Rescued, error) {
+	branch, err := s.Git.CurrentBranch(ctx, rc.worktreePath)
+	if err != nil {
+		return nil, fmt.Errorf("getting branch: %w", err)
+	}
+	rescued := &state.Rescued{Path: rc.repoPath, Branch: branch}
+	base := filepath.Join(rescueDir, rc.repoPath)
+
+	clean, err := s.Git.IsClean(ctx, rc.worktreePath)
+	if err != nil {
+		return nil, fmt.Errorf("checking worktree status: %w", err)
+	}
+	if !clean {
+		patch, err := s.Git.DiffWorktree(ctx, rc.worktreePath)
+		if err != nil {
+			return nil, fmt.Errorf("capturing uncommitted changes: %w", err)
+		}
+		if len(patch) > 0 {
+			rescued.Patch = base + ".patch"
+			if err := writeRescueFile(filepath.Join(wsDir, rescued.Patch), patch); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var pin string
+	if rc.repo.Pinned() {
+		if pin, err = s.pinnedCommit(ctx, rc); err != nil {
+			return nil, err
+		}
+	}
+	unpushed, err := s.localWork(ctx, rc.worktreePath, rc.barePath, branch, pin, true)
+	if err != nil {
+		return nil, fmt.Errorf("checking unpushed commits: %w", err)
+	}
+	if unpushed > 0 {
+		rescued.Bundle = base + ".bundle"
+		rescued.Commits = unpushed
+		path := filepath.Join(wsDir, rescued.Bundle)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := s.Git.CreateBundle(ctx, rc.worktreePath, path, "HEAD"); err != nil {
+			return nil, fmt.Errorf("bundling unpushed commits: %w", err)
+		}
+	}
+
+	if rescued.Patch == "" && rescued.Bundle == "" {
+		return nil, nil
+	}
+	s.log().Debug("rescued work", "path", rc.repoPath, "patch", rescued.Patch, "bundle", rescued.Bundle)
+	return rescued, nil
+}
+
+// writeRescueFile writes data, creating parent directories as needed.
+func writeRescueFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// addRescued records r, replacing any earlier entry for the same path.
+func addRescued(list []state.Rescued, r state.Rescued) []state.Rescued {
+	for i := range list {
+		if list[i].Path == r.Path {
+			list[i] = r
+			return list
+		}
+	}
+	return append(list, r)
+}
+
+// restoreBundles fetches rescued commits back into the bare cache before
+// worktrees are re-created, and returns the restored branch tip for each
+// rescued path. A branch that moved on since it was bundled is left alone
+// and reported; its bundle is kept.
+func (s *Service) restoreBundles(ctx context.Context, wsDir string, st *state.State, warn func(msg string)) map[string]string {
+	restored := make(map[string]string)
+	for _, r := range st.Metadata.Rescued {
+		if r.Bundle == "" {
+			continue
+		}
+		rc := s.rescuedContext(wsDir, st, r)
+		if rc == nil || r.Branch == "HEAD" {
+			warn(fmt.Sprintf("%s: %d commit(s) kept in %s", r.Path, r.Commits, r.Bundle))
+			continue
+		}
+		if err := s.ensureBareRepo(ctx, rc); err != nil {
+			warn(fmt.Sprintf("%s: %v; %d commit(s) kept in %s", r.Path, err, r.Commits, r.Bundle))
+			continue
+		}
+		ref := "refs/heads/" + r.Branch
+		var tip string
+		err := s.withRepoLock(ctx, rc.barePath, func() error {
+			if err := s.Git.FetchBundle(ctx, rc.barePath, filepath.Join(wsDir, r.Bundle), "HEAD:"+ref); err != nil {
+				return err
+			}
+			var err error
+			tip, err = s.Git.ResolveRef(ctx, rc.barePath, ref)
+			return err
+		})
+		if err != nil {
+			s.log().Debug("restoring bundle failed", "path", r.Path, "err", err)
+			warn(fmt.Sprintf("%s: %s has diverged; %d commit(s) kept in %s", r.Path, r.Branch, r.Commits, r.Bundle))
+			continue
+		}
+		restored[r.Path] = tip
+	}
+	return restored
+}
+
+// reapplyRescued applies rescued patches to re-created worktrees and
+// returns the entries that could not be fully restored. A bundle only counts
+// as restored once its tip is reachable from the worktree's HEAD. Files for
+// restored entries are removed.
+func (s *Service) reapplyRescued(ctx context.Context, wsDir string, st *state.State, bundles map[string]string, progress, warn func(msg string)) []state.Rescued {
+	var remaining []state.Rescued
+	for _, r := range st.Metadata.Rescued {
+		wt := filepath.Join(wsDir, r.Path)
+		_, statErr := os.Stat(wt)
+		done := true
+		if tip := bundles[r.Path]; r.Bundle != "" {
+			switch {
+			case tip == "":
+				// Not restored; restoreBundles said why.
+				done = false
+			case statErr != nil:
+				warn(fmt.Sprintf("%s: not restored; %d commit(s) kept in %s", r.Path, r.Commits, r.Bundle))
+				done = false
+			case !s.reachable(ctx, wt, tip):
+				warn(fmt.Sprintf("%s: %s does not contain the restored commits; %d commit(s) kept in %s", r.Path, r.Branch, r.Commits, r.Bundle))
+				done = false
+			}
+		}
+
+		if r.Patch != "" {
+			if statErr != nil {
+				warn(fmt.Sprintf("%s: not restored; uncommitted changes kept in %s", r.Path, r.Patch))
+				done = false
+			} else if err := s.Git.ApplyPatch(ctx, wt, filepath.Join(wsDir, r.Patch)); err != nil {
+				s.log().Debug("applying rescued patch failed", "path", r.Path, "err", err)
+				warn(fmt.Sprintf("%s: patch did not apply cleanly; uncommitted changes kept in %s", r.Path, r.Patch))
+				done = false
+			} else {
+				progress(fmt.Sprintf("      └── %s uncommitted changes reapplied ✓", r.Path))
+				_ = os.Remove(filepath.Join(wsDir, r.Patch))
+				r.Patch = ""
+			}
+		}
+
+		if !done {
+			remaining = append(remaining, r)
+			continue
+		}
+		if r.Bundle != "" {
+			progress(fmt.Sprintf("      └── %s %d unpushed commit(s) restored ✓", r.Path, r.Commits))
+			_ = os.Remove(filepath.Join(wsDir, r.Bundle))
+		}
+	}
+	return remaining
+}
+
+// reachable reports whether commit is reachable from HEAD in the worktree
+// at wt.
+func (s *Service) reachable(ctx context.Context, wt, commit string) bool {
+	missing, err := s.Git.CommitsAhead(ctx, wt, "HEAD", commit)
+	if err != nil {
+		s.log().Debug("checking restored commits failed", "path", wt, "err", err)
+		return false
+	}
+	return missing == 0
+}
+
+// rescuedContext returns the render context of the declared repo a rescue
+// entry belongs to, or nil if the repo is no longer in state.
+func (s *Service) rescuedContext(wsDir string, st *state.State, r state.Rescued) *repoRenderContext {
+	for _, rc := range s.renderContexts(wsDir, st) {
+		if rc.repoPath == r.Path {
+			return &rc
+		}
+	}
+	return nil
+}
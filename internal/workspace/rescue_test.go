@@ -0,0 +1,125 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/milldr/flow/internal/git"
+	"github.com/milldr/flow/internal/state"
+)
+
+// realService is testService backed by real git, with one workspace
+// rendered from a local origin that has main and feat/x. It returns the
+// service and the worktree path.
+func realService(t *testing.T, id string) (*Service, string) {
+	t.Helper()
+	svc, _ := testService(t)
+	svc.Git = &git.RealRunner{}
+
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "init", "--quiet", "-b", "main")
+	commitFile(t, src, "README.md")
+	gitCmd(t, src, "branch", "feat/x")
+
+	st := state.NewState(id, "", []state.Repo{{URL: src, Branch: "feat/x", Path: "repo"}})
+	if err := svc.Create(id, st); err != nil {
+		t.Fatal(err)
+	}
+	opts := &RenderOptions{OnBranchConflict: BranchConflictUseExisting}
+	if err := svc.Render(context.Background(), id, noop, opts); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	return svc, filepath.Join(svc.Config.WorkspacePath(id), "repo")
+}
+
+// commitFile commits a new file named name in dir.
+func commitFile(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, dir, "add", name)
+	gitCmd(t, dir, "commit", "--quiet", "-m", "add "+name)
+}
+
+func TestArchiveRestoresUnpushedCommits(t *testing.T) {
+	svc, wt := realService(t, "real-ws")
+	ctx := context.Background()
+
+	commitFile(t, wt, "local.txt")
+	head := gitCmd(t, wt, "rev-parse", "HEAD")
+	if err := os.WriteFile(filepath.Join(wt, "wip.txt"), []byte("wip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Archive(ctx, "real-ws"); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	var warnings []string
+	if err := svc.Unarchive(ctx, "real-ws", noop, func(msg string) { warnings = append(warnings, msg) }); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+
+	if got := gitCmd(t, wt, "rev-parse", "HEAD"); got != head {
+		t.Errorf("HEAD after unarchive = %s, want the local commit %s", got, head)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "wip.txt")); err != nil {
+		t.Errorf("uncommitted file not reapplied: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v, want none", warnings)
+	}
+	st, err := svc.Find("real-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Metadata.Rescued) != 0 {
+		t.Errorf("Rescued = %+v, want none left", st.Metadata.Rescued)
+	}
+}
+
+func TestArchiveRestoresWorkBesidePinnedRepo(t *testing.T) {
+	svc, wt := realService(t, "pinned-ws")
+	ctx := context.Background()
+
+	// A pinned repo makes render record its commit in state while the
+	// rescued work is still to be reapplied.
+	st, err := svc.Find("pinned-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := st.Spec.Repos[0].URL
+	gitCmd(t, src, "tag", "v1")
+	st.Spec.Repos = append(st.Spec.Repos, state.Repo{URL: src, Ref: "v1", Path: "pinned"})
+	if err := state.Save(svc.Config.StatePath("pinned-ws"), st); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, wt, "local.txt")
+	head := gitCmd(t, wt, "rev-parse", "HEAD")
+
+	if err := svc.Archive(ctx, "pinned-ws"); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if err := svc.Unarchive(ctx, "pinned-ws", noop, func(msg string) { t.Errorf("warning: %s", msg) }); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+
+	if got := gitCmd(t, wt, "rev-parse", "HEAD"); got != head {
+		t.Errorf("HEAD after unarchive = %s, want the local commit %s", got, head)
+	}
+	st, err = svc.Find("pinned-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Metadata.Rescued) != 0 {
+		t.Errorf("Rescued = %+v, want none left", st.Metadata.Rescued)
+	}
+	if want := gitCmd(t, src, "rev-parse", "v1^{commit}"); st.Spec.Repos[1].Commit != want {
+		t.Errorf("pinned commit = %q, want %q", st.Spec.Repos[1].Commit, want)
+	}
+}
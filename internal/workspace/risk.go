@@ -0,0 +1,141 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrDeleteRefused is returned when deleting a workspace would lose local
+// work that the caller has not agreed to discard.
+var ErrDeleteRefused = errors.New("refusing to delete workspace with local work")
+
+// RepoRisk describes local work in a worktree that deleting it would lose.
+type RepoRisk struct {
+	Path     string // relative to the workspace directory
+	Branch   string // checked-out branch, or "HEAD" when detached
+	Dirty    bool
+	Unpushed int  // commits not reachable from any remote ref
+	Stashes  int  // stash entries made on Branch
+	Stray    bool // worktree not declared in state
+}
+
+// Risky reports whether the worktree holds work that isn't on a remote.
+func (r RepoRisk) Risky() bool {
+	return r.Dirty || r.Unpushed > 0 || r.Stashes > 0
+}
+
+// Reason describes the work at risk, e.g. "uncommitted changes, 2 stash(es)".
+func (r RepoRisk) Reason() string {
+	var reasons []string
+	if r.Dirty {
+		reasons = append(reasons, "uncommitted changes")
+	}
+	if r.Unpushed > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d unpushed commit(s)", r.Unpushed))
+	}
+	if r.Stashes > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d stash(es)", r.Stashes))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// DeleteRisks inspects every worktree in a workspace — declared repos and
+// undeclared stray worktrees — for uncommitted changes, unpushed commits
+// and stashes. Repos without a worktree are skipped.
+func (s *Service) DeleteRisks(ctx context.Context, id string) ([]RepoRisk, error) {
+	st, err := s.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	wsDir := s.Config.WorkspacePath(id)
+	var risks []RepoRisk
+	for _, rc := range s.renderContexts(wsDir, st) {
+		if _, err := os.Stat(rc.worktreePath); err != nil {
+			continue
+		}
+		var pin string
+		if rc.repo.Pinned() {
+			if pin, err = s.pinnedCommit(ctx, &rc); err != nil {
+				return nil, err
+			}
+		}
+		risk, err := s.inspectRisk(ctx, rc.worktreePath, rc.barePath, pin)
+		if err != nil {
+			return nil, fmt.Errorf("inspecting %s: %w", rc.repoPath, err)
+		}
+		risk.Path = rc.repoPath
+		risks = append(risks, risk)
+	}
+
+	strays, err := findStrayPaths(wsDir, st)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("scanning for extra worktrees: %w", err)
+	}
+	for _, rel := range strays {
+		path := filepath.Join(wsDir, rel)
+		barePath, err := s.Git.CommonDir(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("locating repository for %s: %w", rel, err)
+		}
+		risk, err := s.inspectRisk(ctx, path, barePath, "")
+		if err != nil {
+			return nil, fmt.Errorf("inspecting %s: %w", rel, err)
+		}
+		risk.Path = rel
+		risk.Stray = true
+		risks = append(risks, risk)
+	}
+	return risks, nil
+}
+
+// inspectRisk gathers the local-work state of a single worktree. pin is the
+// commit a pinned repo is checked out at, or empty.
+func (s *Service) inspectRisk(ctx context.Context, path, barePath, pin string) (RepoRisk, error) {
+	var risk RepoRisk
+
+	branch, err := s.Git.CurrentBranch(ctx, path)
+	if err != nil {
+		return risk, fmt.Errorf("getting branch: %w", err)
+	}
+	risk.Branch = branch
+
+	clean, err := s.Git.IsClean(ctx, path)
+	if err != nil {
+		return risk, fmt.Errorf("checking worktree status: %w", err)
+	}
+	risk.Dirty = !clean
+
+	unpushed, err := s.localWork(ctx, path, barePath, branch, pin, true)
+	if err != nil {
+		return risk, fmt.Errorf("checking unpushed commits: %w", err)
+	}
+	risk.Unpushed = unpushed
+
+	stashes, err := s.Git.StashCount(ctx, path, branch)
+	if err != nil {
+		return risk, fmt.Errorf("checking stashes: %w", err)
+	}
+	risk.Stashes = stashes
+
+	return risk, nil
+}
+
+// localWork counts commits on HEAD in the worktree at path that no remote
+// ref contains. With refresh, origin/<branch> is updated first so commits
+// that were pushed aren't counted; best effort, as the branch may never
+// have been pushed. For a worktree pinned at commit pin, only commits made
+// on top of it count: a tagged commit needn't be on any branch origin has.
+func (s *Service) localWork(ctx context.Context, path, barePath, branch, pin string, refresh bool) (int, error) {
+	if refresh && branch != "HEAD" {
+		s.refreshRemoteRef(ctx, barePath, branch)
+	}
+	if pin == "" {
+		return s.Git.UnpushedCommits(ctx, path, "HEAD")
+	}
+	return s.Git.CommitsAhead(ctx, path, pin, "HEAD")
+}
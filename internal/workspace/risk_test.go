@@ -0,0 +1,58 @@
+package workspace
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestDeleteRisks(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.isClean = true
+
+	st := state.NewState("risk", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "main"},
+		{URL: "github.com/org/web", Branch: "main"},
+	})
+	if err := svc.Create("risk-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "risk-ws", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	risks, err := svc.DeleteRisks(ctx, "risk-ws")
+	if err != nil {
+		t.Fatalf("DeleteRisks: %v", err)
+	}
+	if len(risks) != 2 {
+		t.Fatalf("risks = %d, want 2", len(risks))
+	}
+	for _, r := range risks {
+		if r.Risky() {
+			t.Errorf("%s risky after clean render: %s", r.Path, r.Reason())
+		}
+	}
+
+	mock.isClean = false
+	mock.unpushed = 2
+	mock.stashes = 1
+	makeStrayWorktree(t, filepath.Join(svc.Config.WorkspacePath("risk-ws"), "old"))
+
+	risks, err = svc.DeleteRisks(ctx, "risk-ws")
+	if err != nil {
+		t.Fatalf("DeleteRisks: %v", err)
+	}
+	if len(risks) != 3 {
+		t.Fatalf("risks = %d, want 3", len(risks))
+	}
+	if got, want := risks[0].Reason(), "uncommitted changes, 2 unpushed commit(s), 1 stash(es)"; got != want {
+		t.Errorf("Reason() = %q, want %q", got, want)
+	}
+	if stray := risks[2]; !stray.Stray || stray.Path != "old" || !stray.Risky() {
+		t.Errorf("stray risk = %+v, want risky stray at old", stray)
+	}
+}
@@ -0,0 +1,195 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// ErrTrashNotFound is returned when no trash entry matches.
+var ErrTrashNotFound = errors.New("no deleted workspace found")
+
+// trashTimeFormat is the timestamp suffix of trash entry names.
+const trashTimeFormat = "20060102-150405"
+
+// TrashEntry is a deleted workspace held in the trash.
+type TrashEntry struct {
+	Name    string // directory name in the trash, <id>-<timestamp>[.<n>]
+	ID      string // workspace ID it was deleted from
+	Deleted time.Time
+	State   *state.State // nil if the state file can't be read
+
+	seq int // n of an entry deleted in the same second as an earlier one
+}
+
+// trashName returns the trash entry name for a workspace deleted at now.
+// Entries for the same workspace deleted within one second are told apart
+// by a sequence number from 2 up.
+func trashName(id string, now time.Time, seq int) string {
+	name := id + "-" + now.UTC().Format(trashTimeFormat)
+	if seq > 1 {
+		name += "." + strconv.Itoa(seq)
+	}
+	return name
+}
+
+// parseTrashName splits a trash entry name into workspace ID, deletion
+// time and sequence number.
+func parseTrashName(name string) (string, time.Time, int, bool) {
+	seq := 1
+	if i := strings.LastIndex(name, "."); i > strings.LastIndex(name, "-") {
+		n, err := strconv.Atoi(name[i+1:])
+		if err != nil || n < 2 {
+			return "", time.Time{}, 0, false
+		}
+		name, seq = name[:i], n
+	}
+	n := len(trashTimeFormat)
+	if len(name) < n+2 || name[len(name)-n-1] != '-' {
+		return "", time.Time{}, 0, false
+	}
+	deleted, err := time.Parse(trashTimeFormat, name[len(name)-n:])
+	if err != nil {
+		return "", time.Time{}, 0, false
+	}
+	return name[:len(name)-n-1], deleted, seq, true
+}
+
+// moveToTrash moves a workspace directory into the trash and returns the
+// entry name.
+func (s *Service) moveToTrash(id string) (string, error) {
+	if err := os.MkdirAll(s.Config.TrashDir, 0o755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	name := trashName(id, now, 1)
+	for seq := 2; ; seq++ {
+		if _, err := os.Lstat(s.Config.TrashPath(name)); os.IsNotExist(err) {
+			break
+		}
+		name = trashName(id, now, seq)
+	}
+	s.log().Debug("moving workspace to trash", "id", id, "entry", name)
+	if err := os.Rename(s.Config.WorkspacePath(id), s.Config.TrashPath(name)); err != nil {
+		return "", fmt.Errorf("moving workspace to trash: %w", err)
+	}
+	return name, nil
+}
+
+// ListTrash returns deleted workspaces, most recently deleted first.
+func (s *Service) ListTrash() ([]TrashEntry, error) {
+	entries, err := os.ReadDir(s.Config.TrashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var trash []TrashEntry
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		id, deleted, seq, ok := parseTrashName(e.Name())
+		if !ok {
+			s.log().Debug("skipping trash entry", "name", e.Name())
+			continue
+		}
+		entry := TrashEntry{Name: e.Name(), ID: id, Deleted: deleted, seq: seq}
+		if st, err := state.Load(filepath.Join(s.Config.TrashPath(e.Name()), "state.yaml")); err == nil {
+			entry.State = st
+		}
+		trash = append(trash, entry)
+	}
+
+	sort.SliceStable(trash, func(i, j int) bool {
+		if !trash[i].Deleted.Equal(trash[j].Deleted) {
+			return trash[i].Deleted.After(trash[j].Deleted)
+		}
+		return trash[i].seq > trash[j].seq
+	})
+	return trash, nil
+}
+
+// FindTrash returns the trash entry with the given entry name, or the most
+// recently deleted workspace with the given ID.
+func (s *Service) FindTrash(idOrEntry string) (TrashEntry, error) {
+	trash, err := s.ListTrash()
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	for _, e := range trash {
+		if e.Name == idOrEntry {
+			return e, nil
+		}
+	}
+	for _, e := range trash {
+		if e.ID == idOrEntry {
+			return e, nil
+		}
+	}
+	return TrashEntry{}, fmt.Errorf("%w: %s", ErrTrashNotFound, idOrEntry)
+}
+
+// RestoreTrash moves a deleted workspace back and re-renders it from its
+// existing branches, reapplying work rescued when it was deleted. Archived
+// workspaces are moved back without rendering. Repos that can't be restored
+// are returned together as RepoErrors.
+func (s *Service) RestoreTrash(ctx context.Context, entry TrashEntry, progress, warn func(msg string)) error {
+	l, err := s.lockWorkspace(ctx, entry.ID)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	wsDir := s.Config.WorkspacePath(entry.ID)
+	if _, err := os.Stat(wsDir); err == nil {
+		return fmt.Errorf("%w: %s\n  Hint: delete or rename the existing workspace first", ErrWorkspaceExists, entry.ID)
+	}
+
+	s.log().Debug("restoring workspace from trash", "entry", entry.Name, "id", entry.ID)
+	if err := os.Rename(s.Config.TrashPath(entry.Name), wsDir); err != nil {
+		return fmt.Errorf("restoring from trash: %w", err)
+	}
+
+	st, err := s.Find(entry.ID)
+	if err != nil {
+		return err
+	}
+	if st.Metadata.Archived {
+		return nil
+	}
+	return s.rebuild(ctx, entry.ID, st, progress, warn)
+}
+
+// EmptyTrash permanently removes trash entries deleted more than olderThan
+// ago; zero removes every entry. The removed entries are returned.
+func (s *Service) EmptyTrash(olderThan time.Duration) ([]TrashEntry, error) {
+	trash, err := s.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var removed []TrashEntry
+	for _, e := range trash {
+		if olderThan > 0 && e.Deleted.After(cutoff) {
+			continue
+		}
+		s.log().Debug("removing trash entry", "name", e.Name)
+		if err := os.RemoveAll(s.Config.TrashPath(e.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, e)
+	}
+	return removed, nil
+}
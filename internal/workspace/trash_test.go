@@ -0,0 +1,191 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestParseTrashName(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	name := trashName("calm-delta", now, 1)
+	if name != "calm-delta-20260102-030405" {
+		t.Fatalf("trashName = %q", name)
+	}
+
+	id, deleted, seq, ok := parseTrashName(name)
+	if !ok || id != "calm-delta" || !deleted.Equal(now) || seq != 1 {
+		t.Errorf("parseTrashName(%q) = %q, %v, %d, %v", name, id, deleted, seq, ok)
+	}
+
+	name = trashName("v1.2", now, 3)
+	if name != "v1.2-20260102-030405.3" {
+		t.Fatalf("trashName = %q", name)
+	}
+	if id, _, seq, ok := parseTrashName(name); !ok || id != "v1.2" || seq != 3 {
+		t.Errorf("parseTrashName(%q) = %q, %d, %v", name, id, seq, ok)
+	}
+
+	for _, bad := range []string{"calm-delta", "20260102-030405", "x-2026-01-02", "x-20260102-030405.1", "x-20260102-030405.a"} {
+		if _, _, _, ok := parseTrashName(bad); ok {
+			t.Errorf("parseTrashName(%q) ok, want not ok", bad)
+		}
+	}
+}
+
+func TestDeleteTwiceWithinASecond(t *testing.T) {
+	svc, _ := testService(t)
+	ctx := context.Background()
+
+	var names []string
+	for range 2 {
+		st := state.NewState("again", "", []state.Repo{{URL: "github.com/org/api", Branch: "feat/x"}})
+		if err := svc.Create("again", st); err != nil {
+			t.Fatal(err)
+		}
+		name, err := svc.Delete(ctx, "again")
+		if err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		names = append(names, name)
+	}
+	if names[0] == names[1] {
+		t.Fatalf("both deletes went to %s", names[0])
+	}
+
+	// The later delete is the one restored by ID.
+	entry, err := svc.FindTrash("again")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Name != names[1] {
+		t.Errorf("FindTrash = %s, want the later entry %s", entry.Name, names[1])
+	}
+}
+
+func TestTrashRestore(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+
+	st := state.NewState("trash", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "feat/x"},
+	})
+	if err := svc.Create("trash-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "trash-ws", noop, &RenderOptions{OnBranchConflict: BranchConflictUseExisting}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Uncommitted work is saved into the trash entry.
+	mock.diff = []byte("diff --git a/x b/x\n")
+	mock.currentBranch = "feat/x"
+	name, err := svc.Delete(ctx, "trash-ws")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.Config.TrashPath(name), "archive", "api.patch")); err != nil {
+		t.Errorf("patch not in trash: %v", err)
+	}
+
+	trash, err := svc.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].ID != "trash-ws" || trash[0].State == nil {
+		t.Fatalf("ListTrash = %+v, want one trash-ws entry", trash)
+	}
+
+	entry, err := svc.FindTrash("trash-ws")
+	if err != nil {
+		t.Fatalf("FindTrash: %v", err)
+	}
+	mock.worktrees = nil
+	mock.isClean = true
+	if err := svc.RestoreTrash(ctx, entry, noop, nil); err != nil {
+		t.Fatalf("RestoreTrash: %v", err)
+	}
+	if len(mock.worktrees) != 1 || len(mock.patches) != 1 {
+		t.Errorf("worktrees = %v, patches = %v, want one of each", mock.worktrees, mock.patches)
+	}
+	if _, err := svc.Find("trash-ws"); err != nil {
+		t.Errorf("workspace not restored: %v", err)
+	}
+	if trash, _ := svc.ListTrash(); len(trash) != 0 {
+		t.Errorf("trash = %+v, want empty", trash)
+	}
+
+	if _, err := svc.FindTrash("trash-ws"); !errors.Is(err, ErrTrashNotFound) {
+		t.Errorf("FindTrash after restore = %v, want ErrTrashNotFound", err)
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	svc, _ := testService(t)
+
+	old := trashName("old-ws", time.Now().Add(-40*24*time.Hour), 1)
+	recent := trashName("new-ws", time.Now().Add(-time.Hour), 1)
+	for _, name := range []string{old, recent} {
+		if err := os.MkdirAll(svc.Config.TrashPath(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := svc.EmptyTrash(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("EmptyTrash: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Name != old {
+		t.Errorf("removed = %+v, want [%s]", removed, old)
+	}
+
+	removed, err = svc.EmptyTrash(0)
+	if err != nil {
+		t.Fatalf("EmptyTrash: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Name != recent {
+		t.Errorf("removed = %+v, want [%s]", removed, recent)
+	}
+}
+
+func TestTrashRestoreKeepsUnpushedCommits(t *testing.T) {
+	svc, wt := realService(t, "real-ws")
+	ctx := context.Background()
+
+	commitFile(t, wt, "local.txt")
+	head := gitCmd(t, wt, "rev-parse", "HEAD")
+
+	// A worktree added by hand, not declared in state.
+	bare := gitCmd(t, wt, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	stray := filepath.Join(svc.Config.WorkspacePath("real-ws"), "scratch")
+	gitCmd(t, bare, "worktree", "add", "--quiet", "--detach", stray, "main")
+
+	if _, err := svc.Delete(ctx, "real-ws"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if list := gitCmd(t, bare, "worktree", "list", "--porcelain"); strings.Contains(list, "real-ws") {
+		t.Errorf("bare repo still lists worktrees of the deleted workspace:\n%s", list)
+	}
+
+	entry, err := svc.FindTrash("real-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	if err := svc.RestoreTrash(ctx, entry, noop, func(msg string) { warnings = append(warnings, msg) }); err != nil {
+		t.Fatalf("RestoreTrash: %v", err)
+	}
+	if got := gitCmd(t, wt, "rev-parse", "HEAD"); got != head {
+		t.Errorf("HEAD after restore = %s, want the local commit %s", got, head)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v, want none", warnings)
+	}
+}
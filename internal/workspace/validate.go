@@ -0,0 +1,51 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+// Validate checks a workspace's state file and returns every problem in it
+// with its line and column, or nil if the file is valid. Unlike Find, it
+// works on files that fail to parse. Beyond state.Check, it flags branches
+// that are their repo's default branch in the bare cache.
+func (s *Service) Validate(ctx context.Context, id string) ([]*state.Problem, error) {
+	data, err := os.ReadFile(s.Config.StatePath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrWorkspaceNotFound, id)
+		}
+		return nil, err
+	}
+	return state.Check(data, s.defaultBranchRule(ctx)), nil
+}
+
+// defaultBranchRule reports repos without a base whose branch is the repo's
+// default branch: render would branch it from, and reset it to, itself.
+// Repos that aren't cloned yet can't be checked and are skipped.
+func (s *Service) defaultBranchRule(ctx context.Context) state.Rule {
+	return func(st *state.State) []*state.Problem {
+		var problems []*state.Problem
+		for i, r := range st.Spec.Repos {
+			if r.URL == "" || r.Branch == "" || r.Base != "" {
+				continue
+			}
+			barePath := s.Config.BareRepoPath(r.URL)
+			if _, err := os.Stat(barePath); err != nil {
+				continue
+			}
+			def, err := s.Git.DefaultBranch(ctx, barePath)
+			if err != nil || def != r.Branch {
+				continue
+			}
+			problems = append(problems, &state.Problem{
+				Field: fmt.Sprintf("spec.repos[%d].branch", i),
+				Err:   fmt.Errorf("%w: %s is the repo's default branch; render would reset it to origin/%s", state.ErrBranchIsBase, r.Branch, r.Branch),
+			})
+		}
+		return problems
+	}
+}
@@ -0,0 +1,71 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/milldr/flow/internal/state"
+)
+
+func TestValidateDefaultBranch(t *testing.T) {
+	svc, _ := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("ws", "", []state.Repo{
+		{URL: "github.com/org/cloned", Branch: "main"},
+		{URL: "github.com/org/cloned", Branch: "main-fix", Path: "fix"},
+		{URL: "github.com/org/uncloned", Branch: "main"},
+	})
+	if err := svc.Create("ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(svc.Config.BareRepoPath("github.com/org/cloned"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := svc.Validate(ctx, "ws")
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	// The mock's default branch is main; the uncloned repo can't be checked.
+	if len(problems) != 1 {
+		t.Fatalf("problems = %v, want 1", problems)
+	}
+	p := problems[0]
+	if !errors.Is(p, state.ErrBranchIsBase) || p.Field != "spec.repos[0].branch" || p.Line == 0 {
+		t.Errorf("problem = %v (field %q, line %d), want ErrBranchIsBase on spec.repos[0].branch", p, p.Field, p.Line)
+	}
+
+	if _, err := svc.Validate(ctx, "missing"); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Errorf("Validate(missing) error = %v, want ErrWorkspaceNotFound", err)
+	}
+}
+
+func TestRenderRefusesDefaultBranch(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("ws", "", []state.Repo{{URL: "github.com/org/cloned", Branch: "main"}})
+	if err := svc.Create("ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(svc.Config.BareRepoPath("github.com/org/cloned"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Plan(ctx, "ws", nil); !errors.Is(err, state.ErrBranchIsBase) {
+		t.Errorf("Plan error = %v, want ErrBranchIsBase", err)
+	}
+	if err := svc.Render(ctx, "ws", noop, nil); !errors.Is(err, state.ErrBranchIsBase) {
+		t.Errorf("Render error = %v, want ErrBranchIsBase", err)
+	}
+	if err := svc.Sync(ctx, "ws", noop); !errors.Is(err, state.ErrBranchIsBase) {
+		t.Errorf("Sync error = %v, want ErrBranchIsBase", err)
+	}
+	if len(mock.resets)+len(mock.startPoints)+len(mock.worktrees)+len(mock.rebases) != 0 {
+		t.Errorf("repo touched: resets=%v startPoints=%v worktrees=%v rebases=%v",
+			mock.resets, mock.startPoints, mock.worktrees, mock.rebases)
+	}
+}
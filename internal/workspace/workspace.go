@@ -45,6 +45,9 @@ type Info struct {
 	RepoNames   []string // short repo names (derived from URLs)
 	Archived    bool
 	Created     time.Time
+	// Err is set when the workspace's state file exists but can't be
+	// loaded; only ID is filled in then.
+	Err error
 }
 
 // Service orchestrates workspace operations.
@@ -52,6 +55,14 @@ type Service struct {
 	Config *config.Config
 	Git    git.Runner
 	Log    *slog.Logger
+	// Offline skips clones and fetches; render and sync work from the refs
+	// already in the bare cache.
+	Offline bool
+	// LockTimeout is how long to wait for another flow process working on
+	// the same workspace or bare repo; zero means DefaultLockTimeout.
+	LockTimeout time.Duration
+
+	fetches fetchGroup
 }
 
 func (s *Service) log() *slog.Logger {
@@ -75,7 +86,8 @@ func (s *Service) Create(id string, st *state.State) error {
 		return err
 	}
 
-	if err := state.Save(s.Config.StatePath(id), st); err != nil {
+	path := s.Config.StatePath(id)
+	if err := state.Create(path, st, s.Config.SchemaRef(path, "state")); err != nil {
 		return err
 	}
 
@@ -87,7 +99,8 @@ func (s *Service) Create(id string, st *state.State) error {
 	return nil
 }
 
-// List returns info for all workspaces.
+// List returns info for all workspaces. Workspaces whose state file can't
+// be loaded are included with Info.Err set.
 func (s *Service) List() ([]Info, error) {
 	s.log().Debug("listing workspaces", "dir", s.Config.WorkspacesDir)
 
@@ -106,10 +119,15 @@ func (s *Service) List() ([]Info, error) {
 		}
 		stPath := s.Config.StatePath(entry.Name())
 		st, err := state.Load(stPath)
-		if err != nil {
+		if os.IsNotExist(err) {
 			s.log().Debug("skipping directory", "name", entry.Name(), "error", err)
 			continue
 		}
+		if err != nil {
+			s.log().Debug("invalid state file", "name", entry.Name(), "error", err)
+			infos = append(infos, Info{ID: entry.Name(), Err: err})
+			continue
+		}
 
 		created, _ := time.Parse(time.RFC3339, st.Metadata.Created)
 		repoNames := make([]string, len(st.Spec.Repos))
@@ -151,7 +169,11 @@ func (s *Service) Find(id string) (*state.State, error) {
 // Returns 0 matches as ErrWorkspaceNotFound, N>1 matches as *AmbiguousNameError.
 func (s *Service) Resolve(idOrName string) ([]Info, error) {
 	// Try direct ID lookup first (O(1) filesystem check)
-	if _, err := s.Find(idOrName); err == nil {
+	_, err := s.Find(idOrName)
+	if err != nil && !errors.Is(err, ErrWorkspaceNotFound) {
+		return nil, fmt.Errorf("workspace %s: %w\n  Hint: run `flow validate %s` for details", idOrName, err, idOrName)
+	}
+	if err == nil {
 		// Load full info for the matched workspace
 		stPath := s.Config.StatePath(idOrName)
 		st, _ := state.Load(stPath)
@@ -206,12 +228,63 @@ const (
 // ErrBranchNotFound is returned when --reset=false is used but the branch doesn't exist.
 var ErrBranchNotFound = errors.New("branch not found in remote")
 
+// ErrUnpushedCommits is returned when a branch reset would discard commits
+// that are not on any remote and ForceReset is not set.
+var ErrUnpushedCommits = errors.New("branch has unpushed commits")
+
+// BackupRefPrefix is where forced resets save the previous branch tip, as
+// <prefix><timestamp>/<branch> in the bare repo.
+const BackupRefPrefix = "refs/flow/backup/"
+
 // RenderOptions configures render behavior.
 type RenderOptions struct {
 	// OnBranchConflict controls what to do when a branch already exists.
 	OnBranchConflict BranchConflict
+	// Prune removes worktrees for repos no longer declared in state.yaml.
+	// Worktrees with uncommitted changes or unpushed commits are kept.
+	Prune bool
+	// ForceReset allows resetting a branch that has unpushed commits. The old
+	// tip is saved under BackupRefPrefix first.
+	ForceReset bool
+	// Rebase rebases rendered worktrees whose base changed in state onto the
+	// new base. Without it the change is only reported.
+	Rebase bool
+	// Warn, if set, receives warnings that should outlive progress output,
+	// such as a worktree left on a branch that no longer matches state.
+	Warn func(msg string)
+	// ContinueOnError renders the remaining repos when one fails. Failures
+	// are returned together as RepoErrors.
+	ContinueOnError bool
+	// UpdatePins moves clean pinned worktrees to where their refs resolve
+	// now and records the new commits. Without it, a worktree stays at the
+	// commit recorded in state when its tag moves.
+	UpdatePins bool
+
+	// planOnly is set by Plan, which must not fetch even to refresh the
+	// remote-tracking refs unpushed commits are counted against.
+	planOnly bool
+}
+
+// warn reports a repo warning through Warn, if set.
+func (o *RenderOptions) warn(rc *repoRenderContext, msg string) {
+	if o.Warn != nil {
+		o.Warn(rc.repoPath + ": " + msg)
+	}
 }
 
+// RepoError is a render failure for a single repo.
+type RepoError struct {
+	Path   string
+	Branch string
+	Err    error
+}
+
+func (e *RepoError) Error() string {
+	return fmt.Sprintf("%s (%s): %v", e.Path, e.Branch, e.Err)
+}
+
+func (e *RepoError) Unwrap() error { return e.Err }
+
 // repoRenderContext holds pre-computed paths for rendering a single repo.
 type repoRenderContext struct {
 	index        int
@@ -219,13 +292,28 @@ type repoRenderContext struct {
 	repoPath     string
 	barePath     string
 	worktreePath string
+	// commit is the commit a pinned repo was rendered at, recorded in state
+	// once every repo is rendered.
+	commit string
 }
 
 // Render materializes a workspace: ensures bare clones and creates worktrees.
 // Bare repos are fetched in parallel to ensure we always have the latest remote
-// state before creating or updating worktrees.
+// state before creating or updating worktrees. Each repo's action is decided by
+// planRepo after fetching — the same logic Plan uses for dry runs.
 // progress is called with status messages for each repo.
 func (s *Service) Render(ctx context.Context, id string, progress func(msg string), opts *RenderOptions) error {
+	l, err := s.lockWorkspace(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	return s.render(ctx, id, progress, opts)
+}
+
+// render is Render for callers already holding the workspace lock.
+func (s *Service) render(ctx context.Context, id string, progress func(msg string), opts *RenderOptions) error {
 	if opts == nil {
 		opts = &RenderOptions{}
 	}
@@ -235,7 +323,7 @@ func (s *Service) Render(ctx context.Context, id string, progress func(msg strin
 		return err
 	}
 
-	if err := state.Validate(st); err != nil {
+	if err := state.Validate(st, s.defaultBranchRule(ctx)); err != nil {
 		return fmt.Errorf("invalid state: %w", err)
 	}
 
@@ -243,29 +331,25 @@ func (s *Service) Render(ctx context.Context, id string, progress func(msg strin
 	total := len(st.Spec.Repos)
 
 	// Build render contexts for all repos
-	repos := make([]repoRenderContext, total)
-	for i, repo := range st.Spec.Repos {
-		repos[i] = repoRenderContext{
-			index:        i,
-			repo:         repo,
-			repoPath:     state.RepoPath(repo),
-			barePath:     s.Config.BareRepoPath(repo.URL),
-			worktreePath: filepath.Join(wsDir, state.RepoPath(repo)),
-		}
-	}
+	repos := s.renderContexts(wsDir, st)
 
-	// Partition repos into new (need clone+fetch) and existing (already rendered).
-	var newRepos []*repoRenderContext
+	// Partition out repos that need clone+fetch: unrendered ones, plus
+	// rendered ones whose branch or base changed in state.
+	var fetchRepos []*repoRenderContext
 	for i := range repos {
-		if _, err := os.Stat(repos[i].worktreePath); os.IsNotExist(err) {
-			newRepos = append(newRepos, &repos[i])
+		need, err := s.needsFetch(ctx, &repos[i], opts)
+		if err != nil {
+			return err
+		}
+		if need {
+			fetchRepos = append(fetchRepos, &repos[i])
 		}
 	}
 
-	// Phase 1: Clone and fetch bare repos only for new (unrendered) repos.
+	// Phase 1: Clone and fetch bare repos only for repos that need it.
 	fetchErrs := make([]error, total)
 	var wg sync.WaitGroup
-	for _, rc := range newRepos {
+	for _, rc := range fetchRepos {
 		wg.Add(1)
 		go func(rc *repoRenderContext) {
 			defer wg.Done()
@@ -274,20 +358,46 @@ func (s *Service) Render(ctx context.Context, id string, progress func(msg strin
 	}
 	wg.Wait()
 
-	// Check for fetch errors — fail fast on any clone/fetch failure
+	// Check for fetch errors — fail fast on any clone/fetch failure unless
+	// ContinueOnError is set, in which case the repo is reported and skipped.
+	var repoErrs []error
 	for i, err := range fetchErrs {
-		if err != nil {
+		if err == nil {
+			continue
+		}
+		if !opts.ContinueOnError {
 			return fmt.Errorf("%s: %w", repos[i].repo.URL, err)
 		}
+		repoErrs = append(repoErrs, &RepoError{Path: repos[i].repoPath, Branch: repos[i].repo.Target(), Err: err})
 	}
 
-	// Phase 2: Create worktrees for new repos, skip existing ones.
+	// Phase 2: Plan and apply each repo's worktree step.
 	for i := range repos {
 		rc := &repos[i]
 		progress(fmt.Sprintf("[%d/%d] %s", rc.index+1, total, rc.repo.URL))
 
-		if err := s.ensureWorktree(ctx, rc, opts, progress); err != nil {
-			return err
+		if fetchErrs[i] != nil {
+			progress(fmt.Sprintf("      └── %s (%s) failed: %v", rc.repoPath, rc.repo.Target(), fetchErrs[i]))
+			continue
+		}
+		if err := s.renderRepo(ctx, rc, opts, progress); err != nil {
+			if !opts.ContinueOnError {
+				return err
+			}
+			progress(fmt.Sprintf("      └── %s (%s) failed", rc.repoPath, rc.repo.Target()))
+			repoErrs = append(repoErrs, &RepoError{Path: rc.repoPath, Branch: rc.repo.Target(), Err: err})
+		}
+	}
+
+	if err := s.recordCommits(id, st, repos); err != nil {
+		return err
+	}
+
+	// Phase 3: Remove worktrees for repos dropped from state. A refusal is
+	// reported after the workspace files are regenerated.
+	if opts.Prune {
+		if err := s.pruneWorktrees(ctx, wsDir, st, progress); err != nil {
+			repoErrs = append(repoErrs, err)
 		}
 	}
 
@@ -296,100 +406,276 @@ func (s *Service) Render(ctx context.Context, id string, progress func(msg strin
 		return fmt.Errorf("setting up claude files: %w", err)
 	}
 
-	return nil
+	return errors.Join(repoErrs...)
+}
+
+// renderRepo plans and applies the worktree step for a single repo.
+func (s *Service) renderRepo(ctx context.Context, rc *repoRenderContext, opts *RenderOptions, progress func(msg string)) error {
+	l, err := s.lockRepo(ctx, rc.barePath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	plan, err := s.planRepo(ctx, rc, opts)
+	if err != nil {
+		return err
+	}
+	for _, w := range plan.Warnings {
+		opts.warn(rc, w)
+	}
+	return s.applyRepoPlan(ctx, rc, plan, opts, progress)
 }
 
 // ensureBareRepo clones (if needed) and fetches a bare repository.
 func (s *Service) ensureBareRepo(ctx context.Context, rc *repoRenderContext) error {
+	opts := s.cloneOptions(rc.repo)
+	if s.Offline {
+		if _, err := os.Stat(rc.barePath); os.IsNotExist(err) {
+			return fmt.Errorf("%w: repo has never been cloned", ErrNotCached)
+		}
+		s.log().Debug("offline, skipping fetch", "url", rc.repo.URL)
+		return nil
+	}
+
+	l, err := s.lockRepo(ctx, rc.barePath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
 	if _, err := os.Stat(rc.barePath); os.IsNotExist(err) {
 		s.log().Debug("bare clone not found, cloning", "url", rc.repo.URL, "dest", rc.barePath)
 		if err := os.MkdirAll(filepath.Dir(rc.barePath), 0o755); err != nil {
 			return err
 		}
-		if err := s.Git.BareClone(ctx, rc.repo.URL, rc.barePath); err != nil {
+		if err := s.Git.BareClone(ctx, rc.repo.URL, rc.barePath, opts); err != nil {
 			return fmt.Errorf("cloning: %w", err)
 		}
 	}
 
 	s.log().Debug("fetching bare repo", "url", rc.repo.URL, "path", rc.barePath)
-	if err := s.Git.Fetch(ctx, rc.barePath); err != nil {
+	if err := s.fetch(ctx, rc.barePath); err != nil {
 		return fmt.Errorf("fetching: %w", err)
 	}
+
+	if rc.repo.Pinned() {
+		return s.fetchPinnedRef(ctx, rc)
+	}
+
+	// A single-branch clone only has the default branch; fetch the
+	// workspace branch if origin has it, so render checks it out rather
+	// than creating it.
+	if opts.SingleBranch {
+		exists, err := s.Git.BranchExists(ctx, rc.barePath, rc.repo.Branch)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if _, err := s.Git.FetchBranch(ctx, rc.barePath, rc.repo.Branch); err != nil {
+				return fmt.Errorf("fetching %s: %w", rc.repo.Branch, err)
+			}
+		}
+	}
 	return nil
 }
 
-// ensureWorktree creates a new worktree or skips if already rendered.
-func (s *Service) ensureWorktree(ctx context.Context, rc *repoRenderContext, opts *RenderOptions, progress func(msg string)) error {
-	if _, err := os.Stat(rc.worktreePath); os.IsNotExist(err) {
-		return s.createWorktree(ctx, rc, opts, progress)
+// cloneOptions returns the clone options for a repo: the global defaults
+// from config.yaml, overridden field by field by the repo's own.
+func (s *Service) cloneOptions(r state.Repo) git.CloneOptions {
+	var c state.CloneOptions
+	if s.Config.FlowConfig != nil {
+		c = s.Config.FlowConfig.Spec.Git.Clone
 	}
-	// Already rendered — skip entirely
-	progress(fmt.Sprintf("      └── %s (%s) exists, skipped", rc.repoPath, rc.repo.Branch))
-	return nil
+	if o := r.Clone; o != nil {
+		if o.Filter != "" {
+			c.Filter = o.Filter
+		}
+		if o.Depth != 0 {
+			c.Depth = o.Depth
+		}
+		if o.SingleBranch != nil {
+			c.SingleBranch = o.SingleBranch
+		}
+	}
+
+	opts := git.CloneOptions{Filter: c.Filter, Depth: c.Depth}
+	if c.SingleBranch != nil {
+		opts.SingleBranch = *c.SingleBranch
+	}
+	return opts
 }
 
-// createWorktree creates a new worktree, either from an existing branch or
-// by creating a new branch from the base.
-func (s *Service) createWorktree(ctx context.Context, rc *repoRenderContext, opts *RenderOptions, progress func(msg string)) error {
-	exists, err := s.Git.BranchExists(ctx, rc.barePath, rc.repo.Branch)
-	if err != nil {
-		return fmt.Errorf("checking branch for %s: %w", rc.repo.URL, err)
+// applyRepoPlan carries out a planned worktree step for one repo.
+func (s *Service) applyRepoPlan(ctx context.Context, rc *repoRenderContext, plan *RepoPlan, opts *RenderOptions, progress func(msg string)) error {
+	if plan.Err != nil {
+		return plan.Err
 	}
 
-	if s.shouldResetBranch(opts) {
-		// Reset mode: create a clean branch from base, regardless of whether branch exists
-		baseBranch, err := s.resolveBaseBranch(ctx, rc)
-		if err != nil {
+	switch plan.Action {
+	case ActionSkip:
+		// Already rendered — nothing to change
+		if rc.repo.Pinned() {
+			if plan.Current == plan.Commit {
+				rc.commit = plan.Current
+			}
+			progress(fmt.Sprintf("      └── %s (%s at %s) exists, skipped", rc.repoPath, rc.repo.Ref, ShortSHA(plan.Current)))
+			return nil
+		}
+		if plan.recordBase {
+			if err := s.recordBase(ctx, rc, plan.Base); err != nil {
+				return err
+			}
+		}
+		progress(fmt.Sprintf("      └── %s (%s) exists, skipped", rc.repoPath, plan.Current))
+		return nil
+
+	case ActionSwitchBranch:
+		if plan.NewBranch {
+			start, err := s.remoteRef(ctx, rc.barePath, plan.Base)
+			if err != nil {
+				return fmt.Errorf("ensuring remote ref for %s: %w", rc.repo.URL, err)
+			}
+			if err := s.Git.CheckoutNewBranch(ctx, rc.worktreePath, rc.repo.Branch, start); err != nil {
+				return fmt.Errorf("switching %s to %s: %w", rc.repoPath, rc.repo.Branch, err)
+			}
+		} else if err := s.Git.CheckoutBranch(ctx, rc.worktreePath, rc.repo.Branch); err != nil {
+			return fmt.Errorf("switching %s to %s: %w", rc.repoPath, rc.repo.Branch, err)
+		}
+		if err := s.recordBase(ctx, rc, plan.Base); err != nil {
 			return err
 		}
+		progress(fmt.Sprintf("      └── %s (%s → %s) switched ✓", rc.repoPath, plan.Current, rc.repo.Branch))
+		return nil
 
-		if err := s.Git.EnsureRemoteRef(ctx, rc.barePath, baseBranch); err != nil {
+	case ActionRebase:
+		return s.rebaseOntoNewBase(ctx, rc, plan, progress)
+
+	case ActionPin:
+		return s.pin(ctx, rc, opts, progress)
+
+	case ActionCreateBranch, ActionResetBranch:
+		// Reset mode: create a clean branch from base, regardless of whether branch exists
+		ref, err := s.remoteRef(ctx, rc.barePath, plan.Base)
+		if err != nil {
 			return fmt.Errorf("ensuring remote ref for %s: %w", rc.repo.URL, err)
 		}
 
-		if exists {
+		if plan.Action == ActionResetBranch {
 			// Branch exists (possibly checked out in another worktree) —
 			// create worktree from it, then hard-reset to base.
-			s.log().Debug("resetting branch", "branch", rc.repo.Branch, "from", baseBranch)
+			if plan.Unpushed > 0 {
+				backup := backupRef(rc.repo.Branch, time.Now())
+				if err := s.Git.UpdateRef(ctx, rc.barePath, backup, "refs/heads/"+rc.repo.Branch); err != nil {
+					return fmt.Errorf("backing up %s for %s: %w", rc.repo.Branch, rc.repo.URL, err)
+				}
+				progress(fmt.Sprintf("      └── %s (%s) saved %d unpushed commit(s) to %s", rc.repoPath, rc.repo.Branch, plan.Unpushed, backup))
+			}
+			s.log().Debug("resetting branch", "branch", rc.repo.Branch, "from", plan.Base)
 			if err := s.Git.AddWorktree(ctx, rc.barePath, rc.worktreePath, rc.repo.Branch); err != nil {
 				return fmt.Errorf("creating worktree for %s: %w", rc.repo.URL, err)
 			}
-			ref := "origin/" + baseBranch
 			if err := s.Git.ResetBranch(ctx, rc.worktreePath, ref); err != nil {
 				return fmt.Errorf("resetting branch to %s for %s: %w", ref, rc.repo.URL, err)
 			}
-			progress(fmt.Sprintf("      └── %s (%s, reset from %s) ✓", rc.repoPath, rc.repo.Branch, baseBranch))
-		} else {
-			// Branch doesn't exist — create new branch from base
-			startPoint := "origin/" + baseBranch
-			s.log().Debug("creating worktree with new branch", "path", rc.worktreePath, "branch", rc.repo.Branch, "from", startPoint)
-			if err := s.Git.AddWorktreeNewBranch(ctx, rc.barePath, rc.worktreePath, rc.repo.Branch, startPoint); err != nil {
-				return fmt.Errorf("creating worktree for %s: %w", rc.repo.URL, err)
+			if err := s.recordBase(ctx, rc, plan.Base); err != nil {
+				return err
 			}
-			progress(fmt.Sprintf("      └── %s (%s, new branch from %s) ✓", rc.repoPath, rc.repo.Branch, baseBranch))
+			progress(fmt.Sprintf("      └── %s (%s, reset from %s) ✓", rc.repoPath, rc.repo.Branch, plan.Base))
+			return nil
+		}
+
+		// Branch doesn't exist — create new branch from base
+		s.log().Debug("creating worktree with new branch", "path", rc.worktreePath, "branch", rc.repo.Branch, "from", ref)
+		if err := s.Git.AddWorktreeNewBranch(ctx, rc.barePath, rc.worktreePath, rc.repo.Branch, ref); err != nil {
+			return fmt.Errorf("creating worktree for %s: %w", rc.repo.URL, err)
 		}
+		if err := s.recordBase(ctx, rc, plan.Base); err != nil {
+			return err
+		}
+		progress(fmt.Sprintf("      └── %s (%s, new branch from %s) ✓", rc.repoPath, rc.repo.Branch, plan.Base))
 		return nil
+
+	case ActionUseExisting:
+		s.log().Debug("creating worktree from existing branch", "path", rc.worktreePath, "branch", rc.repo.Branch)
+		if err := s.Git.AddWorktree(ctx, rc.barePath, rc.worktreePath, rc.repo.Branch); err != nil {
+			return fmt.Errorf("creating worktree for %s: %w", rc.repo.URL, err)
+		}
+		base, err := s.resolveBaseBranch(ctx, rc)
+		if err != nil {
+			return err
+		}
+		if err := s.recordBase(ctx, rc, base); err != nil {
+			return err
+		}
+		// Fast-forward to latest remote state
+		return s.updateWorktreeRemote(ctx, rc, opts, progress)
+	}
+
+	return nil
+}
+
+// rebaseOntoNewBase moves the commits made on top of the previously recorded
+// base onto the new one. A conflicting rebase is aborted and the recorded
+// base is left unchanged so the next render reports it again.
+func (s *Service) rebaseOntoNewBase(ctx context.Context, rc *repoRenderContext, plan *RepoPlan, progress func(msg string)) error {
+	onto, err := s.remoteRef(ctx, rc.barePath, plan.Base)
+	if err != nil {
+		return fmt.Errorf("ensuring remote ref for %s: %w", rc.repo.URL, err)
+	}
+
+	if upstream, uerr := s.remoteRef(ctx, rc.barePath, plan.PrevBase); uerr == nil {
+		err = s.Git.RebaseOnto(ctx, rc.worktreePath, onto, upstream)
+	} else {
+		// Old base is gone from the remote — fall back to a plain rebase.
+		err = s.Git.Rebase(ctx, rc.worktreePath, onto)
+	}
+	if err != nil {
+		_ = s.Git.RebaseAbort(ctx, rc.worktreePath)
+		progress(fmt.Sprintf("      └── %s (%s) conflict rebasing onto %s, aborted", rc.repoPath, rc.repo.Branch, onto))
+		return fmt.Errorf("rebasing %s onto %s: %w", rc.repoPath, onto, err)
 	}
 
-	// Use-existing mode: branch must exist in remote
-	if !exists {
-		return fmt.Errorf("%w: branch %q does not exist in remote for %s\n  Hint: remove --reset=false to create a new branch, or push the branch to the remote first",
-			ErrBranchNotFound, rc.repo.Branch, rc.repoPath)
+	if err := s.recordBase(ctx, rc, plan.Base); err != nil {
+		return err
 	}
+	progress(fmt.Sprintf("      └── %s (%s) rebased onto %s (was %s) ✓", rc.repoPath, rc.repo.Branch, onto, plan.PrevBase))
+	return nil
+}
+
+// baseConfigKey is the per-worktree git config key that records the base a
+// branch was last rendered against. It is kept out of the bare repo's shared
+// config so workspaces with the same branch don't overwrite each other's.
+func baseConfigKey(branch string) string {
+	return "branch." + branch + ".flowBase"
+}
 
-	s.log().Debug("creating worktree from existing branch", "path", rc.worktreePath, "branch", rc.repo.Branch)
-	if err := s.Git.AddWorktree(ctx, rc.barePath, rc.worktreePath, rc.repo.Branch); err != nil {
-		return fmt.Errorf("creating worktree for %s: %w", rc.repo.URL, err)
+// recordBase remembers the base a branch was rendered against so a later
+// change to base: in state can be detected.
+func (s *Service) recordBase(ctx context.Context, rc *repoRenderContext, base string) error {
+	if err := s.Git.SetWorktreeConfig(ctx, rc.worktreePath, baseConfigKey(rc.repo.Branch), base); err != nil {
+		return fmt.Errorf("recording base for %s: %w", rc.repoPath, err)
 	}
-	// Fast-forward to latest remote state
-	return s.updateWorktreeRemote(ctx, rc, progress)
+	return nil
 }
 
-// updateWorktreeRemote updates an existing worktree to the latest remote ref.
-func (s *Service) updateWorktreeRemote(ctx context.Context, rc *repoRenderContext, progress func(msg string)) error {
+// backupRef returns the ref a forced reset saves branch's old tip under.
+func backupRef(branch string, now time.Time) string {
+	return BackupRefPrefix + now.UTC().Format("20060102-150405") + "/" + branch
+}
+
+// updateWorktreeRemote fast-forwards an existing worktree to the latest
+// remote ref. A dirty worktree, or one with commits the remote doesn't have,
+// is left as it is.
+func (s *Service) updateWorktreeRemote(ctx context.Context, rc *repoRenderContext, opts *RenderOptions, progress func(msg string)) error {
+	if s.Offline {
+		progress(fmt.Sprintf("      └── %s (%s) exists (offline, not updated)", rc.repoPath, rc.repo.Branch))
+		return nil
+	}
 	if err := s.Git.EnsureRemoteRef(ctx, rc.barePath, rc.repo.Branch); err != nil {
 		s.log().Debug("worktree exists, no remote branch to update from", "path", rc.worktreePath, "branch", rc.repo.Branch)
 		progress(fmt.Sprintf("      └── %s (%s) exists", rc.repoPath, rc.repo.Branch))
+		opts.warn(rc, fmt.Sprintf("%s not found on the remote; checked out from the local cache", rc.repo.Branch))
 		return nil
 	}
 
@@ -404,7 +690,19 @@ func (s *Service) updateWorktreeRemote(ctx context.Context, rc *repoRenderContex
 		return nil
 	}
 
+	// Only fast-forward: commits that origin/<branch> doesn't have (e.g.
+	// restored from an archive) must not be reset away.
 	ref := "origin/" + rc.repo.Branch
+	local, err := s.Git.CommitsAhead(ctx, rc.worktreePath, ref, "HEAD")
+	if err != nil {
+		return fmt.Errorf("comparing %s with %s for %s: %w", rc.repo.Branch, ref, rc.repo.URL, err)
+	}
+	if local > 0 {
+		s.log().Debug("worktree has local commits, skipping update", "path", rc.worktreePath, "commits", local)
+		progress(fmt.Sprintf("      └── %s (%s) exists (%d local commit(s), not updated)", rc.repoPath, rc.repo.Branch, local))
+		return nil
+	}
+
 	s.log().Debug("updating worktree to latest remote", "path", rc.worktreePath, "ref", ref)
 	if err := s.Git.ResetBranch(ctx, rc.worktreePath, ref); err != nil {
 		return fmt.Errorf("updating worktree for %s: %w", rc.repo.URL, err)
@@ -426,15 +724,21 @@ func (s *Service) resolveBaseBranch(ctx context.Context, rc *repoRenderContext)
 	return baseBranch, nil
 }
 
-// Sync fetches and rebases worktrees onto their base branches.
-// It continues through failures — one repo failing doesn't block others.
+// Sync fetches and rebases worktrees onto their base branches. Pinned repos
+// are left where they are. It continues through failures — one repo failing doesn't block others.
 func (s *Service) Sync(ctx context.Context, id string, progress func(msg string)) error {
+	l, err := s.lockWorkspace(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
 	st, err := s.Find(id)
 	if err != nil {
 		return err
 	}
 
-	if err := state.Validate(st); err != nil {
+	if err := state.Validate(st, s.defaultBranchRule(ctx)); err != nil {
 		return fmt.Errorf("invalid state: %w", err)
 	}
 
@@ -443,70 +747,84 @@ func (s *Service) Sync(ctx context.Context, id string, progress func(msg string)
 	var errs []error
 
 	for i, repo := range st.Spec.Repos {
-		repoPath := state.RepoPath(repo)
-		barePath := s.Config.BareRepoPath(repo.URL)
-		worktreePath := filepath.Join(wsDir, repoPath)
-
 		progress(fmt.Sprintf("[%d/%d] %s", i+1, total, repo.URL))
-
-		// Skip if worktree doesn't exist
-		if _, err := os.Stat(worktreePath); os.IsNotExist(err) {
-			progress(fmt.Sprintf("      └── %s skipped (not rendered)", repoPath))
-			continue
+		if err := s.syncRepo(ctx, wsDir, repo, progress); err != nil {
+			errs = append(errs, err)
 		}
+	}
 
-		// Fetch bare repo
-		if err := s.Git.Fetch(ctx, barePath); err != nil {
-			errs = append(errs, fmt.Errorf("%s: fetch: %w", repoPath, err))
-			progress(fmt.Sprintf("      └── %s fetch failed", repoPath))
-			continue
-		}
+	return errors.Join(errs...)
+}
 
-		// Resolve base branch
-		var baseBranch string
-		if repo.Base != "" {
-			baseBranch = repo.Base
-		} else {
-			baseBranch, err = s.Git.DefaultBranch(ctx, barePath)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("%s: default branch: %w", repoPath, err))
-				progress(fmt.Sprintf("      └── %s failed to resolve base branch", repoPath))
-				continue
-			}
-		}
+// syncRepo fetches and rebases one worktree, holding its bare repo's lock
+// since the rebase moves the branch ref stored there.
+func (s *Service) syncRepo(ctx context.Context, wsDir string, repo state.Repo, progress func(msg string)) error {
+	repoPath := state.RepoPath(repo)
+	barePath := s.Config.BareRepoPath(repo.URL)
+	worktreePath := filepath.Join(wsDir, repoPath)
 
-		// Ensure remote ref so origin/{base} resolves from worktrees
-		if err := s.Git.EnsureRemoteRef(ctx, barePath, baseBranch); err != nil {
-			errs = append(errs, fmt.Errorf("%s: ensure remote ref: %w", repoPath, err))
-			progress(fmt.Sprintf("      └── %s failed to ensure remote ref", repoPath))
-			continue
-		}
+	// Skip if worktree doesn't exist
+	if _, err := os.Stat(worktreePath); os.IsNotExist(err) {
+		progress(fmt.Sprintf("      └── %s skipped (not rendered)", repoPath))
+		return nil
+	}
+	if repo.Pinned() {
+		progress(fmt.Sprintf("      └── %s skipped (pinned to %s)", repoPath, repo.Ref))
+		return nil
+	}
+
+	l, err := s.lockRepo(ctx, barePath)
+	if err != nil {
+		progress(fmt.Sprintf("      └── %s skipped (repo busy)", repoPath))
+		return fmt.Errorf("%s: %w", repoPath, err)
+	}
+	defer l.Release()
 
-		// Check clean
-		clean, err := s.Git.IsClean(ctx, worktreePath)
+	// Fetch bare repo
+	if s.Offline {
+		s.log().Debug("offline, skipping fetch", "url", repo.URL)
+	} else if err := s.fetch(ctx, barePath); err != nil {
+		progress(fmt.Sprintf("      └── %s fetch failed", repoPath))
+		return fmt.Errorf("%s: fetch: %w", repoPath, err)
+	}
+
+	// Resolve base branch
+	baseBranch := repo.Base
+	if baseBranch == "" {
+		baseBranch, err = s.Git.DefaultBranch(ctx, barePath)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("%s: checking clean: %w", repoPath, err))
-			progress(fmt.Sprintf("      └── %s failed to check status", repoPath))
-			continue
-		}
-		if !clean {
-			progress(fmt.Sprintf("      └── %s skipped (dirty worktree)", repoPath))
-			continue
+			progress(fmt.Sprintf("      └── %s failed to resolve base branch", repoPath))
+			return fmt.Errorf("%s: default branch: %w", repoPath, err)
 		}
+	}
 
-		// Rebase onto origin/{base}
-		onto := "origin/" + baseBranch
-		if err := s.Git.Rebase(ctx, worktreePath, onto); err != nil {
-			_ = s.Git.RebaseAbort(ctx, worktreePath)
-			errs = append(errs, fmt.Errorf("%s: rebase onto %s: %w", repoPath, onto, err))
-			progress(fmt.Sprintf("      └── %s rebase failed (aborted)", repoPath))
-			continue
-		}
+	// Ensure remote ref so origin/{base} resolves from worktrees
+	onto, err := s.remoteRef(ctx, barePath, baseBranch)
+	if err != nil {
+		progress(fmt.Sprintf("      └── %s failed to ensure remote ref", repoPath))
+		return fmt.Errorf("%s: ensure remote ref: %w", repoPath, err)
+	}
 
-		progress(fmt.Sprintf("      └── %s rebased onto %s ✓", repoPath, onto))
+	// Check clean
+	clean, err := s.Git.IsClean(ctx, worktreePath)
+	if err != nil {
+		progress(fmt.Sprintf("      └── %s failed to check status", repoPath))
+		return fmt.Errorf("%s: checking clean: %w", repoPath, err)
+	}
+	if !clean {
+		progress(fmt.Sprintf("      └── %s skipped (dirty worktree)", repoPath))
+		return nil
 	}
 
-	return errors.Join(errs...)
+	// Rebase onto origin/{base}
+	if err := s.Git.Rebase(ctx, worktreePath, onto); err != nil {
+		_ = s.Git.RebaseAbort(ctx, worktreePath)
+		progress(fmt.Sprintf("      └── %s rebase failed (aborted)", repoPath))
+		return fmt.Errorf("%s: rebase onto %s: %w", repoPath, onto, err)
+	}
+
+	progress(fmt.Sprintf("      └── %s rebased onto %s ✓", repoPath, onto))
+	return nil
 }
 
 // shouldResetBranch determines whether to reset an existing branch based on options.
@@ -516,52 +834,177 @@ func (s *Service) shouldResetBranch(opts *RenderOptions) bool {
 
 // Archive removes worktrees (freeing branches) and marks the workspace as archived.
 // The workspace directory and state file are preserved so it can still appear in listings.
+// Uncommitted changes and local-only commits are saved under the workspace's
+// archive directory first and recorded in metadata for Unarchive to reapply.
+// A worktree whose work can't be saved is kept, and the workspace is not
+// marked archived.
 func (s *Service) Archive(ctx context.Context, id string) error {
+	l, err := s.lockWorkspace(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
 	st, err := s.Find(id)
 	if err != nil {
 		return err
 	}
 
+	if err := checkRescueDir(st); err != nil {
+		return err
+	}
+
 	wsDir := s.Config.WorkspacePath(id)
 	s.log().Debug("archiving workspace", "id", id, "path", wsDir)
 
 	// Remove worktrees to free branches
-	for _, repo := range st.Spec.Repos {
-		barePath := s.Config.BareRepoPath(repo.URL)
-		worktreePath := filepath.Join(wsDir, state.RepoPath(repo))
+	var errs []error
+	for _, rc := range s.renderContexts(wsDir, st) {
+		if _, err := os.Stat(rc.worktreePath); err != nil {
+			continue
+		}
 
-		if _, err := os.Stat(worktreePath); err == nil {
-			s.log().Debug("removing worktree", "path", worktreePath)
-			_ = s.Git.RemoveWorktree(ctx, barePath, worktreePath)
+		rescued, err := s.rescueWork(ctx, wsDir, &rc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("saving work in %s: %w", rc.repoPath, err))
+			continue
+		}
+		if rescued != nil {
+			st.Metadata.Rescued = addRescued(st.Metadata.Rescued, *rescued)
+		}
+
+		s.log().Debug("removing worktree", "path", rc.worktreePath)
+		err = s.withRepoLock(ctx, rc.barePath, func() error {
+			return s.Git.RemoveWorktree(ctx, rc.barePath, rc.worktreePath)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("removing worktree %s: %w", rc.repoPath, err))
 		}
 	}
 
-	// Mark as archived in state
-	st.Metadata.Archived = true
-	return state.Save(s.Config.StatePath(id), st)
+	// Mark as archived in state. Rescue records are saved even on failure so
+	// work from worktrees that were removed isn't orphaned.
+	st.Metadata.Archived = len(errs) == 0
+	if err := state.Save(s.Config.StatePath(id), st); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
 }
 
-// Delete removes all worktrees and the workspace directory.
-func (s *Service) Delete(ctx context.Context, id string) error {
+// Unarchive clears the archived flag and re-creates every worktree from its
+// existing branch, restored from the bare cache or the remote. Work rescued
+// by Archive is reapplied. Repos that can't be restored (e.g. the branch was
+// deleted upstream and isn't cached) don't stop the others; they are
+// returned together as RepoErrors.
+func (s *Service) Unarchive(ctx context.Context, id string, progress func(msg string), warn func(msg string)) error {
+	l, err := s.lockWorkspace(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
 	st, err := s.Find(id)
 	if err != nil {
 		return err
 	}
 
+	s.log().Debug("unarchiving workspace", "id", id)
+	st.Metadata.Archived = false
+	if err := state.Save(s.Config.StatePath(id), st); err != nil {
+		return err
+	}
+	return s.rebuild(ctx, id, st, progress, warn)
+}
+
+// rebuild re-creates a workspace's worktrees from their existing branches
+// and reapplies rescued work. Per-repo failures are returned as RepoErrors.
+// The caller holds the workspace lock.
+func (s *Service) rebuild(ctx context.Context, id string, st *state.State, progress, warn func(msg string)) error {
+	if warn == nil {
+		warn = func(string) {}
+	}
+	wsDir := s.Config.WorkspacePath(id)
+	bundles := s.restoreBundles(ctx, wsDir, st, warn)
+
+	renderErr := s.render(ctx, id, progress, &RenderOptions{
+		OnBranchConflict: BranchConflictUseExisting,
+		ContinueOnError:  true,
+		Warn:             warn,
+	})
+
+	if len(st.Metadata.Rescued) > 0 {
+		// Render saves the state when it records pinned commits; reload it
+		// so that save isn't overwritten with the copy read before.
+		current, err := s.Find(id)
+		if err != nil {
+			return errors.Join(renderErr, err)
+		}
+		st = current
+		st.Metadata.Rescued = s.reapplyRescued(ctx, wsDir, st, bundles, progress, warn)
+		if len(st.Metadata.Rescued) == 0 {
+			_ = os.RemoveAll(filepath.Join(wsDir, rescueDir))
+		}
+		if err := state.Save(s.Config.StatePath(id), st); err != nil {
+			return errors.Join(renderErr, err)
+		}
+	}
+	return renderErr
+}
+
+// Delete removes a workspace's worktrees, stray ones included, and moves
+// what remains — state, status spec, generated files, and uncommitted
+// changes and unpushed commits saved from the worktrees — to the trash.
+// Returns the trash entry name.
+func (s *Service) Delete(ctx context.Context, id string) (string, error) {
+	l, err := s.lockWorkspace(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	defer l.Release()
+
+	st, err := s.Find(id)
+	if err != nil {
+		return "", err
+	}
+
 	wsDir := s.Config.WorkspacePath(id)
 	s.log().Debug("deleting workspace", "id", id, "path", wsDir)
 
-	// Remove worktrees
-	for _, repo := range st.Spec.Repos {
-		barePath := s.Config.BareRepoPath(repo.URL)
-		worktreePath := filepath.Join(wsDir, state.RepoPath(repo))
+	// Work can't be saved if a repo lives in the rescue directory.
+	rescue := checkRescueDir(st) == nil
+
+	// Remove worktrees, including undeclared ones, so no bare repo keeps
+	// an entry for a worktree that is about to move to the trash.
+	strays, err := s.strayContexts(ctx, wsDir, st)
+	if err != nil {
+		return "", err
+	}
+	for _, rc := range append(s.renderContexts(wsDir, st), strays...) {
+		if _, err := os.Stat(rc.worktreePath); err != nil {
+			continue
+		}
 
-		if _, err := os.Stat(worktreePath); err == nil {
-			s.log().Debug("removing worktree", "path", worktreePath)
-			// Best effort — worktree may already be gone
-			_ = s.Git.RemoveWorktree(ctx, barePath, worktreePath)
+		if rescue {
+			// Best effort — the worktree is removed either way.
+			rescued, err := s.rescueWork(ctx, wsDir, &rc)
+			if err != nil {
+				s.log().Debug("saving work failed", "path", rc.repoPath, "err", err)
+			} else if rescued != nil {
+				st.Metadata.Rescued = addRescued(st.Metadata.Rescued, *rescued)
+			}
 		}
+
+		s.log().Debug("removing worktree", "path", rc.worktreePath)
+		// Best effort — worktree may already be gone
+		_ = s.withRepoLock(ctx, rc.barePath, func() error {
+			return s.Git.RemoveWorktree(ctx, rc.barePath, rc.worktreePath)
+		})
 	}
 
-	return os.RemoveAll(wsDir)
+	if len(st.Metadata.Rescued) > 0 {
+		if err := state.Save(s.Config.StatePath(id), st); err != nil {
+			return "", err
+		}
+	}
+	return s.moveToTrash(id)
 }
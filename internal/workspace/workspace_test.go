@@ -11,6 +11,7 @@ import (
 
 	"github.com/milldr/flow/internal/agents"
 	"github.com/milldr/flow/internal/config"
+	"github.com/milldr/flow/internal/git"
 	"github.com/milldr/flow/internal/state"
 )
 
@@ -19,6 +20,10 @@ import (
 type mockRunner struct {
 	mu          sync.Mutex
 	clones      []string
+	cloneOpts   []git.CloneOptions
+	fetched     []string // branches fetched with FetchBranch
+	fetchedRefs []string // tags and commits fetched with FetchRef
+	detached    []string // commits checked out detached
 	fetches     []string
 	worktrees   []string
 	removed     []string
@@ -28,6 +33,17 @@ type mockRunner struct {
 	rebases     []string
 	aborts      []string
 	checkouts   []string
+	backups     []string
+	patches     []string
+	bundles     []string
+	pruned      []string
+	collected   []string
+	config      map[string]string
+	wtConfig    map[string]string   // per-worktree config, for every worktree
+	linked      map[string][]string // bare repo → registered worktrees
+	// onListWorktrees, if set, runs at the start of every ListWorktrees.
+	onListWorktrees func(bareRepo string)
+	refs            map[string]map[string]string // bare repo → ref → commit, for ListRefs
 
 	cloneErr      error
 	fetchErr      error
@@ -37,11 +53,23 @@ type mockRunner struct {
 	rebaseErr     error
 	resetErr      error
 	currentBranch string
+	unpushed      int
+	ahead         int
+	diff          []byte
+	stashes       int
+	noRefs        bool // RefExists reports every ref missing
+	// commits maps refs to what ResolveRef returns for them; any other ref
+	// resolves to testCommit.
+	commits map[string]string
 }
 
-func (m *mockRunner) BareClone(_ context.Context, url, dest string) error {
+// testCommit is the commit mockRunner resolves refs to by default.
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+func (m *mockRunner) BareClone(_ context.Context, url, dest string, opts git.CloneOptions) error {
 	m.mu.Lock()
 	m.clones = append(m.clones, url)
+	m.cloneOpts = append(m.cloneOpts, opts)
 	cloneErr := m.cloneErr
 	m.mu.Unlock()
 	if cloneErr != nil {
@@ -58,6 +86,20 @@ func (m *mockRunner) Fetch(_ context.Context, repoPath string) error {
 	return fetchErr
 }
 
+func (m *mockRunner) FetchBranch(_ context.Context, _, branch string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fetched = append(m.fetched, branch)
+	return true, nil
+}
+
+func (m *mockRunner) FetchRef(_ context.Context, _, ref string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fetchedRefs = append(m.fetchedRefs, ref)
+	return m.fetchErr
+}
+
 func (m *mockRunner) AddWorktree(_ context.Context, _, worktreePath, _ string) error {
 	m.mu.Lock()
 	m.worktrees = append(m.worktrees, worktreePath)
@@ -81,6 +123,18 @@ func (m *mockRunner) AddWorktreeNewBranch(_ context.Context, _, worktreePath, _,
 	return os.MkdirAll(worktreePath, 0o755)
 }
 
+func (m *mockRunner) AddWorktreeDetached(_ context.Context, _, worktreePath, commit string) error {
+	m.mu.Lock()
+	m.detached = append(m.detached, commit)
+	m.worktrees = append(m.worktrees, worktreePath)
+	addWTErr := m.addWTErr
+	m.mu.Unlock()
+	if addWTErr != nil {
+		return addWTErr
+	}
+	return os.MkdirAll(worktreePath, 0o755)
+}
+
 func (m *mockRunner) RemoveWorktree(_ context.Context, _, worktreePath string) error {
 	m.mu.Lock()
 	m.removed = append(m.removed, worktreePath)
@@ -164,6 +218,155 @@ func (m *mockRunner) CheckoutNewBranch(_ context.Context, _, newBranch, startPoi
 	return nil
 }
 
+func (m *mockRunner) CheckoutDetached(_ context.Context, _, commit string) error {
+	m.mu.Lock()
+	m.detached = append(m.detached, commit)
+	m.currentBranch = "HEAD"
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockRunner) UnpushedCommits(_ context.Context, _, _ string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.unpushed, nil
+}
+
+func (m *mockRunner) CommonDir(_ context.Context, worktreePath string) (string, error) {
+	return filepath.Join(filepath.Dir(worktreePath), "bare.git"), nil
+}
+
+func (m *mockRunner) CommitsAhead(_ context.Context, _, _, _ string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ahead, nil
+}
+
+func (m *mockRunner) RefExists(_ context.Context, _, _ string) (bool, error) {
+	return !m.noRefs, nil
+}
+
+func (m *mockRunner) UpdateRef(_ context.Context, _, ref, _ string) error {
+	m.mu.Lock()
+	m.backups = append(m.backups, ref)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockRunner) RebaseOnto(_ context.Context, _, onto, _ string) error {
+	m.mu.Lock()
+	m.rebases = append(m.rebases, onto)
+	rebaseErr := m.rebaseErr
+	m.mu.Unlock()
+	return rebaseErr
+}
+
+func (m *mockRunner) GetConfig(_ context.Context, _, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config[key], nil
+}
+
+func (m *mockRunner) SetConfig(_ context.Context, _, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.config == nil {
+		m.config = make(map[string]string)
+	}
+	m.config[key] = value
+	return nil
+}
+
+func (m *mockRunner) GetWorktreeConfig(_ context.Context, _, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.wtConfig[key], nil
+}
+
+func (m *mockRunner) SetWorktreeConfig(_ context.Context, _, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.wtConfig == nil {
+		m.wtConfig = make(map[string]string)
+	}
+	m.wtConfig[key] = value
+	return nil
+}
+
+func (m *mockRunner) ResolveRef(_ context.Context, _, ref string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.commits[ref]; ok {
+		return c, nil
+	}
+	return testCommit, nil
+}
+
+func (m *mockRunner) DiffWorktree(_ context.Context, _ string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.diff, nil
+}
+
+func (m *mockRunner) ApplyPatch(_ context.Context, _, patchFile string) error {
+	m.mu.Lock()
+	m.patches = append(m.patches, patchFile)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockRunner) CreateBundle(_ context.Context, _, bundleFile, _ string) error {
+	return os.WriteFile(bundleFile, nil, 0o644)
+}
+
+func (m *mockRunner) FetchBundle(_ context.Context, _, bundleFile, _ string) error {
+	m.mu.Lock()
+	m.bundles = append(m.bundles, bundleFile)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockRunner) StashCount(_ context.Context, _, _ string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stashes, nil
+}
+
+func (m *mockRunner) PruneWorktrees(_ context.Context, bareRepo string) error {
+	m.mu.Lock()
+	m.pruned = append(m.pruned, bareRepo)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockRunner) ListWorktrees(_ context.Context, bareRepo string) ([]string, error) {
+	if m.onListWorktrees != nil {
+		m.onListWorktrees(bareRepo)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.linked[bareRepo], nil
+}
+
+func (m *mockRunner) GarbageCollect(_ context.Context, repoPath string) error {
+	m.mu.Lock()
+	m.collected = append(m.collected, repoPath)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockRunner) ListRefs(_ context.Context, repoPath, prefix string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refs := make(map[string]string)
+	for ref, sha := range m.refs[repoPath] {
+		if strings.HasPrefix(ref, prefix) {
+			refs[ref] = sha
+		}
+	}
+	return refs, nil
+}
+
 func testService(t *testing.T) (*Service, *mockRunner) {
 	t.Helper()
 	dir := t.TempDir()
@@ -173,6 +376,10 @@ func testService(t *testing.T) (*Service, *mockRunner) {
 		ReposDir:       filepath.Join(dir, "repos"),
 		AgentsDir:      filepath.Join(dir, "agents"),
 		CacheDir:       filepath.Join(dir, "cache"),
+		TrashDir:       filepath.Join(dir, "trash"),
+		LocksDir:       filepath.Join(dir, "locks"),
+		SchemasDir:     filepath.Join(dir, "schemas"),
+		LayoutFile:     filepath.Join(dir, "layout"),
 		ConfigFile:     filepath.Join(dir, "config.yaml"),
 		StatusSpecFile: filepath.Join(dir, "status.yaml"),
 	}
@@ -182,6 +389,8 @@ func testService(t *testing.T) (*Service, *mockRunner) {
 	if err := agents.EnsureSharedAgent(cfg.AgentsDir); err != nil {
 		t.Fatal(err)
 	}
+	// Fetch every time; the fetch cache has its own tests.
+	cfg.FlowConfig.Spec.Git.FetchTTL = "0"
 	mock := &mockRunner{}
 	return &Service{Config: cfg, Git: mock}, mock
 }
@@ -274,8 +483,8 @@ func TestRender(t *testing.T) {
 	ctx := context.Background()
 
 	st := state.NewState("Render test", "Render test", []state.Repo{
-		{URL: "github.com/org/repo-a", Branch: "main", Path: "./repo-a"},
-		{URL: "github.com/org/repo-b", Branch: "main", Path: "./repo-b"},
+		{URL: "github.com/org/repo-a", Branch: "feature", Path: "./repo-a"},
+		{URL: "github.com/org/repo-b", Branch: "feature", Path: "./repo-b"},
 	})
 
 	if err := svc.Create("render-ws", st); err != nil {
@@ -349,7 +558,8 @@ func TestDelete(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := svc.Delete(ctx, "del-ws"); err != nil {
+	entry, err := svc.Delete(ctx, "del-ws")
+	if err != nil {
 		t.Fatalf("Delete: %v", err)
 	}
 
@@ -362,6 +572,11 @@ func TestDelete(t *testing.T) {
 	if _, err := os.Stat(wsDir); !os.IsNotExist(err) {
 		t.Error("workspace directory still exists after delete")
 	}
+
+	// State should be in the trash
+	if _, err := os.Stat(filepath.Join(svc.Config.TrashPath(entry), "state.yaml")); err != nil {
+		t.Errorf("state not moved to trash: %v", err)
+	}
 }
 
 func TestFindNotFound(t *testing.T) {
@@ -421,7 +636,7 @@ func TestListSkipsNonDirs(t *testing.T) {
 	}
 }
 
-func TestListSkipsMalformedState(t *testing.T) {
+func TestListIncludesMalformedState(t *testing.T) {
 	svc, _ := testService(t)
 
 	// Create a workspace dir with invalid state.yaml
@@ -437,9 +652,25 @@ func TestListSkipsMalformedState(t *testing.T) {
 	if err != nil {
 		t.Fatalf("List: %v", err)
 	}
-	// Broken workspace should be skipped, not cause an error
-	if len(infos) != 0 {
-		t.Errorf("expected 0 infos (broken skipped), got %d", len(infos))
+	// Broken workspace should be listed with its error, not fail the listing
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 info, got %d", len(infos))
+	}
+	if infos[0].ID != "broken" || infos[0].Err == nil {
+		t.Errorf("info = %+v, want broken with Err set", infos[0])
+	}
+
+	// Resolving it by ID reports the error instead of "not found"
+	if _, err := svc.Resolve("broken"); err == nil || errors.Is(err, ErrWorkspaceNotFound) {
+		t.Errorf("Resolve error = %v, want the parse error", err)
+	}
+
+	problems, err := svc.Validate(context.Background(), "broken")
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(problems) != 1 || !errors.Is(problems[0], state.ErrInvalidYAML) {
+		t.Errorf("problems = %v, want one ErrInvalidYAML", problems)
 	}
 }
 
@@ -464,12 +695,48 @@ func TestListMultipleWorkspaces(t *testing.T) {
 	}
 }
 
+func TestRenderCloneOptions(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	single := true
+	full := false
+	svc.Config.FlowConfig.Spec.Git.Clone = state.CloneOptions{Filter: "blob:none", SingleBranch: &single}
+	st := state.NewState("Clone options", "", []state.Repo{
+		{URL: "github.com/org/mono", Branch: "feature", Path: "./mono"},
+		{URL: "github.com/org/small", Branch: "main", Path: "./small",
+			Clone: &state.CloneOptions{Depth: 10, SingleBranch: &full}},
+	})
+	if err := svc.Create("clone-ws", st); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := svc.Render(ctx, "clone-ws", noop, nil); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	got := make(map[string]git.CloneOptions)
+	for i, url := range mock.clones {
+		got[url] = mock.cloneOpts[i]
+	}
+	if want := (git.CloneOptions{Filter: "blob:none", SingleBranch: true}); got["github.com/org/mono"] != want {
+		t.Errorf("mono options = %+v, want %+v", got["github.com/org/mono"], want)
+	}
+	if want := (git.CloneOptions{Filter: "blob:none", Depth: 10}); got["github.com/org/small"] != want {
+		t.Errorf("small options = %+v, want %+v", got["github.com/org/small"], want)
+	}
+
+	// Only the single-branch clone fetches its branch separately.
+	if len(mock.fetched) != 1 || mock.fetched[0] != "feature" {
+		t.Errorf("fetched branches = %v, want [feature]", mock.fetched)
+	}
+}
+
 func TestRenderExistingWorktreeSkipped(t *testing.T) {
 	svc, mock := testService(t)
 	ctx := context.Background()
 
 	st := state.NewState("Skip test", "Skip existing worktree test", []state.Repo{
-		{URL: "github.com/org/repo", Branch: "main", Path: "./repo"},
+		{URL: "github.com/org/repo", Branch: "feature", Path: "./repo"},
 	})
 	if err := svc.Create("skip-ws", st); err != nil {
 		t.Fatal(err)
@@ -667,7 +934,7 @@ func TestDeleteNotFound(t *testing.T) {
 	svc, _ := testService(t)
 	ctx := context.Background()
 
-	err := svc.Delete(ctx, "nonexistent")
+	_, err := svc.Delete(ctx, "nonexistent")
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -688,7 +955,7 @@ func TestDeleteWithoutRender(t *testing.T) {
 	}
 
 	// Delete without rendering — worktrees don't exist
-	if err := svc.Delete(ctx, "no-render"); err != nil {
+	if _, err := svc.Delete(ctx, "no-render"); err != nil {
 		t.Fatalf("Delete: %v", err)
 	}
 
@@ -714,7 +981,7 @@ func TestDeleteMultipleRepos(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := svc.Delete(ctx, "multi-del"); err != nil {
+	if _, err := svc.Delete(ctx, "multi-del"); err != nil {
 		t.Fatalf("Delete: %v", err)
 	}
 	if len(mock.removed) != 3 {
@@ -1113,7 +1380,7 @@ func TestRenderAddsNewRepoWithoutAffectingExisting(t *testing.T) {
 	ctx := context.Background()
 
 	st := state.NewState("Additive test", "Test additive render", []state.Repo{
-		{URL: "github.com/org/repo-a", Branch: "main", Path: "./repo-a"},
+		{URL: "github.com/org/repo-a", Branch: "feature", Path: "./repo-a"},
 	})
 	if err := svc.Create("additive-ws", st); err != nil {
 		t.Fatal(err)
@@ -1321,3 +1588,248 @@ func TestSyncMultiRepos(t *testing.T) {
 		t.Errorf("rebases = %d, want 3", len(mock.rebases))
 	}
 }
+
+func TestRenderSwitchesChangedBranch(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("switch", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "main", Path: "./repo"},
+	})
+	if err := svc.Create("switch-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "switch-ws", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	st.Spec.Repos[0].Branch = "feat/new"
+	if err := state.Save(svc.Config.StatePath("switch-ws"), st); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dirty worktree is left alone with a warning.
+	var warnings []string
+	opts := &RenderOptions{Warn: func(msg string) { warnings = append(warnings, msg) }}
+	if err := svc.Render(ctx, "switch-ws", noop, opts); err != nil {
+		t.Fatalf("Render (dirty): %v", err)
+	}
+	if len(mock.checkouts) != 0 {
+		t.Errorf("checkouts = %v, want none for dirty worktree", mock.checkouts)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "not switched") {
+		t.Errorf("warnings = %v, want one 'not switched' warning", warnings)
+	}
+
+	// Clean worktree switches to a new branch from the base.
+	mock.isClean = true
+	mock.fetches = nil
+	mock.startPoints = nil
+	if err := svc.Render(ctx, "switch-ws", noop, nil); err != nil {
+		t.Fatalf("Render (clean): %v", err)
+	}
+	if len(mock.fetches) != 1 {
+		t.Errorf("fetches = %d, want 1", len(mock.fetches))
+	}
+	if len(mock.checkouts) != 1 || mock.checkouts[0] != "feat/new" {
+		t.Errorf("checkouts = %v, want [feat/new]", mock.checkouts)
+	}
+	if len(mock.startPoints) != 1 || mock.startPoints[0] != "origin/main" {
+		t.Errorf("startPoints = %v, want [origin/main]", mock.startPoints)
+	}
+	if got := mock.wtConfig[baseConfigKey("feat/new")]; got != "main" {
+		t.Errorf("recorded base = %q, want main", got)
+	}
+}
+
+func TestRenderReportsChangedBase(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.isClean = true
+
+	st := state.NewState("base", "", []state.Repo{
+		{URL: "github.com/org/repo", Branch: "main", Path: "./repo"},
+	})
+	if err := svc.Create("base-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "base-ws", noop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	st.Spec.Repos[0].Base = "develop"
+	if err := state.Save(svc.Config.StatePath("base-ws"), st); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []string
+	opts := &RenderOptions{Warn: func(msg string) { warnings = append(warnings, msg) }}
+	if err := svc.Render(ctx, "base-ws", noop, opts); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "base changed from main to develop") {
+		t.Errorf("warnings = %v, want base change warning", warnings)
+	}
+	if len(mock.rebases) != 0 {
+		t.Errorf("rebases = %v, want none without Rebase", mock.rebases)
+	}
+
+	if err := svc.Render(ctx, "base-ws", noop, &RenderOptions{Rebase: true}); err != nil {
+		t.Fatalf("Render --rebase: %v", err)
+	}
+	if len(mock.rebases) != 1 || mock.rebases[0] != "origin/develop" {
+		t.Errorf("rebases = %v, want [origin/develop]", mock.rebases)
+	}
+	if got := mock.wtConfig[baseConfigKey("main")]; got != "develop" {
+		t.Errorf("recorded base = %q, want develop", got)
+	}
+}
+
+func TestUnarchive(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+	mock.isClean = true
+
+	st := state.NewState("unarchive", "", []state.Repo{
+		{URL: "github.com/org/repo-a", Branch: "feat/x"},
+		{URL: "github.com/org/repo-b", Branch: "feat/x"},
+	})
+	if err := svc.Create("unarchive-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "unarchive-ws", noop, &RenderOptions{OnBranchConflict: BranchConflictUseExisting}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Archive(ctx, "unarchive-ws"); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.worktrees = nil
+	if err := svc.Unarchive(ctx, "unarchive-ws", noop, nil); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+	if len(mock.worktrees) != 2 {
+		t.Errorf("worktrees = %d, want 2", len(mock.worktrees))
+	}
+	loaded, err := svc.Find("unarchive-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Metadata.Archived {
+		t.Error("workspace still archived")
+	}
+}
+
+func TestUnarchiveReportsMissingBranches(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+
+	st := state.NewState("unarchive", "", []state.Repo{
+		{URL: "github.com/org/repo-a", Branch: "feat/gone"},
+		{URL: "github.com/org/repo-b", Branch: "feat/gone"},
+	})
+	st.Metadata.Archived = true
+	if err := svc.Create("unarchive-ws", st); err != nil {
+		t.Fatal(err)
+	}
+
+	// Branch was deleted upstream and isn't in the bare cache.
+	mock.branchExists = false
+	err := svc.Unarchive(ctx, "unarchive-ws", noop, nil)
+	if !errors.Is(err, ErrBranchNotFound) {
+		t.Fatalf("Unarchive err = %v, want ErrBranchNotFound", err)
+	}
+
+	var repoErr *RepoError
+	if !errors.As(err, &repoErr) || repoErr.Path != "repo-a" {
+		t.Errorf("first RepoError = %v, want repo-a", repoErr)
+	}
+	if !strings.Contains(err.Error(), "repo-b") {
+		t.Errorf("err = %v, want both repos reported", err)
+	}
+
+	loaded, err := svc.Find("unarchive-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Metadata.Archived {
+		t.Error("workspace still archived")
+	}
+}
+
+func TestArchiveRescuesWork(t *testing.T) {
+	svc, mock := testService(t)
+	ctx := context.Background()
+	mock.branchExists = true
+
+	st := state.NewState("rescue", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "feat/x"},
+	})
+	if err := svc.Create("rescue-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Render(ctx, "rescue-ws", noop, &RenderOptions{OnBranchConflict: BranchConflictUseExisting}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dirty worktree with two local-only commits.
+	mock.isClean = false
+	mock.diff = []byte("diff --git a/x b/x\n")
+	mock.unpushed = 2
+	mock.currentBranch = "feat/x"
+	if err := svc.Archive(ctx, "rescue-ws"); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	loaded, err := svc.Find("rescue-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Metadata.Archived {
+		t.Error("workspace not archived")
+	}
+	want := state.Rescued{Path: "api", Branch: "feat/x", Patch: "archive/api.patch", Bundle: "archive/api.bundle", Commits: 2}
+	if len(loaded.Metadata.Rescued) != 1 || loaded.Metadata.Rescued[0] != want {
+		t.Fatalf("Rescued = %+v, want [%+v]", loaded.Metadata.Rescued, want)
+	}
+	wsDir := svc.Config.WorkspacePath("rescue-ws")
+	for _, f := range []string{want.Patch, want.Bundle} {
+		if _, err := os.Stat(filepath.Join(wsDir, f)); err != nil {
+			t.Errorf("%s not written: %v", f, err)
+		}
+	}
+
+	// Unarchive fetches the bundle, reapplies the patch and cleans up.
+	mock.isClean = true
+	if err := svc.Unarchive(ctx, "rescue-ws", noop, nil); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+	if len(mock.bundles) != 1 || len(mock.patches) != 1 {
+		t.Errorf("bundles = %v, patches = %v, want one of each", mock.bundles, mock.patches)
+	}
+	loaded, err = svc.Find("rescue-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Metadata.Rescued) != 0 {
+		t.Errorf("Rescued = %+v, want none", loaded.Metadata.Rescued)
+	}
+	if _, err := os.Stat(filepath.Join(wsDir, want.Patch)); !os.IsNotExist(err) {
+		t.Errorf("patch not removed: %v", err)
+	}
+}
+
+func TestArchiveRejectsRescueDirConflict(t *testing.T) {
+	svc, _ := testService(t)
+
+	st := state.NewState("rescue", "", []state.Repo{
+		{URL: "github.com/org/api", Branch: "main", Path: "archive/api"},
+	})
+	if err := svc.Create("rescue-ws", st); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Archive(context.Background(), "rescue-ws"); !errors.Is(err, ErrRescueDirConflict) {
+		t.Errorf("Archive = %v, want ErrRescueDirConflict", err)
+	}
+}